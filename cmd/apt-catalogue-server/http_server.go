@@ -1,16 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"errors"
-	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	"os"
-	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -18,8 +16,11 @@ import (
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/build"
+	"github.com/woolawin/catalogue/internal/clone"
 	"github.com/woolawin/catalogue/internal/config"
 	"github.com/woolawin/catalogue/internal/ext"
+	"github.com/woolawin/catalogue/internal/index"
 	"github.com/woolawin/catalogue/internal/registry"
 	"github.com/woolawin/catalogue/internal/update"
 )
@@ -29,6 +30,10 @@ type HTTPServer struct {
 	config internal.Config
 	system internal.System
 	api    *ext.API
+	// clients are the tokens clients authenticate with by client name
+	clients map[string]string
+	// snapshot keeps the cached indexes consistent with the Release served
+	snapshot sync.Mutex
 }
 
 func NewHTTPServer(config internal.Config, system internal.System, api *ext.API) *HTTPServer {
@@ -41,26 +46,70 @@ func (server *HTTPServer) start() error {
 
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
+	router.Use(server.authorize)
 
+	router.Get("/repositories/{repo}/key.gpg", server.PublicKey)
 	router.Get("/repositories/{repo}/dists/{distro}/Release", server.Release)
 	router.Get("/repositories/{repo}/dists/{distro}/InRelease", server.InRelease)
 	router.Get("/repositories/{repo}/pool/{package}/{version}/{commit}/install.deb", server.Pool)
 	router.Get("/repositories/{repo}/dists/{distro}/packages/{arch}/{file}", server.Packages)
+	router.Get("/repositories/{repo}/dists/{distro}/packages/{arch}/by-hash/SHA256/{digest}", server.ByHash)
 
-	server.server = &http.Server{
-		Addr:    fmt.Sprintf("localhost:%d", server.config.Port),
-		Handler: router,
+	if server.config.Auth.Mode == internal.TokenAuth {
+		clients, err := server.api.Host.GetClients()
+		if err != nil {
+			return err
+		}
+		if len(clients) == 0 {
+			slog.Warn("no clients in clients file, only this host can reach the repository", "path", ext.ClientsPath)
+		}
+		server.clients = clients
+	}
+
+	tlsConfig, err := tlsConfig(server.config)
+	if err != nil {
+		return err
 	}
 
-	go func() {
-		err := server.server.ListenAndServe()
-		if !errors.Is(err, http.ErrServerClosed) {
-			slog.Error("failed to start server", "error", err)
+	addresses := server.config.ListenAddresses()
+	if server.config.Auth.Mode == internal.NoAuth {
+		for _, address := range addresses {
+			if !loopbackAddress(address) {
+				slog.Warn("repository is served beyond this host without auth, anyone who can reach it can read every package", "address", address)
+			}
 		}
-		slog.Info("stopping http server")
-	}()
+	}
+	listeners, err := listen(addresses)
+	if err != nil {
+		return err
+	}
+
+	// there is no write timeout, apt may take long to download a large
+	// package on a slow link
+	server.server = &http.Server{
+		Handler:           router,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
 
-	slog.Info("started http server", "port", server.config.Port)
+	for _, listener := range listeners {
+		go func() {
+			var err error
+			if tlsConfig != nil {
+				err = server.server.ServeTLS(listener, "", "")
+			} else {
+				err = server.server.Serve(listener)
+			}
+			if !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("failed to serve", "address", listener.Addr().String(), "error", err)
+			}
+			slog.Info("stopping http server", "address", listener.Addr().String())
+		}()
+	}
+
+	slog.Info("started http server", "addresses", addresses, "tls", tlsConfig != nil, "auth", string(server.config.Auth.Mode))
 
 	return nil
 }
@@ -75,16 +124,16 @@ func (server *HTTPServer) Shutdown(ctx context.Context) {
 	}
 }
 
+// Release serves the Release file of a new snapshot of the indexes.
 func (server *HTTPServer) Release(writer http.ResponseWriter, request *http.Request) {
-	content, err := server.packagesFile()
+	message, modified, err := server.release(chi.URLParam(request, "repo"), chi.URLParam(request, "distro"))
 	if err != nil {
-		slog.Error("failed to list packages", "error", err)
+		slog.Error("failed to create release", "error", err)
 		writer.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
-	writer.WriteHeader(http.StatusOK)
-	writer.Write([]byte(content))
+	serveIndex(writer, request, message, modified, "text/plain; charset=utf-8", true)
 }
 
 func (server *HTTPServer) Packages(writer http.ResponseWriter, request *http.Request) {
@@ -96,20 +145,14 @@ func (server *HTTPServer) Packages(writer http.ResponseWriter, request *http.Req
 		return
 	}
 
-	compression := ""
-	if file == "Packages" {
-		compression = "plain"
-	} else if file == "Packages.xz" {
-		compression = "xz"
-	}
-
-	if compression == "" {
+	variant, found := index.VariantNamed(file)
+	if !found {
 		slog.Error("packages file compression not supported", "file", file)
 		writer.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
-	contents, found, err := registry.ReadReleaseCache(compression)
+	contents, found, err := registry.ReadReleaseCache(variant.Compression)
 	if err != nil {
 		slog.Error("failed to read cached release file", "file", file, "error", err)
 		writer.WriteHeader(http.StatusInternalServerError)
@@ -117,78 +160,144 @@ func (server *HTTPServer) Packages(writer http.ResponseWriter, request *http.Req
 	}
 
 	if !found {
-		slog.Warn("did not find cached release file", "file", file)
-		server.Release(writer, request)
+		slog.Warn("did not find cached release file, creating a snapshot", "file", file)
+		_, _, err = server.snapshotIndexes()
+		if err == nil {
+			contents, found, err = registry.ReadReleaseCache(variant.Compression)
+		}
+		if err != nil {
+			slog.Error("failed to create cached release file", "file", file, "error", err)
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			writer.WriteHeader(http.StatusNotFound)
+			return
+		}
+	}
+
+	serveIndex(writer, request, contents.Data, contents.Modified, variant.ContentType, true)
+}
+
+// ByHash serves an index file by its digest from one of the last snapshots,
+// which keeps working while a newer snapshot replaces the files by name.
+func (server *HTTPServer) ByHash(writer http.ResponseWriter, request *http.Request) {
+	digest := strings.TrimSpace(chi.URLParam(request, "digest"))
+
+	contents, found, err := registry.ReadByHash(digest)
+	if err != nil {
+		slog.Error("failed to read index by hash", "digest", digest, "error", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !found {
+		slog.Warn("index by hash not found", "digest", digest)
+		writer.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	writer.WriteHeader(http.StatusOK)
-	writer.Write([]byte(contents))
+	serveIndex(writer, request, contents.Data, contents.Modified, "application/octet-stream", true)
 }
 
 func (server *HTTPServer) InRelease(writer http.ResponseWriter, request *http.Request) {
-	plain, err := server.packagesFile()
+	message, modified, err := server.release(chi.URLParam(request, "repo"), chi.URLParam(request, "distro"))
 	if err != nil {
-		slog.Error("failed to list packages", "error", err)
+		slog.Error("failed to create release", "error", err)
 		writer.WriteHeader(http.StatusInternalServerError)
 		return
 	}
-	plainBytes := []byte(plain)
 
-	checksum := func(in []byte) string {
-		hash := sha256.Sum256(in)
-		slice := hash[:]
-		return hex.EncodeToString(slice)
+	signature, err := internal.PGPSign(server.config.PrivateAPTKey, message)
+	if err != nil {
+		slog.Error("failed to create signature of message", "error", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
 	}
 
-	plainHash := checksum(plainBytes)
+	// a signature is made anew every time, only its time can be revalidated
+	serveIndex(writer, request, []byte(signature), modified, "text/plain; charset=utf-8", false)
+}
+
+// serveIndex serves an index with when its snapshot last changed, so apt and
+// proxies in front can revalidate it with If-Modified-Since. Indexes that are
+// the same bytes every time also get their digest as ETag.
+func serveIndex(writer http.ResponseWriter, request *http.Request, contents []byte, modified time.Time, contentType string, etag bool) {
+	writer.Header().Set("Content-Type", contentType)
+	if etag {
+		hash := sha256.Sum256(contents)
+		writer.Header().Set("ETag", `"`+hex.EncodeToString(hash[:])+`"`)
+	}
+	http.ServeContent(writer, request, "", modified, bytes.NewReader(contents))
+}
 
-	err = registry.CacheRelease("plain", plainBytes)
+// release takes a snapshot of the indexes and returns the Release file of a
+// suite listing it, with its date.
+func (server *HTTPServer) release(repo string, suite string) ([]byte, time.Time, error) {
+	files, modified, err := server.snapshotIndexes()
 	if err != nil {
-		slog.Warn("failed to save plain cache release", "error", err)
+		return nil, time.Time{}, err
 	}
 
-	arch := server.system.Architecture
+	config := server.config.Release(repo, suite)
+	date := index.ReleaseDate(modified, config.ValidFor, time.Now())
+	message := internal.SerializeDebParagraph(index.Release(config, index.Suite{
+		Name:          suite,
+		Version:       server.system.APTDistroVersion,
+		Architectures: []internal.Architecture{server.system.Architecture},
+		Date:          date,
+	}, files))
+	return []byte(message), date, nil
+}
 
-	sha256 := []string{
-		"",
-		fmt.Sprintf("%s %d packages/binary-%s/Packages", plainHash, len(plainBytes), arch),
-	}
+// snapshotIndexes takes a snapshot of the Packages index in every
+// compression and caches it for the Packages route, returning the files with
+// when the snapshot last changed.
+func (server *HTTPServer) snapshotIndexes() ([]index.File, time.Time, error) {
+	server.snapshot.Lock()
+	defer server.snapshot.Unlock()
 
-	message := internal.SerializeDebFile([]map[string]string{
-		{
-			"Origin":        "Catalogue",
-			"Label":         "Catalogue",
-			"Suite":         "stable",
-			"Codename":      "stable",
-			"Version":       server.system.APTDistroVersion,
-			"Date":          time.Now().UTC().Truncate(time.Second).Format(time.RFC1123),
-			"Architectures": string(server.system.Architecture),
-			"Components":    "packages",
-			"SHA256":        internal.DebMultiLine(sha256),
-		},
-	})
+	paragraphs, err := server.packageParagraphs()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
 
-	signature, err := internal.PGPSign(server.config.PrivateAPTKey, []byte(message))
+	files, err := index.Packages(paragraphs, server.system.Architecture)
 	if err != nil {
-		slog.Error("failed to create signature of message", "error", err)
-		writer.WriteHeader(http.StatusInternalServerError)
-		return
+		return nil, time.Time{}, err
 	}
 
-	writer.Write([]byte(signature))
+	// every variant is cached before the Release listing them is served
+	var modified time.Time
+	generation := make(map[string][]byte)
+	for _, file := range files {
+		changed, err := registry.CacheRelease(file.Compression, file.Data)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if changed.After(modified) {
+			modified = changed
+		}
+		generation[file.SHA256()] = file.Data
+	}
+	err = registry.CacheGeneration(generation)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return files, modified, nil
 }
 
-func (server *HTTPServer) packagesFile() (string, error) {
+// packageParagraphs updates every package and returns their Packages entries.
+func (server *HTTPServer) packageParagraphs() ([]internal.Paragraph, error) {
 	packages, err := registry.ListPackages()
 	if err != nil {
 		slog.Error("failed to list packages", "error", err)
-		return "", err
+		return nil, err
 	}
 
 	group := sync.WaitGroup{}
 	mutex := sync.Mutex{}
-	var paragraphs []map[string]string
+	var paragraphs []internal.Paragraph
 
 	for _, pkg := range packages {
 		group.Go(func() {
@@ -205,23 +314,17 @@ func (server *HTTPServer) packagesFile() (string, error) {
 			}
 
 			log := internal.NewLog(internal.NewStdoutLogger(5))
-			updated, build, ok := update.Update(record, log, server.system, server.api)
+			var buildFile config.BuildFile
+			vcs, ok := clone.Open(record.Remote, log, server.api)
 			if ok {
-				record = updated
+				var updated config.Record
+				updated, buildFile, ok = update.Update(record, vcs, build.Opts{}, log, server.system, server.api)
+				if ok {
+					record = updated
+				}
 			}
 
-			paragraph := make(map[string]string)
-			paragraph["Package"] = record.Name
-			paragraph["Version"] = record.LatestPin.VersionName
-			paragraph["Filename"] = packageFilename(record)
-			paragraph["Depends"] = record.Metadata.Dependencies
-			paragraph["Section"] = record.Metadata.Category
-			paragraph["Homepage"] = record.Metadata.Homepage
-			paragraph["Maintainer"] = record.Metadata.Maintainer
-			paragraph["Description"] = record.Metadata.Description
-			paragraph["Architecture"] = record.Metadata.Architecture
-			paragraph["SHA256"] = build.SHA245
-			paragraph["Size"] = strconv.FormatInt(build.Size, 10)
+			paragraph := index.PackageParagraph(record, buildFile)
 
 			mutex.Lock()
 			defer mutex.Unlock()
@@ -231,19 +334,7 @@ func (server *HTTPServer) packagesFile() (string, error) {
 
 	group.Wait()
 
-	return internal.SerializeDebFile(paragraphs), nil
-}
-
-func packageFilename(record config.Record) string {
-	filename := strings.Builder{}
-	filename.WriteString("pool/")
-	filename.WriteString(record.Name)
-	filename.WriteString("/")
-	filename.WriteString(record.LatestPin.VersionName)
-	filename.WriteString("/")
-	filename.WriteString(record.LatestPin.CommitHash)
-	filename.WriteString("/install.deb")
-	return filename.String()
+	return paragraphs, nil
 }
 
 func (server *HTTPServer) Pool(writer http.ResponseWriter, request *http.Request) {
@@ -296,10 +387,18 @@ func (server *HTTPServer) Pool(writer http.ResponseWriter, request *http.Request
 	}
 	defer file.Close()
 
-	writer.WriteHeader(http.StatusOK)
-	_, err = io.Copy(writer, file)
+	info, err := file.Stat()
 	if err != nil {
-		slog.Error("could not write file to response", "package", pkg, "error", err)
+		slog.Error("could not stat file", "path", wanted.Path, "error", err)
 		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// builds never change once made, their digest is a strong ETag and Range
+	// requests let apt resume downloads
+	writer.Header().Set("Content-Type", index.DebContentType)
+	if len(wanted.SHA245) != 0 {
+		writer.Header().Set("ETag", `"`+wanted.SHA245+`"`)
 	}
+	http.ServeContent(writer, request, "install.deb", info.ModTime(), file)
 }
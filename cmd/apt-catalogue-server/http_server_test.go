@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServeIndexRevalidates(t *testing.T) {
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	contents := []byte("Package: foo\n\n")
+
+	request := httptest.NewRequest("GET", "/Packages", nil)
+	request.Header.Set("If-Modified-Since", modified.Format(http.TimeFormat))
+	recorder := httptest.NewRecorder()
+	serveIndex(recorder, request, contents, modified, "text/plain", true)
+	if recorder.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", recorder.Code)
+	}
+
+	request = httptest.NewRequest("GET", "/Packages", nil)
+	request.Header.Set("If-Modified-Since", modified.Add(-time.Hour).Format(http.TimeFormat))
+	recorder = httptest.NewRecorder()
+	serveIndex(recorder, request, contents, modified, "text/plain", true)
+	if recorder.Code != http.StatusOK || recorder.Body.String() != string(contents) {
+		t.Fatalf("expected the index, got %d '%s'", recorder.Code, recorder.Body.String())
+	}
+	if recorder.Header().Get("Last-Modified") != modified.Format(http.TimeFormat) {
+		t.Fatalf("unexpected Last-Modified '%s'", recorder.Header().Get("Last-Modified"))
+	}
+	etag := recorder.Header().Get("ETag")
+
+	request = httptest.NewRequest("GET", "/Packages", nil)
+	request.Header.Set("If-None-Match", etag)
+	recorder = httptest.NewRecorder()
+	serveIndex(recorder, request, contents, modified, "text/plain", true)
+	if recorder.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for ETag %s, got %d", etag, recorder.Code)
+	}
+
+	request = httptest.NewRequest("GET", "/Packages", nil)
+	request.Header.Set("Range", "bytes=9-")
+	recorder = httptest.NewRecorder()
+	serveIndex(recorder, request, contents, modified, "text/plain", true)
+	if recorder.Code != http.StatusPartialContent || recorder.Body.String() != "foo\n\n" {
+		t.Fatalf("expected partial content, got %d '%s'", recorder.Code, recorder.Body.String())
+	}
+}
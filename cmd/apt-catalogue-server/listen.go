@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// tlsConfig is the tls the repository is served with, nil when it is served
+// over plain http.
+func tlsConfig(config internal.Config) (*tls.Config, error) {
+	if !config.TLS.Enabled() {
+		return nil, nil
+	}
+	certPath, keyPath := ext.TLSFiles(config.TLS)
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not load tls certificate '%s'", certPath)
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	if config.Auth.Mode == internal.MTLSAuth {
+		caPath := config.Auth.ClientCA
+		data, err := os.ReadFile(caPath)
+		if err != nil {
+			return nil, internal.ErrOf(err, "can not read client certificate authority '%s'", caPath)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, internal.Err("no certificates in client certificate authority '%s'", caPath)
+		}
+		tlsConfig.ClientCAs = pool
+		// the host itself connects without a certificate, authorize checks
+		// everyone else has one
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
+
+// listen binds every listen address before any is served, so a bad address
+// fails the start.
+func listen(addresses []string) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, address := range addresses {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			for _, bound := range listeners {
+				bound.Close()
+			}
+			return nil, internal.ErrOf(err, "can not listen on '%s'", address)
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// loopbackAddress is whether a listen address can only be reached from the
+// host itself.
+func loopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// authorize only lets through clients that authenticated the way the config
+// asks for. Apt on the host itself is let through unless the config does not
+// trust the host.
+func (server *HTTPServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !server.authorized(request) {
+			if server.config.Auth.Mode == internal.TokenAuth {
+				writer.Header().Set("WWW-Authenticate", `Basic realm="catalogue"`)
+			}
+			writer.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(writer, request)
+	})
+}
+
+func (server *HTTPServer) authorized(request *http.Request) bool {
+	if server.config.Auth.Mode == internal.NoAuth {
+		return true
+	}
+	if server.config.Auth.TrustHost && fromHost(request) {
+		return true
+	}
+
+	switch server.config.Auth.Mode {
+	case internal.TokenAuth:
+		token := requestToken(request)
+		if len(token) == 0 {
+			return false
+		}
+		for _, clientToken := range server.clients {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(clientToken)) == 1 {
+				return true
+			}
+		}
+	case internal.MTLSAuth:
+		return request.TLS != nil && len(request.TLS.VerifiedChains) != 0
+	}
+	return false
+}
+
+// fromHost is whether a request comes from the host itself, over loopback or
+// from the address it was made to.
+func fromHost(request *http.Request) bool {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	remote := net.ParseIP(host)
+	if remote == nil {
+		return false
+	}
+	if remote.IsLoopback() {
+		return true
+	}
+	local, ok := request.Context().Value(http.LocalAddrContextKey).(*net.TCPAddr)
+	return ok && local.IP.Equal(remote)
+}
+
+// requestToken is the bearer token of a request, or the password of basic
+// auth as apt only knows how to send that.
+func requestToken(request *http.Request) string {
+	if _, password, ok := request.BasicAuth(); ok {
+		return password
+	}
+	token, found := strings.CutPrefix(request.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// PublicKey serves the key the Release files are signed with, client-setup
+// fetches it to add the repository on another host.
+func (server *HTTPServer) PublicKey(writer http.ResponseWriter, request *http.Request) {
+	http.ServeFile(writer, request, ext.APTPublicGPGKeyPath)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/woolawin/catalogue/internal"
+)
+
+func TestAuthorizeToken(t *testing.T) {
+	server := &HTTPServer{
+		config:  internal.Config{Auth: internal.AuthConfig{Mode: internal.TokenAuth, TrustHost: true}},
+		clients: map[string]string{"laptop": "0123456789abcdef"},
+	}
+	handler := server.authorize(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {}))
+
+	cases := []struct {
+		name     string
+		remote   string
+		auth     func(request *http.Request)
+		expected int
+	}{
+		{"bearer", "192.168.1.20:4000", func(request *http.Request) { request.Header.Set("Authorization", "Bearer 0123456789abcdef") }, http.StatusOK},
+		{"basic", "192.168.1.20:4000", func(request *http.Request) { request.SetBasicAuth("catalogue", "0123456789abcdef") }, http.StatusOK},
+		{"wrong_token", "192.168.1.20:4000", func(request *http.Request) { request.Header.Set("Authorization", "Bearer fedcba9876543210") }, http.StatusUnauthorized},
+		{"no_token", "192.168.1.20:4000", func(request *http.Request) {}, http.StatusUnauthorized},
+		{"loopback", "127.0.0.1:4000", func(request *http.Request) {}, http.StatusOK},
+		{"host_address", "192.168.1.10:4000", func(request *http.Request) {}, http.StatusOK},
+	}
+	for _, test := range cases {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/repositories/catalogue/key.gpg", nil)
+			local := &net.TCPAddr{IP: net.ParseIP("192.168.1.10"), Port: 6111}
+			request = request.WithContext(context.WithValue(request.Context(), http.LocalAddrContextKey, local))
+			request.RemoteAddr = test.remote
+			test.auth(request)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			if recorder.Code != test.expected {
+				t.Fatalf("expected %d, got %d", test.expected, recorder.Code)
+			}
+		})
+	}
+
+	server.config.Auth.TrustHost = false
+	request := httptest.NewRequest("GET", "/repositories/catalogue/key.gpg", nil)
+	request.RemoteAddr = "127.0.0.1:4000"
+	if server.authorized(request) {
+		t.Fatal("expected the host not to be let through when it is not trusted")
+	}
+}
+
+func TestAuthorizeMTLS(t *testing.T) {
+	server := &HTTPServer{config: internal.Config{Auth: internal.AuthConfig{Mode: internal.MTLSAuth}}}
+
+	request := httptest.NewRequest("GET", "/repositories/catalogue/key.gpg", nil)
+	request.RemoteAddr = "192.168.1.20:4000"
+	request.TLS = &tls.ConnectionState{}
+	if server.authorized(request) {
+		t.Fatal("expected a client without a certificate not to be authorized")
+	}
+
+	request.TLS.VerifiedChains = [][]*x509.Certificate{{{}}}
+	if !server.authorized(request) {
+		t.Fatal("expected a client with a verified certificate to be authorized")
+	}
+}
+
+func TestLoopbackAddress(t *testing.T) {
+	for address, expected := range map[string]bool{
+		"localhost:6111":  true,
+		"127.0.0.1:6111":  true,
+		"[::1]:6111":      true,
+		"0.0.0.0:6111":    false,
+		":6111":           false,
+		"buildhost:6111":  false,
+		"192.168.1.2:443": false,
+	} {
+		if actual := loopbackAddress(address); actual != expected {
+			t.Fatalf("expected loopback of '%s' to be %t", address, expected)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
@@ -12,7 +13,9 @@ import (
 	"github.com/woolawin/catalogue/internal/clone"
 	"github.com/woolawin/catalogue/internal/config"
 	"github.com/woolawin/catalogue/internal/daemon"
+	"github.com/woolawin/catalogue/internal/export"
 	"github.com/woolawin/catalogue/internal/ext"
+	"github.com/woolawin/catalogue/internal/registry"
 	"github.com/woolawin/catalogue/internal/setup"
 )
 
@@ -35,6 +38,30 @@ func runSetup(cmd *cobra.Command, args []string) {
 	setup.SetUp(internal.NewLog(internal.NewStdoutLogger(5)))
 }
 
+func runClientSetup(cmd *cobra.Command, cliargs []string) {
+	log := internal.NewLog(internal.NewStdoutLogger(5))
+	log.Stage("cli")
+
+	opts := setup.ClientOpts{URL: cliargs[0]}
+	tokenFile, _ := cmd.Flags().GetString("token-file")
+	if len(tokenFile) != 0 {
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			log.Err(err, "failed to read token file '%s'", tokenFile)
+			os.Exit(1)
+		}
+		opts.Token = strings.TrimSpace(string(token))
+	}
+	opts.CA, _ = cmd.Flags().GetString("ca")
+	opts.Cert, _ = cmd.Flags().GetString("cert")
+	opts.Key, _ = cmd.Flags().GetString("key")
+	opts.Fingerprint, _ = cmd.Flags().GetString("fingerprint")
+
+	if !setup.ClientSetUp(opts, log) {
+		os.Exit(1)
+	}
+}
+
 func runUpdate(cmd *cobra.Command, cliargs []string) {
 	logger := internal.NewStdoutLogger(5)
 	log := internal.NewLog(logger)
@@ -46,6 +73,10 @@ func runUpdate(cmd *cobra.Command, cliargs []string) {
 		component = cliargs[0]
 	}
 	args := map[string]any{"component": component}
+	installBuildDeps, _ := cmd.Flags().GetBool("install-build-deps")
+	if installBuildDeps {
+		args["install_build_deps"] = true
+	}
 	ok, _, err := client.Send(daemon.Update, args)
 	if err != nil || !ok {
 		os.Exit(1)
@@ -72,34 +103,155 @@ func runDelete(cmd *cobra.Command, cliargs []string) {
 	}
 }
 
+func runPrefs(cmd *cobra.Command, cliargs []string) {
+	logger := internal.NewStdoutLogger(5)
+	log := internal.NewLog(logger)
+	log.Stage("cli")
+
+	args := map[string]any{}
+	switch len(cliargs) {
+	case 0:
+	case 2:
+		priority, err := strconv.Atoi(cliargs[1])
+		if err != nil {
+			log.Err(err, "invalid priority '%s'", cliargs[1])
+			os.Exit(1)
+		}
+		args["component"] = cliargs[0]
+		args["priority"] = priority
+	default:
+		log.Err(nil, "expected a package name and a priority")
+		os.Exit(1)
+	}
+
+	client := daemon.NewClient(logger)
+	ok, _, err := client.Send(daemon.Prefs, args)
+	if err != nil || !ok {
+		os.Exit(1)
+	}
+}
+
+func runVerifyBuild(cmd *cobra.Command, cliargs []string) {
+	logger := internal.NewStdoutLogger(5)
+	log := internal.NewLog(logger)
+	log.Stage("cli")
+
+	client := daemon.NewClient(logger)
+	args := map[string]any{"component": cliargs[0]}
+	ok, _, err := client.Send(daemon.VerifyBuild, args)
+	if err != nil {
+		log.Err(err, "failed to communicate with daemon")
+		os.Exit(1)
+	}
+	if !ok {
+		fmt.Println("ERROR: rebuilding did not give the same package")
+		os.Exit(1)
+	}
+	fmt.Println("OK: rebuilding gave the same package")
+}
+
 func runConfig(cmd *cobra.Command, args []string) {
 	config, _ := ext.NewHost().GetConfig()
 	fmt.Println("DefaultUser: ", config.DefaultUser)
+	for name, path := range config.Anchors {
+		fmt.Printf("Anchor %s: %s\n", name, path)
+	}
+	for name, base := range config.Aliases {
+		fmt.Printf("Alias %s: %s\n", name, base)
+	}
+}
+
+func runExport(cmd *cobra.Command, args []string) {
+	log := internal.NewLog(internal.NewStdoutLogger(5))
+	log.Stage("cli")
+
+	dir, _ := cmd.Flags().GetString("dir")
+	repo, _ := cmd.Flags().GetString("repo")
+	suite, _ := cmd.Flags().GetString("suite")
+	if len(dir) == 0 {
+		log.Err(nil, "no directory specified with --dir")
+		os.Exit(1)
+	}
+
+	if !export.Export(dir, repo, suite, log, ext.NewAPI("/")) {
+		os.Exit(1)
+	}
+}
+
+func runInfo(cmd *cobra.Command, args []string) {
+	log := internal.NewLog(internal.NewStdoutLogger(5))
+	if len(args) == 0 {
+		log.Err(nil, "no package name expecified")
+		os.Exit(1)
+	}
+
+	record, found, err := registry.GetPackageRecord(args[0])
+	if err != nil {
+		log.Err(err, "failed to read package '%s'", args[0])
+		os.Exit(1)
+	}
+	if !found {
+		log.Err(nil, "package '%s' has not been added", args[0])
+		os.Exit(1)
+	}
+
+	remote := record.Remote.URL.Redacted()
+	if len(record.Remote.Alias) != 0 {
+		remote = fmt.Sprintf("%s (%s)", record.Remote.Alias, remote)
+	}
+
+	data := internal.Paragraph{
+		{Name: "Package", Value: record.Name},
+		{Name: "Version", Value: record.LatestPin.VersionName},
+		{Name: "Commit", Value: record.LatestPin.CommitHash},
+		{Name: "Remote", Value: remote},
+		{Name: "Protocol", Value: config.ProtocolDebugString(record.Remote.Protocol)},
+		{Name: "Maintainer", Value: record.Metadata.Maintainer},
+		{Name: "Homepage", Value: record.Metadata.Homepage},
+		{Name: "Builds", Value: strconv.Itoa(len(record.Builds))},
+		{Name: "Description", Value: record.Metadata.Description},
+	}
+
+	fmt.Println(internal.SerializeDebParagraph(data))
 }
 
 func runSystem(cmd *cobra.Command, args []string) {
 	log := internal.NewLog(internal.NewStdoutLogger(5))
-	system, err := ext.NewHost().GetSystem()
+	host := ext.NewHost()
+	system, err := host.GetSystem()
 	if err != nil {
 		log.Err(err, "failed to get system information")
 		os.Exit(1)
 	}
-
-	data := make(map[string]string)
-	data["Arhiteture"] = string(system.Architecture)
-	data["OSReleaseID"] = system.OSReleaseID
-	data["OSReleaseVersion"] = system.OSReleaseVersion
-	data["OSReleaseVersionID"] = system.OSReleaseVersionID
-	data["OSReleaseVersionCodeName"] = system.OSReleaseVersionCodeName
+	overrideSystem(&system, cmd)
+
+	data := internal.Paragraph{
+		{Name: "Arhiteture", Value: string(system.Architecture)},
+		{Name: "OSReleaseID", Value: system.OSReleaseID},
+		{Name: "OSReleaseVersion", Value: system.OSReleaseVersion},
+		{Name: "OSReleaseVersionID", Value: system.OSReleaseVersionID},
+		{Name: "OSReleaseVersionCodeName", Value: system.OSReleaseVersionCodeName},
+	}
 
 	fmt.Println(internal.SerializeDebParagraph(data))
+
+	component, _ := cmd.Flags().GetString("explain")
+	if len(component) == 0 {
+		return
+	}
+
+	explanation, ok := explainComponent(component, log, system, host)
+	if !ok {
+		os.Exit(1)
+	}
+	fmt.Print(explanation.String())
 }
 
 func runAdd(cmd *cobra.Command, cliargs []string) {
 	logger := internal.NewStdoutLogger(5)
 	log := internal.NewLog(logger)
 	log.Stage("cli")
-	protocol, remote, err := getProtocolAndRemote(cmd, cliargs)
+	protocol, remote, alias, err := getProtocolAndRemote(cmd, cliargs)
 	if err != nil {
 		fmt.Println("ERROR")
 		fmt.Println(err.Error())
@@ -109,6 +261,17 @@ func runAdd(cmd *cobra.Command, cliargs []string) {
 
 	client := daemon.NewClient(logger)
 	args := map[string]any{"protocol": protocol, "remote": remote}
+	if len(alias) != 0 {
+		args["alias"] = alias
+	}
+	versions, _ := cmd.Flags().GetString("versions")
+	if len(versions) != 0 {
+		args["versions"] = versions
+	}
+	installBuildDeps, _ := cmd.Flags().GetBool("install-build-deps")
+	if installBuildDeps {
+		args["install_build_deps"] = true
+	}
 	ok, _, err := client.Send(daemon.Add, args)
 	if err != nil {
 		log.Err(err, "failed to communicate with daemon")
@@ -134,9 +297,19 @@ func runClone(cmd *cobra.Command, args []string) {
 		protocolCount++
 		protocol = config.Git
 	}
+	http, _ := cmd.Flags().GetBool("http")
+	if http {
+		protocolCount++
+		protocol = config.HTTP
+	}
+	dir, _ := cmd.Flags().GetBool("dir")
+	if dir {
+		protocolCount++
+		protocol = config.Local
+	}
 
 	if protocolCount != 1 {
-		fmt.Println("ERROR: must specify --git")
+		fmt.Println("ERROR: must specify one of --git, --http or --dir")
 		os.Exit(0)
 	}
 
@@ -149,13 +322,16 @@ func runClone(cmd *cobra.Command, args []string) {
 
 	api := ext.NewAPI("/")
 	log := internal.NewLog(internal.NewStdoutLogger(9))
+	vcs, ok := clone.Open(config.Remote{Protocol: protocol, URL: remoteURL}, log, api)
+	if !ok {
+		os.Exit(1)
+	}
 	opts := clone.NewOpts(
-		config.Remote{Protocol: protocol, URL: remoteURL},
 		local,
 		path,
 		nil,
 	)
-	_, ok := clone.Clone(opts, log, api)
+	_, _, ok = clone.Clone(vcs, opts, log, api)
 	if !ok {
 		os.Exit(1)
 	}
@@ -169,6 +345,10 @@ func args() *cobra.Command {
 		Run:   runAdd,
 	}
 	add.Flags().String("git", "", "Add from a git repository")
+	add.Flags().String("http", "", "Add from a tarball or zip URL, may contain a '{version}' placeholder")
+	add.Flags().String("versions", "", "URL listing the versions of an --http component")
+	add.Flags().String("dir", "", "Add from a directory or git repository on this machine")
+	add.Flags().Bool("install-build-deps", false, "Install missing build dependencies with apt-get before building")
 
 	/*	var build = &cobra.Command{
 			Use:   "build",
@@ -193,6 +373,12 @@ func args() *cobra.Command {
 		Long:  "",
 		Run:   runSystem,
 	}
+	printSystem.Flags().String("explain", "", "Explain how a local directory or remote component resolves for this system, without building it")
+	printSystem.Flags().String("architecture", "", "Override the system architecture")
+	printSystem.Flags().String("os-release-id", "", "Override the system OS release ID")
+	printSystem.Flags().String("os-release-version", "", "Override the system OS release version")
+	printSystem.Flags().String("os-release-version-id", "", "Override the system OS release version ID")
+	printSystem.Flags().String("os-release-version-code-name", "", "Override the system OS release version code name")
 
 	var version = &cobra.Command{
 		Use:   "version",
@@ -211,6 +397,8 @@ func args() *cobra.Command {
 	clone.Flags().String("local", "", "The local destination to clone to")
 	clone.Flags().String("path", "", "The path to clone files from the remote")
 	clone.Flags().Bool("git", false, "Clone via git")
+	clone.Flags().Bool("http", false, "Clone via a tarball or zip URL")
+	clone.Flags().Bool("dir", false, "Clone from a directory or git repository on this machine")
 	clone.MarkFlagRequired("remote")
 	clone.MarkFlagRequired("local")
 	clone.MarkFlagRequired("path")
@@ -228,6 +416,7 @@ func args() *cobra.Command {
 		Long:  "",
 		Run:   runUpdate,
 	}
+	update.Flags().Bool("install-build-deps", false, "Install missing build dependencies with apt-get before building")
 
 	setup := &cobra.Command{
 		Use:   "setup",
@@ -236,6 +425,21 @@ func args() *cobra.Command {
 		Run:   runSetup,
 	}
 
+	clientSetup := &cobra.Command{
+		Use:   "client-setup <url>",
+		Short: "Add the catalogue repository of another host to apt on this one",
+		Long: "The url is the address the other host serves its repository on, like https://buildhost:6111\n\n" +
+			"The other host lets itself through without auth. A proxy on that host, like apt-cacher-ng, lets whoever it serves through as well unless its [auth] config has trust_host = false.",
+		Args: cobra.ExactArgs(1),
+		Run:  runClientSetup,
+	}
+	clientSetup.Flags().String("token-file", "", "File with the token to authenticate with")
+	clientSetup.Flags().String("ca", "", "Certificate authority to trust the repository with, the ca.crt of a self signed repository")
+	clientSetup.Flags().String("cert", "", "Client certificate to authenticate with")
+	clientSetup.Flags().String("key", "", "Key of the client certificate")
+	clientSetup.Flags().String("fingerprint", "", "Fingerprint of the repository key, 'catalogue setup' prints it on the other host")
+	clientSetup.MarkFlagRequired("fingerprint")
+
 	delete := &cobra.Command{
 		Use:   "del",
 		Short: "Delete a package from the catalogue registry, this does not uninstall the package!",
@@ -243,6 +447,40 @@ func args() *cobra.Command {
 		Run:   runDelete,
 	}
 
+	exportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the registry as a static apt repository, to host it with any web server",
+		Long:  "Writes the dists and pool of the repository into the directory, only copying what changed since the last export. The directory is what the repository URL points to.",
+		Args:  cobra.NoArgs,
+		Run:   runExport,
+	}
+	exportCmd.Flags().String("dir", "", "Directory to export the repository to")
+	exportCmd.Flags().String("repo", internal.HostRepository, "Repository whose release config to use")
+	exportCmd.Flags().String("suite", internal.HostSuite, "Suite to export the packages as")
+
+	info := &cobra.Command{
+		Use:   "info",
+		Short: "Print the registry record of a component",
+		Long:  "",
+		Run:   runInfo,
+	}
+
+	verifyBuild := &cobra.Command{
+		Use:   "verify-build <package>",
+		Short: "Rebuild the latest version of a package and check it is the same as the one in the registry",
+		Long:  "",
+		Args:  cobra.ExactArgs(1),
+		Run:   runVerifyBuild,
+	}
+
+	prefs := &cobra.Command{
+		Use:   "prefs [<package> <priority>]",
+		Short: "Regenerate the apt preferences of catalogue packages, or set the apt pin priority of a package",
+		Long:  "A priority above 1000 installs the catalogue package even when it is a downgrade, one like 100 leaves a package of another repository in place. A priority of 0 removes the pin.",
+		Args:  cobra.RangeArgs(0, 2),
+		Run:   runPrefs,
+	}
+
 	var root = &cobra.Command{
 		Use:   "catalogue",
 		Short: "The missing piece to APT. An APT Repository Middleware",
@@ -256,6 +494,11 @@ func args() *cobra.Command {
 	root.AddCommand(config)
 	root.AddCommand(update)
 	root.AddCommand(setup)
+	root.AddCommand(clientSetup)
 	root.AddCommand(delete)
+	root.AddCommand(info)
+	root.AddCommand(exportCmd)
+	root.AddCommand(verifyBuild)
+	root.AddCommand(prefs)
 	return root
 }
@@ -1,51 +1,59 @@
 package main
 
 import (
-	"fmt"
+	"bytes"
+	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/clone"
 	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/explain"
+	"github.com/woolawin/catalogue/internal/ext"
 )
 
-func getProtocolAndRemote(cmd *cobra.Command, args []string) (config.Protocol, string, error) {
-	git, _ := cmd.Flags().GetString("git")
-	if len(git) != 0 {
-		return config.Git, git, nil
+// getProtocolAndRemote returns the protocol and remote of the component to
+// add, and the shorthand it was given as if any.
+func getProtocolAndRemote(cmd *cobra.Command, args []string) (config.Protocol, string, string, error) {
+	dir, _ := cmd.Flags().GetString("dir")
+	if len(dir) != 0 {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return 0, "", "", internal.ErrOf(err, "invalid directory '%s'", dir)
+		}
+		return config.Local, (&url.URL{Scheme: "file", Path: abs}).String(), "", nil
 	}
 
-	if len(args) == 0 {
-		return 0, "", internal.Err("must specify name of component to install")
+	git, _ := cmd.Flags().GetString("git")
+	if strings.HasPrefix(git, "file://") {
+		return config.Local, git, "", nil
 	}
-
-	return getProtocolAndRemoteFromFreidnly(args[0])
-}
-
-func getProtocolAndRemoteFromFreidnly(value string) (config.Protocol, string, error) {
-
-	if !strings.HasPrefix(value, "github/") {
-		return 0, "", internal.Err("only github components are currently supported")
+	if len(git) != 0 {
+		return config.Git, git, "", nil
 	}
 
-	ref, _ := strings.CutPrefix(value, "github/")
-
-	sep := strings.Index(ref, "/")
-	if sep == -1 {
-		return 0, "", internal.Err("invalid github component '%s', expected '{github}/{owner}/{repo}'", value)
+	http, _ := cmd.Flags().GetString("http")
+	if len(http) != 0 {
+		return config.HTTP, http, "", nil
 	}
 
-	owner := strings.TrimSpace(ref[:sep])
-	if len(owner) == 0 {
-		return 0, "", internal.Err("invalid github owner '%s', expected '{github}/{owner}/{repo}'", value)
+	if len(args) == 0 {
+		return 0, "", "", internal.Err("must specify name of component to install")
 	}
 
-	repo := strings.TrimSpace(ref[sep+1:])
-	if len(repo) == 0 {
-		return 0, "", internal.Err("invalid github repo '%s', expected '{github}/{owner}/{repo}'", value)
-	}
-	remote := fmt.Sprintf("https://github.com/%s/%s.git", owner, repo)
+	hostConfig, _ := ext.NewHost().GetConfig()
+	protocol, remote, err := getProtocolAndRemoteFromFreidnly(args[0], hostConfig.Aliases)
+	return protocol, remote, strings.TrimSpace(args[0]), err
+}
 
+func getProtocolAndRemoteFromFreidnly(value string, aliases map[string]string) (config.Protocol, string, error) {
+	remote, err := config.ExpandShorthand(value, aliases)
+	if err != nil {
+		return 0, "", err
+	}
 	return config.Git, remote, nil
 }
 
@@ -76,3 +84,82 @@ func overrideSystem(system *internal.System, cmd *cobra.Command) {
 	}
 
 }
+
+func explainComponent(value string, log *internal.Log, system internal.System, host *ext.Host) (explain.Explanation, bool) {
+	prev := log.Stage("explain")
+	defer prev()
+
+	var dir string
+	var remote config.Remote
+	author := ""
+
+	info, err := os.Stat(value)
+	if err == nil && info.IsDir() {
+		abs, err := filepath.Abs(value)
+		if err != nil {
+			log.Err(err, "invalid component directory '%s'", value)
+			return explain.Explanation{}, false
+		}
+		dir = abs
+		nested := filepath.Join(abs, ".catalogue")
+		if info, err := os.Stat(nested); err == nil && info.IsDir() {
+			dir = nested
+		}
+		remote = config.Remote{Protocol: config.Local, URL: &url.URL{Scheme: "file", Path: abs}}
+	} else {
+		protocol, remoteStr, alias := config.Git, value, ""
+		if strings.HasPrefix(value, "file://") {
+			protocol = config.Local
+		} else if !strings.Contains(value, "://") {
+			hostConfig, _ := host.GetConfig()
+			alias = value
+			protocol, remoteStr, err = getProtocolAndRemoteFromFreidnly(value, hostConfig.Aliases)
+			if err != nil {
+				log.Err(err, "'%s' is neither a directory nor a remote component", value)
+				return explain.Explanation{}, false
+			}
+		}
+		remoteURL, err := url.Parse(remoteStr)
+		if err != nil {
+			log.Err(err, "invalid remote '%s'", remoteStr)
+			return explain.Explanation{}, false
+		}
+		remote = config.Remote{Protocol: protocol, URL: remoteURL, Alias: alias}
+
+		local := host.RandomTmpDir()
+		defer os.RemoveAll(local)
+
+		api := ext.NewAPI("/")
+		vcs, ok := clone.Open(remote, log, api)
+		if !ok {
+			return explain.Explanation{}, false
+		}
+		opts := clone.NewOpts(local, ".catalogue", nil)
+		_, cloneAuthor, ok := clone.Clone(vcs, opts, log, api)
+		if !ok {
+			return explain.Explanation{}, false
+		}
+		author = cloneAuthor
+		dir = filepath.Join(local, ".catalogue")
+	}
+
+	disk := ext.NewDisk(dir)
+	configPath := disk.Path("config.toml")
+	configData, found, err := disk.ReadFile(configPath)
+	if err != nil {
+		log.Err(err, "failed to read config.toml at '%s'", configPath)
+		return explain.Explanation{}, false
+	}
+	if !found {
+		log.Err(nil, "config.toml not found at '%s'", configPath)
+		return explain.Explanation{}, false
+	}
+
+	component, err := config.ParseWithFileMaps(bytes.NewReader(configData), disk)
+	if err != nil {
+		log.Err(err, "failed to deserialize config.toml at '%s'", configPath)
+		return explain.Explanation{}, false
+	}
+
+	return explain.Explain(component, remote, author, system, host), true
+}
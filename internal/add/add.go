@@ -2,7 +2,7 @@ package add
 
 import (
 	"bytes"
-	"net/url"
+	"os"
 	"path/filepath"
 
 	"crypto/sha256"
@@ -17,26 +17,26 @@ import (
 	"github.com/woolawin/catalogue/internal/registry"
 )
 
-func Add(protocol config.Protocol, remoteStr string, log *internal.Log, system internal.System, api *ext.API) bool {
+func Add(vcs clone.VCS, buildOpts build.Opts, log *internal.Log, system internal.System, api *ext.API) bool {
 	prev := log.Stage("add")
 	defer prev()
 
-	remoteURL, err := url.Parse(remoteStr)
-	if err != nil {
-		log.Err(err, "invalid remote '%s'", remoteStr)
+	remote := vcs.Remote()
+	if _, hasPassword := remote.URL.User.Password(); hasPassword {
+		log.Err(nil, "remote '%s' has a password, put it in '%s' instead so it is not stored in the registry", remote.URL.Redacted(), ext.CredentialsPath)
 		return false
 	}
 
 	local := api.Host.RandomTmpDir()
+	defer os.RemoveAll(local)
 
 	opts := clone.NewOpts(
-		config.Remote{Protocol: protocol, URL: remoteURL},
 		local,
 		".catalogue",
 		nil,
 	)
 
-	author, ok := clone.Clone(opts, log, api)
+	pin, author, ok := clone.Clone(vcs, opts, log, api)
 	if !ok {
 		return false
 	}
@@ -61,14 +61,16 @@ func Add(protocol config.Protocol, remoteStr string, log *internal.Log, system i
 	}
 
 	if exists {
-		log.Err(nil, "package with name '%s' already exists")
+		log.Err(nil, "package with name '%s' already exists", component.Name)
 		return false
 	}
 
-	remote := config.Remote{Protocol: protocol, URL: remoteURL}
-	metadata, err := config.BuildMetadata(component.Metadata, remote, author, log, system)
+	readFile := func(path string) ([]byte, error) {
+		return clone.ReadFile(vcs, pin, local, path, api)
+	}
+	metadata, err := config.BuildMetadata(component.Metadata, remote, author, readFile, log, system)
 	if err != nil {
-		log.Err(err, "failed to build metadata from config.toml at '%s'", remoteStr)
+		log.Err(err, "failed to build metadata from config.toml at '%s'", remote.URL.Redacted())
 		return false
 	}
 
@@ -77,10 +79,6 @@ func Add(protocol config.Protocol, remoteStr string, log *internal.Log, system i
 		return false
 	}
 
-	pin, ok := clone.CheckoutLatestVersion(local, log)
-	if !ok {
-		return false
-	}
 	record := config.Record{
 		Name:      component.Name,
 		LatestPin: pin,
@@ -93,11 +91,19 @@ func Add(protocol config.Protocol, remoteStr string, log *internal.Log, system i
 		return false
 	}
 
+	if component.Build != nil {
+		ok = clone.CloneTree(vcs, pin, local, log)
+		if !ok {
+			return false
+		}
+	}
+
 	file, err := registry.PackageBuildFile(record, pin.CommitHash)
 	if err != nil {
 		log.Err(err, "failed to assemle package '%s'", record.Name)
 		return false
 	}
+	defer os.Remove(file.Name())
 	defer file.Close()
 
 	hasher := sha256.New()
@@ -106,16 +112,22 @@ func Add(protocol config.Protocol, remoteStr string, log *internal.Log, system i
 	writer := io.MultiWriter(file, hasher, &counter)
 
 	buildPath := filepath.Join(local, ".catalogue")
-	ok = build.Build(writer, record, log, system, ext.NewAPI(buildPath))
+	ok = build.Build(writer, record, buildOpts, log, system, ext.NewAPI(buildPath))
 	if !ok {
 		return false
 	}
 
+	path, err := registry.SaveBuildFile(file)
+	if err != nil {
+		log.Err(err, "failed to save build of package '%s'", record.Name)
+		return false
+	}
+
 	digest := hex.EncodeToString(hasher.Sum(nil))
 	build := config.BuildFile{
 		Version:    pin.VersionName,
 		CommitHash: pin.CommitHash,
-		Path:       file.Name(),
+		Path:       path,
 		Size:       counter.Count(),
 		SHA245:     digest,
 	}
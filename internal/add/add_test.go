@@ -0,0 +1,133 @@
+package add
+
+import (
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/build"
+	"github.com/woolawin/catalogue/internal/clone"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+	"github.com/woolawin/catalogue/internal/registry"
+)
+
+type DoNothingLogger struct {
+}
+
+func (log *DoNothingLogger) Log(stmt *internal.LogStatement) {
+
+}
+
+const configTOML = `
+name='foo'
+type='package'
+supported_targets=['all']
+
+[metadata.all]
+maintainer='Bob Doe <bob@foo.com>'
+description='foo'
+architecture='all'
+`
+
+func u(value string) *url.URL {
+	res, err := url.Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func setUp(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		t.Skip("dpkg-deb is not installed")
+	}
+	base := registry.PackagesBase
+	registry.PackagesBase = t.TempDir()
+	t.Cleanup(func() { registry.PackagesBase = base })
+}
+
+func mockVCS(remote string) *clone.MockVCS {
+	return &clone.MockVCS{
+		Source:   config.Remote{Protocol: config.Git, URL: u(remote)},
+		Versions: []config.Pin{{VersionName: "1.0.0", CommitHash: "aaa"}},
+		Files: map[string]map[string]string{
+			"aaa": {
+				".catalogue/config.toml":                           configTOML,
+				".catalogue/filemaps/root.all/usr/share/foo/a.txt": "a",
+			},
+		},
+		Authors: map[string]string{"aaa": "Bob Doe <bob@foo.com>"},
+	}
+}
+
+func TestAdd(t *testing.T) {
+	setUp(t)
+	log := internal.NewLog(&DoNothingLogger{})
+	system := internal.System{Architecture: internal.AMD64}
+
+	ok := Add(mockVCS("https://foo.com/foo.git"), build.Opts{}, log, system, ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected add to succeed")
+	}
+
+	record, found, err := registry.GetPackageRecord("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("expected package 'foo' in the registry")
+	}
+	expected := config.Pin{VersionName: "1.0.0", CommitHash: "aaa"}
+	if diff := cmp.Diff(expected, record.LatestPin); diff != "" {
+		t.Fatalf("pin mismatch (-want +got):\n%s", diff)
+	}
+	if record.Remote.URL.String() != "https://foo.com/foo.git" {
+		t.Fatalf("unexpected remote '%s'", record.Remote.URL)
+	}
+	if len(record.Builds) != 1 {
+		t.Fatalf("expected one build, got %d", len(record.Builds))
+	}
+	build := record.Builds[0]
+	if build.Version != "1.0.0" || build.CommitHash != "aaa" {
+		t.Fatalf("unexpected build of '%s' at '%s'", build.Version, build.CommitHash)
+	}
+	if filepath.Base(build.Path) != "build.deb" {
+		t.Fatalf("expected the build to be moved into place, got '%s'", build.Path)
+	}
+	info, err := os.Stat(build.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() != int64(build.Size) {
+		t.Fatalf("build is %d bytes, record says %d", info.Size(), build.Size)
+	}
+}
+
+func TestAddDuplicateName(t *testing.T) {
+	setUp(t)
+	log := internal.NewLog(&DoNothingLogger{})
+	system := internal.System{Architecture: internal.AMD64}
+
+	ok := Add(mockVCS("https://foo.com/foo.git"), build.Opts{}, log, system, ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected first add to succeed")
+	}
+	ok = Add(mockVCS("https://bar.com/foo.git"), build.Opts{}, log, system, ext.NewAPI("/"))
+	if ok {
+		t.Fatal("expected add of a second package named 'foo' to fail")
+	}
+
+	record, _, err := registry.GetPackageRecord("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Remote.URL.String() != "https://foo.com/foo.git" {
+		t.Fatalf("expected the first package to be kept, got remote '%s'", record.Remote.URL)
+	}
+}
@@ -2,16 +2,30 @@ package build
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/woolawin/catalogue/internal"
 	"github.com/woolawin/catalogue/internal/config"
 	"github.com/woolawin/catalogue/internal/ext"
 )
 
-func Build(dst io.Writer, record config.Record, log *internal.Log, system internal.System, api *ext.API) bool {
+// Opts are what whoever asks for a build decides, rather than the component.
+type Opts struct {
+	installBuildDeps bool
+}
+
+func NewOpts(installBuildDeps bool) Opts {
+	return Opts{installBuildDeps: installBuildDeps}
+}
+
+func Build(dst io.Writer, record config.Record, opts Opts, log *internal.Log, system internal.System, api *ext.API) bool {
 	prev := log.Stage("build")
 	defer prev()
 
@@ -37,28 +51,78 @@ func Build(dst io.Writer, record config.Record, log *internal.Log, system intern
 		return false
 	}
 
+	ok := depends(component.Build, opts, log, api)
+	if !ok {
+		return false
+	}
+
+	// builds are dated to the pinned commit so the same pin always builds
+	// the same package
+	epoch := record.LatestPin.Date
+	if epoch.IsZero() {
+		log.Info(8, "pin '%s' has no date, dating the build to the epoch", record.LatestPin.VersionName)
+		epoch = time.Unix(0, 0)
+	}
+
 	tmpDir := api.Host.RandomTmpDir()
 	tmp := ext.NewDisk(tmpDir)
 
-	ok := control(record, log, tmp)
+	// .catalogue is at the root of the tree a source build runs in
+	ok = source(component.Build, filepath.Dir(string(api.Disk.Path())), epoch, log, tmp, api)
+	if !ok {
+		return false
+	}
+	ok = filemap(system, record.Name, component.FileMaps, log, tmp, api)
+	if !ok {
+		return false
+	}
+	ok = download(system, record.Name, component.Downloads, log, tmp, api)
+	if !ok {
+		return false
+	}
+	scripts := maintainerScripts{}
+	ok = files(record.Name, component.Files, &scripts, log, tmp)
+	if !ok {
+		return false
+	}
+
+	ok = home(record.Name, &scripts, log, tmp, api)
+	if !ok {
+		return false
+	}
+	ok = services(component.Services, &scripts, log, tmp, api)
 	if !ok {
 		return false
 	}
-	ok = filemap(system, component.FileMaps, log, tmp, api)
+	ok = scripts.write(log, tmp)
 	if !ok {
 		return false
 	}
-	ok = download(system, component.Downloads, log, tmp, api)
+	// the control file goes last, the services found decide what it depends on
+	ok = control(record, scripts.depends, log, tmp)
 	if !ok {
 		return false
 	}
 
+	err = clamp(tmpDir, epoch)
+	if err != nil {
+		log.Err(err, "failed to date files of the package")
+		return false
+	}
+
+	// dpkg-deb sorts entries and dates the archive by SOURCE_DATE_EPOCH, a
+	// single compressor thread keeps the compressed data the same as well.
+	// The thread limit is given through the environment, dpkg-deb before
+	// 1.21.9 does not know the --threads-max option. Entries are owned by
+	// root rather than whoever runs the build, owners declared in
+	// config.toml are set by the postinst.
 	debFile := api.Host.RandomTmpFile(".deb")
-	args := []string{"-b", tmpDir, debFile}
+	args := []string{"-Zxz", "--root-owner-group", "-b", tmpDir, debFile}
 	ar := exec.Command("dpkg-deb", args...)
+	ar.Env = append(os.Environ(), fmt.Sprintf("SOURCE_DATE_EPOCH=%d", epoch.Unix()), "DPKG_DEB_THREADS_MAX=1")
 	stdout, err := ar.CombinedOutput()
 	if err != nil {
-		log.Err(internal.Err(string(stdout)), "failed to run dpkg-deb on %s", tmpDir)
+		log.Err(internal.Err("%s", string(stdout)), "failed to run dpkg-deb on %s", tmpDir)
 		return false
 	}
 
@@ -79,10 +143,27 @@ func Build(dst io.Writer, record config.Record, log *internal.Log, system intern
 	return true
 }
 
-func filemap(system internal.System, filemaps map[string][]*config.FileMap, log *internal.Log, dst ext.Disk, api *ext.API) bool {
+// clamp dates every file and directory of the package to epoch, symlinks are
+// left to dpkg-deb as changing them would change their targets.
+func clamp(dir string, epoch time.Time) error {
+	return filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type()&os.ModeSymlink != 0 {
+			return nil
+		}
+		return os.Chtimes(path, epoch, epoch)
+	})
+}
+
+func filemap(system internal.System, pkg string, filemaps map[string][]*config.FileMap, log *internal.Log, dst ext.Disk, api *ext.API) bool {
 	prev := log.Stage("build.filemaps")
 	defer prev()
-	for anchor, targets := range filemaps {
+	// anchors are gone through in order, so when two map the same file the
+	// same one wins every build
+	for _, anchor := range slices.Sorted(maps.Keys(filemaps)) {
+		targets := filemaps[anchor]
 		for _, filemap := range internal.Ranked(system, targets) {
 
 			path := api.Disk.Path("filemaps", filemap.ID)
@@ -92,11 +173,12 @@ func filemap(system internal.System, filemaps map[string][]*config.FileMap, log
 				return false
 			}
 
-			anchorPath, err := api.Host.ResolveAnchor(anchor)
+			resolved, err := api.Host.ResolveAnchor(anchor)
 			if err != nil {
 				log.Err(err, "filemap '%s' has unknown anchor '%s'", filemap.ID, anchor)
 				return false
 			}
+			anchorPath := installPath(resolved, pkg)
 
 			for _, file := range files {
 				dstPath := dst.Path(anchorPath, string(file))
@@ -111,6 +193,15 @@ func filemap(system internal.System, filemaps map[string][]*config.FileMap, log
 					log.Info(8, "file '/%s' has already been mapped", string(file))
 					continue
 				}
+				head, err := api.Disk.ReadHead(srcPath, internal.LFSPointerMaxSize+1)
+				if err != nil {
+					log.Err(err, "failed to read mapfile '/%s' from '%s'", file, filemap.ID)
+					return false
+				}
+				if pointer, isPointer := internal.ParseLFSPointer(head); isPointer {
+					log.Err(nil, "mapfile '/%s' from '%s' is a git-lfs pointer to '%s', its content was never fetched", file, filemap.ID, pointer.OID)
+					return false
+				}
 				err = api.Disk.MoveFileTo(dst, dstPath, srcPath)
 				if err != nil {
 					log.Err(err, "failed to move mapfile '/%s' from '%s'", file, filemap.ID)
@@ -126,7 +217,7 @@ func filemap(system internal.System, filemaps map[string][]*config.FileMap, log
 	return true
 }
 
-func download(system internal.System, downloads map[string][]*config.Download, log *internal.Log, dst ext.Disk, api *ext.API) bool {
+func download(system internal.System, pkg string, downloads map[string][]*config.Download, log *internal.Log, dst ext.Disk, api *ext.API) bool {
 	prev := log.Stage("build.download")
 	defer prev()
 	if len(downloads) == 0 {
@@ -138,11 +229,12 @@ func download(system internal.System, downloads map[string][]*config.Download, l
 			continue
 		}
 		file := tgt.Destination
-		anchorPath, err := api.Host.ResolveAnchor(file.Host)
+		resolved, err := api.Host.ResolveAnchor(file.Host)
 		if err != nil {
 			log.Err(err, "download for taret '%s' has unknown anchor '%s'", tgt.ID, file.Host)
 			return false
 		}
+		anchorPath := installPath(resolved, pkg)
 
 		dstPath := dst.Path(anchorPath, file.Path)
 		data, err := api.Http.Fetch(tgt.Source)
@@ -0,0 +1,35 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+type DoNothingLogger struct {
+}
+
+func (log *DoNothingLogger) Log(stmt *internal.LogStatement) {
+
+}
+
+func TestFilemapRejectsLFSPointer(t *testing.T) {
+	src := t.TempDir()
+	path := filepath.Join(src, "filemaps", "root.all", "usr", "share", "foo", "logo.png")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	pointer := "version https://git-lfs.github.com/spec/v1\noid sha256:4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393\nsize 12345\n"
+	os.WriteFile(path, []byte(pointer), 0644)
+
+	filemaps := map[string][]*config.FileMap{
+		"root": {{ID: "root.all", Anchor: "root", Target: internal.Target{Name: "all", All: true}}},
+	}
+	dst := ext.NewDisk(t.TempDir())
+	ok := filemap(internal.System{Architecture: internal.AMD64}, "foo", filemaps, internal.NewLog(&DoNothingLogger{}), dst, ext.NewAPI(src))
+	if ok {
+		t.Fatal("expected filemap with lfs pointer to fail")
+	}
+}
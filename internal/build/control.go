@@ -8,14 +8,31 @@ import (
 	"github.com/woolawin/catalogue/internal/ext"
 )
 
-func control(record config.Record, log *internal.Log, dst ext.Disk) bool {
+// control writes the control file, depends are what the package needs on top
+// of the dependencies of its metadata.
+func control(record config.Record, depends []string, log *internal.Log, dst ext.Disk) bool {
 	controlFile := dst.Path("DEBIAN", "control")
-	data := make(map[string]string)
-	data = copyMetadata(data, record.Metadata)
-	data["Package"] = record.Name
-	data["Version"] = record.LatestPin.VersionName
-	data["Priority"] = "optional"
-	data["Section"] = "utils"
+
+	dependencies := record.Metadata.Dependencies
+	for _, depend := range depends {
+		if len(dependencies) != 0 {
+			dependencies += ", "
+		}
+		dependencies += depend
+	}
+
+	// fields go in the order of Debian policy
+	data := internal.Paragraph{
+		{Name: "Package", Value: record.Name},
+		{Name: "Version", Value: record.LatestPin.VersionName},
+		{Name: "Architecture", Value: record.Metadata.Architecture},
+		{Name: "Maintainer", Value: record.Metadata.Maintainer},
+		{Name: "Depends", Value: dependencies},
+		{Name: "Section", Value: "utils"},
+		{Name: "Priority", Value: "optional"},
+		{Name: "Homepage", Value: record.Metadata.Homepage},
+		{Name: "Description", Value: record.Metadata.Description},
+	}
 
 	contents := internal.SerializeDebParagraph(data)
 
@@ -26,31 +43,3 @@ func control(record config.Record, log *internal.Log, dst ext.Disk) bool {
 	}
 	return err == nil
 }
-
-func copyMetadata(data map[string]string, metadata config.Metadata) map[string]string {
-	if len(data["Depends"]) == 0 {
-		data["Depends"] = metadata.Dependencies
-	}
-
-	if len(data["Section"]) == 0 {
-		data["Section"] = metadata.Category
-	}
-
-	if len(data["Homepage"]) == 0 {
-		data["Homepage"] = metadata.Homepage
-	}
-
-	if len(data["Maintainer"]) == 0 {
-		data["Maintainer"] = metadata.Maintainer
-	}
-
-	if len(data["Description"]) == 0 {
-		data["Description"] = metadata.Description
-	}
-
-	if len(data["Architecture"]) == 0 {
-		data["Architecture"] = metadata.Architecture
-	}
-
-	return data
-}
@@ -0,0 +1,83 @@
+package build
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// depends checks the build dependencies of a component are installed before
+// building it, they are installed with apt-get when opts allow it.
+func depends(spec *config.Build, opts Opts, log *internal.Log, api *ext.API) bool {
+	prev := log.Stage("build.depends")
+	defer prev()
+	if spec == nil || len(spec.Depends) == 0 {
+		return true
+	}
+
+	relations, err := internal.ParseDebRelations(spec.Depends)
+	if err != nil {
+		log.Err(err, "invalid build dependencies")
+		return false
+	}
+	status, err := api.Host.GetDpkgStatus()
+	if err != nil {
+		log.Err(err, "failed to read installed packages")
+		return false
+	}
+	missing := status.Missing(relations)
+	if len(missing) == 0 {
+		log.Info(9, "build dependencies are installed")
+		return true
+	}
+
+	var packages []string
+	for _, alternatives := range missing {
+		packages = append(packages, alternatives[0].Name)
+	}
+	if !opts.installBuildDeps {
+		log.Err(nil, "missing build dependencies '%s', install them with 'apt-get install %s' or retry with --install-build-deps", formatRelations(missing), strings.Join(packages, " "))
+		return false
+	}
+
+	log.Info(8, "installing build dependencies '%s'", formatRelations(missing))
+	cmd := exec.Command("apt-get", append([]string{"install", "--yes", "--no-install-recommends"}, packages...)...)
+	cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive")
+	output := &logWriter{log: log}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err = cmd.Run()
+	output.flush()
+	if err != nil {
+		log.Err(err, "failed to install build dependencies '%s'", strings.Join(packages, " "))
+		return false
+	}
+
+	status, err = api.Host.GetDpkgStatus()
+	if err != nil {
+		log.Err(err, "failed to read installed packages")
+		return false
+	}
+	missing = status.Missing(relations)
+	if len(missing) != 0 {
+		log.Err(nil, "build dependencies '%s' are still not met after installing them", formatRelations(missing))
+		return false
+	}
+	return true
+}
+
+func formatRelations(relations [][]internal.DebDependency) string {
+	var formatted []string
+	for _, alternatives := range relations {
+		var names []string
+		for _, alternative := range alternatives {
+			names = append(names, alternative.String())
+		}
+		formatted = append(formatted, strings.Join(names, " | "))
+	}
+	return strings.Join(formatted, ", ")
+}
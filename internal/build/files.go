@@ -0,0 +1,131 @@
+package build
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// files applies the mode declared in config.toml and creates its symlinks.
+// Symlinks are created last so nothing is ever written through one. The
+// package itself is owned by root, the owner and group are set by the
+// postinst so their names are resolved on the host it is installed on.
+func files(pkg string, declared []*config.File, scripts *maintainerScripts, log *internal.Log, dst ext.Disk) bool {
+	prev := log.Stage("build.files")
+	defer prev()
+
+	var owned []*config.File
+
+	for _, file := range declared {
+		if len(file.Symlink) != 0 {
+			continue
+		}
+		path := dst.Path(filePath(pkg, file.Path))
+		exists, _, err := dst.FileExists(path)
+		if err != nil {
+			log.Err(err, "failed to check if file '%s' exists", file.Path)
+			return false
+		}
+		if !exists {
+			log.Err(nil, "file '%s' declared in config.toml is not in the package", file.Path)
+			return false
+		}
+		if file.Mode != 0 {
+			err = dst.Chmod(path, file.Mode)
+			if err != nil {
+				log.Err(err, "failed to set mode of file '%s'", file.Path)
+				return false
+			}
+			log.Info(8, "set mode of file '%s' to %s", file.Path, file.Mode)
+		}
+		if len(file.Owner) != 0 || len(file.Group) != 0 {
+			owned = appendOwned(owned, file, log)
+		}
+	}
+
+	for _, file := range declared {
+		if len(file.Symlink) == 0 {
+			continue
+		}
+		path := dst.Path(filePath(pkg, file.Path))
+		err := dst.Symlink(path, file.Symlink)
+		if err != nil {
+			log.Err(err, "failed to create symlink '%s'", file.Path)
+			return false
+		}
+		if len(file.Owner) != 0 || len(file.Group) != 0 {
+			owned = appendOwned(owned, file, log)
+		}
+		log.Info(8, "created symlink '%s' -> '%s'", file.Path, file.Symlink)
+	}
+
+	if len(owned) != 0 {
+		scripts.postinst = append(scripts.postinst, ownershipScript(pkg, owned))
+	}
+	return true
+}
+
+// appendOwned adds a file the postinst sets the owner of. Home files are left
+// out, they belong to whoever they are copied to.
+func appendOwned(owned []*config.File, file *config.File, log *internal.Log) []*config.File {
+	if strings.HasPrefix(file.Path, "~/") {
+		log.Info(8, "home file '%s' is owned by the user it is copied to", file.Path)
+		return owned
+	}
+	return append(owned, file)
+}
+
+// ownershipScript sets the owner and group of files. The mode is set again
+// after, chown clears the setuid and setgid bits.
+func ownershipScript(pkg string, owned []*config.File) string {
+	script := strings.Builder{}
+	script.WriteString("# owner and group of files declared in config.toml\n")
+	script.WriteString("if [ \"$1\" = \"configure\" ]; then\n")
+	for _, file := range owned {
+		owner := file.Owner
+		if len(file.Group) != 0 {
+			owner += ":" + file.Group
+		}
+		path := shellQuote(filePath(pkg, file.Path))
+		fmt.Fprintf(&script, "\tchown -h %s %s\n", shellQuote(owner), path)
+		if file.Mode != 0 {
+			fmt.Fprintf(&script, "\tchmod %s %s\n", octalMode(file.Mode), path)
+		}
+	}
+	script.WriteString("fi\n")
+	return script.String()
+}
+
+// octalMode is mode the way chmod takes it.
+func octalMode(mode os.FileMode) string {
+	value := uint32(mode.Perm())
+	if mode&os.ModeSetuid != 0 {
+		value |= 0o4000
+	}
+	if mode&os.ModeSetgid != 0 {
+		value |= 0o2000
+	}
+	if mode&os.ModeSticky != 0 {
+		value |= 0o1000
+	}
+	return fmt.Sprintf("%04o", value)
+}
+
+// shellQuote quotes value as a single sh word.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// filePath maps a path declared in config.toml to where it is staged, paths
+// starting with '~/' are relative to the home anchor.
+func filePath(pkg string, path string) string {
+	relative, isHome := strings.CutPrefix(path, "~/")
+	if !isHome {
+		return path
+	}
+	return installPath(ext.Anchor{Name: "home", Path: relative, Home: true}, pkg)
+}
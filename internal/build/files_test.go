@@ -0,0 +1,117 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+func TestFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		declared []*config.File
+		ok       bool
+		mode     os.FileMode
+		symlink  string
+		postinst []string
+	}{
+		{
+			name:     "mode",
+			declared: []*config.File{{Path: "/usr/bin/foo", Mode: 0750}},
+			ok:       true,
+			mode:     0750,
+		},
+		{
+			name:     "owner_and_group",
+			declared: []*config.File{{Path: "/usr/bin/foo", Mode: 0755 | os.ModeSetuid, Owner: "foo", Group: "staff"}},
+			ok:       true,
+			mode:     0755 | os.ModeSetuid,
+			postinst: []string{"\tchown -h 'foo:staff' '/usr/bin/foo'\n\tchmod 4755 '/usr/bin/foo'\n"},
+		},
+		{
+			name:     "group",
+			declared: []*config.File{{Path: "/usr/bin/foo", Group: "staff"}},
+			ok:       true,
+			mode:     0644,
+			postinst: []string{"\tchown -h ':staff' '/usr/bin/foo'\n"},
+		},
+		{
+			name:     "symlink",
+			declared: []*config.File{{Path: "/usr/bin/bar", Symlink: "/usr/bin/foo", Owner: "foo"}},
+			ok:       true,
+			mode:     0644,
+			symlink:  "/usr/bin/foo",
+			postinst: []string{"\tchown -h 'foo' '/usr/bin/bar'\n"},
+		},
+		{
+			name:     "home_owner",
+			declared: []*config.File{{Path: "~/.foorc", Owner: "foo"}},
+			ok:       true,
+			mode:     0644,
+		},
+		{
+			name:     "missing",
+			declared: []*config.File{{Path: "/usr/bin/missing", Mode: 0755}},
+			ok:       false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dst := ext.NewDisk(t.TempDir())
+			for _, path := range []string{"/usr/bin/foo", filepath.Join(SkelBase, "foo", ".foorc")} {
+				err := dst.WriteFile(dst.Path(path), strings.NewReader("foo"))
+				if err != nil {
+					t.Fatal(err)
+				}
+				os.Chmod(string(dst.Path(path)), 0644)
+			}
+
+			scripts := maintainerScripts{}
+			ok := files("foo", test.declared, &scripts, internal.NewLog(&DoNothingLogger{}), dst)
+			if ok != test.ok {
+				t.Fatalf("expected files to return %t", test.ok)
+			}
+			if !ok {
+				return
+			}
+
+			file := test.declared[0]
+			path := string(dst.Path(filePath("foo", file.Path)))
+			if len(test.symlink) != 0 {
+				target, err := os.Readlink(path)
+				if err != nil || target != test.symlink {
+					t.Fatalf("expected symlink to '%s', got '%s'", test.symlink, target)
+				}
+			} else {
+				info, err := os.Stat(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode()&(os.ModePerm|os.ModeSetuid) != test.mode {
+					t.Fatalf("expected mode %s, got %s", test.mode, info.Mode())
+				}
+			}
+
+			if len(test.postinst) == 0 {
+				if len(scripts.postinst) != 0 {
+					t.Fatalf("expected no postinst, got:\n%s", scripts.postinst[0])
+				}
+				return
+			}
+			if len(scripts.postinst) != 1 {
+				t.Fatal("expected the postinst to set the owner")
+			}
+			for _, line := range test.postinst {
+				if !strings.Contains(scripts.postinst[0], line) {
+					t.Fatalf("expected postinst to have '%s', got:\n%s", line, scripts.postinst[0])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package build
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// Files for home anchors are not installed into any one user's home, they are
+// staged here and copied into each user's home by the package's postinst.
+const SkelBase = "/usr/share/catalogue/skel"
+
+func installPath(anchor ext.Anchor, pkg string) string {
+	if anchor.Home {
+		return filepath.Join(SkelBase, pkg, anchor.Path)
+	}
+	return anchor.Path
+}
+
+func home(pkg string, scripts *maintainerScripts, log *internal.Log, dst ext.Disk, api *ext.API) bool {
+	exists, _, err := dst.DirExists(dst.Path(SkelBase, pkg))
+	if err != nil {
+		log.Err(err, "failed to check for staged home files")
+		return false
+	}
+	if !exists {
+		return true
+	}
+
+	config, err := api.Host.GetConfig()
+	if err != nil {
+		log.Err(err, "failed to get config for home files")
+		return false
+	}
+
+	scripts.postinst = append(scripts.postinst, homeScript(pkg, config))
+	log.Info(8, "home files will be copied to users on install")
+	return true
+}
+
+// copySkel copies the staged files in $1 into the home $2, never overwriting
+// what is already there. It runs as the user owning the home, and still
+// leaves alone every path that goes through a symlink, which would let the
+// user have the files written anywhere.
+const copySkel = `skel="$1"
+home="$2"
+[ -d "$home" ] && [ ! -L "$home" ] || exit 0
+linked() {
+	current="$home"
+	rest="$1"
+	while [ -n "$rest" ]; do
+		part="${rest%%/*}"
+		if [ "$part" = "$rest" ]; then rest=""; else rest="${rest#*/}"; fi
+		current="$current/$part"
+		[ -L "$current" ] && return 0
+	done
+	return 1
+}
+cd "$skel" || exit 0
+find . -mindepth 1 -type d | while IFS= read -r dir; do
+	dir="${dir#./}"
+	linked "$dir" && continue
+	[ -d "$home/$dir" ] && continue
+	mkdir "$home/$dir"
+done
+find . ! -type d | while IFS= read -r file; do
+	file="${file#./}"
+	linked "$file" && continue
+	[ -e "$home/$file" ] && continue
+	cp -P --preserve=mode,timestamps "$file" "$home/$file"
+done
+`
+
+func homeScript(pkg string, config internal.Config) string {
+	script := strings.Builder{}
+	script.WriteString("# copy staged home files into user homes, never overwriting existing files\n")
+	script.WriteString("if [ \"$1\" = \"configure\" ]; then\n")
+	fmt.Fprintf(&script, "\tskel='%s'\n", filepath.Join(SkelBase, pkg))
+	script.WriteString("\tcopy_skel=$(cat <<'EOF'\n" + copySkel + "EOF\n)\n")
+	script.WriteString(`	copy_user() {
+		runuser -u "$1" -- sh -c "$copy_skel" sh "$skel" "$2" || echo "failed to copy home files to '$2'" >&2
+	}
+`)
+	if len(config.HomeUsers) == 0 {
+		script.WriteString(`	getent passwd | while IFS=: read -r user _ uid _ _ dir _; do
+		[ "$uid" -ge 1000 ] && [ "$uid" -lt 60000 ] || continue
+		copy_user "$user" "$dir"
+	done
+`)
+	} else {
+		var users []string
+		for _, user := range config.HomeUsers {
+			users = append(users, "'"+user+"'")
+		}
+		fmt.Fprintf(&script, "\tfor user in %s; do\n", strings.Join(users, " "))
+		script.WriteString(`		dir="$(getent passwd "$user" | cut -d: -f6)"
+		[ -n "$dir" ] || continue
+		copy_user "$user" "$dir"
+	done
+`)
+	}
+	if config.HomeSkel {
+		script.WriteString("\tsh -c \"$copy_skel\" sh \"$skel\" /etc/skel\n")
+	}
+	script.WriteString("fi\n")
+	return script.String()
+}
@@ -0,0 +1,93 @@
+package build
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+func TestInstallPath(t *testing.T) {
+	actual := installPath(ext.Anchor{Name: "xdg_config", Path: ".config", Home: true}, "foo")
+	if actual != "/usr/share/catalogue/skel/foo/.config" {
+		t.Fatalf("'%s' not correct", actual)
+	}
+
+	actual = installPath(ext.Anchor{Name: "etc", Path: "/etc"}, "foo")
+	if actual != "/etc" {
+		t.Fatalf("'%s' not correct", actual)
+	}
+}
+
+func TestHomeScript(t *testing.T) {
+	t.Run("all_users", func(t *testing.T) {
+		script := homeScript("foo", internal.Config{})
+		if !strings.Contains(script, "skel='/usr/share/catalogue/skel/foo'") {
+			t.Fatal("expected script to copy from package skel directory")
+		}
+		if !strings.Contains(script, "getent passwd | while") {
+			t.Fatal("expected script to copy to all users")
+		}
+		if !strings.Contains(script, `runuser -u "$1" --`) {
+			t.Fatal("expected script to copy as the user owning the home")
+		}
+		out, err := exec.Command("sh", "-n", "-c", script).CombinedOutput()
+		if err != nil {
+			t.Fatalf("script is not valid sh: %s", out)
+		}
+		if strings.Contains(script, "/etc/skel") {
+			t.Fatal("expected script NOT to copy to /etc/skel")
+		}
+	})
+
+	t.Run("listed_users", func(t *testing.T) {
+		script := homeScript("foo", internal.Config{HomeUsers: []string{"alice", "bob"}, HomeSkel: true})
+		if !strings.Contains(script, "for user in 'alice' 'bob'; do") {
+			t.Fatal("expected script to copy to listed users")
+		}
+		if !strings.Contains(script, "sh -c \"$copy_skel\" sh \"$skel\" /etc/skel") {
+			t.Fatal("expected script to copy to /etc/skel")
+		}
+	})
+}
+
+// A symlink the user planted in their home must not let the copy write
+// through it.
+func TestCopySkelLeavesSymlinksAlone(t *testing.T) {
+	skel := t.TempDir()
+	os.MkdirAll(filepath.Join(skel, ".config", "foo"), 0755)
+	os.WriteFile(filepath.Join(skel, ".config", "foo", "settings"), []byte("settings"), 0644)
+	os.WriteFile(filepath.Join(skel, ".foorc"), []byte("rc"), 0644)
+	os.WriteFile(filepath.Join(skel, ".bashrc"), []byte("bashrc"), 0644)
+
+	home := t.TempDir()
+	outside := t.TempDir()
+	os.Mkdir(filepath.Join(home, ".config"), 0755)
+	os.Symlink(outside, filepath.Join(home, ".config", "foo"))
+	os.Symlink(filepath.Join(outside, "bashrc"), filepath.Join(home, ".bashrc"))
+
+	out, err := exec.Command("sh", "-c", copySkel, "sh", skel, home).CombinedOutput()
+	if err != nil {
+		t.Fatalf("copy failed: %s", out)
+	}
+
+	entries, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected nothing to be written through the symlinks, found '%s'", entries[0].Name())
+	}
+	target, err := os.Readlink(filepath.Join(home, ".config", "foo"))
+	if err != nil || target != outside {
+		t.Fatal("expected the planted symlink to be left alone")
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".foorc"))
+	if err != nil || string(data) != "rc" {
+		t.Fatal("expected files outside the symlinks to be copied")
+	}
+}
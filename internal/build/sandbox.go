@@ -0,0 +1,179 @@
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// sandboxID is the user and group builds run as when the daemon runs as root.
+const sandboxID = 65534
+
+const sandboxPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// sandboxDir is where the work directory of a build is mounted within its
+// sandbox, the tree is at source and what gets packaged goes into stage.
+const sandboxDir = "/tmp/build"
+
+// source runs the build commands of a component in a sandbox and collects
+// what they installed into $STAGE into the package.
+func source(spec *config.Build, tree string, epoch time.Time, log *internal.Log, dst ext.Disk, api *ext.API) bool {
+	prev := log.Stage("build.source")
+	defer prev()
+	if spec == nil {
+		return true
+	}
+
+	work := api.Host.RandomTmpDir()
+	stage := filepath.Join(work, "stage")
+	err := os.MkdirAll(stage, 0755)
+	if err != nil {
+		log.Err(err, "failed to create stage directory '%s'", stage)
+		return false
+	}
+	defer os.RemoveAll(work)
+
+	workTree := filepath.Join(work, "source")
+	err = os.Rename(tree, workTree)
+	if err != nil {
+		log.Err(err, "failed to move tree '%s' into the build directory", tree)
+		return false
+	}
+	defer os.Rename(workTree, tree)
+
+	owner, group := os.Geteuid(), os.Getegid()
+	builder, builderGroup := owner, group
+	if owner == 0 {
+		builder, builderGroup = sandboxID, sandboxID
+	}
+	err = chownAll(work, builder, builderGroup)
+	if err != nil {
+		log.Err(err, "failed to hand '%s' to the build sandbox", work)
+		return false
+	}
+	defer chownAll(workTree, owner, group)
+
+	for _, command := range spec.Commands {
+		log.Info(8, "running '%s'", command)
+		err = sandboxed(command, work, epoch, spec.Network, builder, builderGroup, log)
+		if err != nil {
+			log.Err(err, "build command '%s' failed", command)
+			return false
+		}
+	}
+
+	err = chownAll(stage, owner, group)
+	if err != nil {
+		log.Err(err, "failed to take back stage directory '%s'", stage)
+		return false
+	}
+
+	workDisk := ext.NewDisk(work)
+	files, err := workDisk.ListRec(workDisk.Path("stage"))
+	if err != nil {
+		log.Err(err, "failed to list stage directory")
+		return false
+	}
+	for _, file := range files {
+		err = workDisk.MoveFileTo(dst, dst.Path(string(file)), workDisk.Path("stage", string(file)))
+		if err != nil {
+			log.Err(err, "failed to collect '/%s' from stage directory", file)
+			return false
+		}
+		log.Info(8, "collected file '/%s'", file)
+	}
+	log.Info(9, "completed source build")
+	return true
+}
+
+// sandboxed runs command in new user, mount, pid, ipc and uts namespaces, and
+// a network namespace without any interfaces unless network is allowed. It is
+// root only within the namespace, outside it is the builder. Every command
+// gets an empty tmpfs on /tmp with only the work directory mounted into it.
+func sandboxed(command string, work string, epoch time.Time, network bool, builder int, builderGroup int, log *internal.Log) error {
+	// the work directory is hidden by the tmpfs once it is mounted, it is
+	// reached again through the working directory of the shell
+	script := "set -e\n" +
+		"mount -t tmpfs -o mode=1777 tmpfs /tmp\n" +
+		"mkdir " + sandboxDir + "\n" +
+		"mount --no-canonicalize --bind /proc/self/cwd " + sandboxDir + "\n" +
+		"cd \"$SOURCE\"\n" +
+		command + "\n"
+	cmd := exec.Command("/bin/sh", "-c", script)
+	cmd.Dir = work
+	cmd.Env = []string{
+		"PATH=" + sandboxPath,
+		"HOME=/tmp",
+		"TMPDIR=/tmp",
+		"SOURCE=" + sandboxDir + "/source",
+		"STAGE=" + sandboxDir + "/stage",
+		fmt.Sprintf("SOURCE_DATE_EPOCH=%d", epoch.Unix()),
+	}
+
+	flags := syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS | syscall.CLONE_NEWPID | syscall.CLONE_NEWIPC | syscall.CLONE_NEWUTS
+	if !network {
+		flags |= syscall.CLONE_NEWNET
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags:  uintptr(flags),
+		UidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: builder, Size: 1}},
+		GidMappings: []syscall.SysProcIDMap{{ContainerID: 0, HostID: builderGroup, Size: 1}},
+		// become the mapped root, the daemon itself is not mapped
+		Credential: &syscall.Credential{Uid: 0, Gid: 0, NoSetGroups: true},
+		Pdeathsig:  syscall.SIGKILL,
+	}
+
+	output := &logWriter{log: log}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	err := cmd.Run()
+	output.flush()
+	if err != nil {
+		return internal.ErrOf(err, "build sandbox failed")
+	}
+	return nil
+}
+
+func chownAll(dir string, uid int, gid int) error {
+	return filepath.WalkDir(dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Lchown(path, uid, gid)
+	})
+}
+
+// logWriter streams output line by line into the log, so a client sees a
+// build as it happens.
+type logWriter struct {
+	log     *internal.Log
+	pending []byte
+}
+
+func (writer *logWriter) Write(data []byte) (int, error) {
+	writer.pending = append(writer.pending, data...)
+	for {
+		line, rest, found := bytes.Cut(writer.pending, []byte("\n"))
+		if !found {
+			break
+		}
+		writer.log.Info(8, "%s", line)
+		writer.pending = rest
+	}
+	return len(data), nil
+}
+
+func (writer *logWriter) flush() {
+	if len(writer.pending) != 0 {
+		writer.log.Info(8, "%s", writer.pending)
+		writer.pending = nil
+	}
+}
@@ -0,0 +1,74 @@
+package build
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+type recordingLogger struct {
+	messages []string
+}
+
+func (log *recordingLogger) Log(stmt *internal.LogStatement) {
+	log.messages = append(log.messages, stmt.Message)
+}
+
+func TestSourceBuild(t *testing.T) {
+	if err := exec.Command("unshare", "--user", "--map-root-user", "--mount", "true").Run(); err != nil {
+		t.Skip("user namespaces are not available")
+	}
+
+	tree := t.TempDir()
+	// the sandbox builds as nobody, who must be able to reach the tree
+	os.Chmod(filepath.Dir(tree), 0755)
+	os.WriteFile(filepath.Join(tree, "hello.sh"), []byte("#!/bin/sh\necho hello\n"), 0755)
+
+	marker := filepath.Join(os.TempDir(), filepath.Base(filepath.Dir(tree))+".leak")
+	spec := &config.Build{Commands: []string{
+		"echo building",
+		"touch " + marker,
+		"mkdir -p $STAGE/usr/bin && cp hello.sh $STAGE/usr/bin/hello",
+	}}
+
+	logger := &recordingLogger{}
+	dst := ext.NewDisk(t.TempDir())
+	ok := source(spec, tree, time.Unix(0, 0), internal.NewLog(logger), dst, ext.NewAPI(tree))
+	if !ok {
+		t.Fatalf("expected source build to succeed: %s", strings.Join(logger.messages, "\n"))
+	}
+
+	info, err := os.Stat(string(dst.Path("usr", "bin", "hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Fatalf("expected executable to be collected, got %s", info.Mode())
+	}
+	if _, err := os.Stat(filepath.Join(tree, "hello.sh")); err != nil {
+		t.Fatal("expected tree to be put back after the build")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		os.Remove(marker)
+		t.Fatal("expected /tmp of the sandbox to be private")
+	}
+	found := false
+	for _, message := range logger.messages {
+		found = found || message == "building"
+	}
+	if !found {
+		t.Fatal("expected build output to be logged")
+	}
+
+	ok = source(&config.Build{Commands: []string{"exit 3"}}, tree, time.Unix(0, 0), internal.NewLog(logger), ext.NewDisk(t.TempDir()), ext.NewAPI(tree))
+	if ok {
+		t.Fatal("expected failing command to fail the build")
+	}
+}
@@ -0,0 +1,57 @@
+package build
+
+import (
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// maintainerScripts collects shell snippets that are assembled into the
+// package's DEBIAN maintainer scripts, and the packages they need.
+type maintainerScripts struct {
+	postinst []string
+	prerm    []string
+	postrm   []string
+	depends  []string
+}
+
+func (scripts *maintainerScripts) write(log *internal.Log, dst ext.Disk) bool {
+	ok := writeScript("postinst", scripts.postinst, log, dst)
+	if !ok {
+		return false
+	}
+	ok = writeScript("prerm", scripts.prerm, log, dst)
+	if !ok {
+		return false
+	}
+	return writeScript("postrm", scripts.postrm, log, dst)
+}
+
+func writeScript(name string, snippets []string, log *internal.Log, dst ext.Disk) bool {
+	if len(snippets) == 0 {
+		return true
+	}
+
+	contents := strings.Builder{}
+	contents.WriteString("#!/bin/sh\nset -e\n")
+	for _, snippet := range snippets {
+		contents.WriteString("\n")
+		contents.WriteString(snippet)
+	}
+	contents.WriteString("\nexit 0\n")
+
+	path := dst.Path("DEBIAN", name)
+	err := dst.WriteFile(path, strings.NewReader(contents.String()))
+	if err != nil {
+		log.Err(err, "failed to create maintainer script '%s'", name)
+		return false
+	}
+	err = dst.Chmod(path, 0755)
+	if err != nil {
+		log.Err(err, "failed to make maintainer script '%s' executable", name)
+		return false
+	}
+	log.Info(8, "generated maintainer script '%s'", name)
+	return true
+}
@@ -0,0 +1,144 @@
+package build
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// services generates the maintainer scripts deb-systemd-helper and
+// deb-systemd-invoke would for every unit under the systemd_system anchor.
+// initSystemHelpers has the deb-systemd-helper and deb-systemd-invoke the
+// service scripts call.
+const initSystemHelpers = "init-system-helpers (>= 1.54~)"
+
+func services(declared []*config.Service, scripts *maintainerScripts, log *internal.Log, dst ext.Disk, api *ext.API) bool {
+	prev := log.Stage("build.services")
+	defer prev()
+
+	anchor, err := api.Host.ResolveAnchor("systemd_system")
+	if err != nil {
+		log.Err(err, "failed to resolve systemd_system anchor")
+		return false
+	}
+
+	unitDir := dst.Path(anchor.Path)
+	exists, _, err := dst.DirExists(unitDir)
+	if err != nil {
+		log.Err(err, "failed to check for systemd units")
+		return false
+	}
+
+	var units []string
+	if exists {
+		files, _, err := dst.List(unitDir)
+		if err != nil {
+			log.Err(err, "failed to list systemd units")
+			return false
+		}
+		for _, file := range files {
+			if config.IsServiceUnit(string(file)) {
+				units = append(units, string(file))
+			}
+		}
+	}
+
+	var found []config.Service
+	for _, service := range declared {
+		if !slices.Contains(units, service.Unit) {
+			log.Err(nil, "service '%s' declared in config.toml is not in '%s'", service.Unit, anchor.Path)
+			return false
+		}
+		found = append(found, *service)
+	}
+
+	for _, unit := range units {
+		declared := slices.ContainsFunc(found, func(service config.Service) bool {
+			return service.Unit == unit
+		})
+		if !declared {
+			found = append(found, config.DefaultService(unit))
+		}
+	}
+
+	slices.SortFunc(found, func(a, b config.Service) int {
+		return strings.Compare(a.Unit, b.Unit)
+	})
+
+	if len(found) != 0 {
+		scripts.depends = append(scripts.depends, initSystemHelpers)
+	}
+	for _, service := range found {
+		postinst, prerm, postrm := serviceScripts(service)
+		scripts.postinst = append(scripts.postinst, postinst)
+		scripts.prerm = append(scripts.prerm, prerm)
+		scripts.postrm = append(scripts.postrm, postrm)
+		log.Info(8, "added systemd unit '%s' enable=%t start=%t restart_on_upgrade=%t", service.Unit, service.Enable, service.Start, service.Restart)
+	}
+
+	return true
+}
+
+func serviceScripts(service config.Service) (string, string, string) {
+	configure := `if [ "$1" = "configure" ] || [ "$1" = "abort-upgrade" ] || [ "$1" = "abort-deconfigure" ] || [ "$1" = "abort-remove" ]; then`
+
+	postinst := strings.Builder{}
+	fmt.Fprintf(&postinst, "# systemd unit '%s'\n", service.Unit)
+	postinst.WriteString(configure + "\n")
+	fmt.Fprintf(&postinst, "\tdeb-systemd-helper unmask '%s' >/dev/null || true\n", service.Unit)
+	if service.Enable {
+		fmt.Fprintf(&postinst, "\tif deb-systemd-helper --quiet was-enabled '%s'; then\n", service.Unit)
+		fmt.Fprintf(&postinst, "\t\tdeb-systemd-helper enable '%s' >/dev/null || true\n", service.Unit)
+		postinst.WriteString("\telse\n")
+		fmt.Fprintf(&postinst, "\t\tdeb-systemd-helper update-state '%s' >/dev/null || true\n", service.Unit)
+		postinst.WriteString("\tfi\n")
+	} else {
+		fmt.Fprintf(&postinst, "\tdeb-systemd-helper update-state '%s' >/dev/null || true\n", service.Unit)
+	}
+	postinst.WriteString("\tif [ -d /run/systemd/system ]; then\n")
+	postinst.WriteString("\t\tsystemctl --system daemon-reload >/dev/null || true\n")
+	if service.Start && service.Restart {
+		postinst.WriteString("\t\tif [ -n \"$2\" ]; then\n")
+		fmt.Fprintf(&postinst, "\t\t\tdeb-systemd-invoke restart '%s' >/dev/null || true\n", service.Unit)
+		postinst.WriteString("\t\telse\n")
+		fmt.Fprintf(&postinst, "\t\t\tdeb-systemd-invoke start '%s' >/dev/null || true\n", service.Unit)
+		postinst.WriteString("\t\tfi\n")
+	} else if service.Start {
+		postinst.WriteString("\t\tif [ -z \"$2\" ]; then\n")
+		fmt.Fprintf(&postinst, "\t\t\tdeb-systemd-invoke start '%s' >/dev/null || true\n", service.Unit)
+		postinst.WriteString("\t\tfi\n")
+	} else if service.Restart {
+		postinst.WriteString("\t\tif [ -n \"$2\" ]; then\n")
+		fmt.Fprintf(&postinst, "\t\t\tdeb-systemd-invoke try-restart '%s' >/dev/null || true\n", service.Unit)
+		postinst.WriteString("\t\tfi\n")
+	}
+	postinst.WriteString("\tfi\n")
+	postinst.WriteString("fi\n")
+
+	prerm := strings.Builder{}
+	fmt.Fprintf(&prerm, "# systemd unit '%s'\n", service.Unit)
+	prerm.WriteString("if [ -z \"${DPKG_ROOT:-}\" ] && [ \"$1\" = remove ] && [ -d /run/systemd/system ]; then\n")
+	fmt.Fprintf(&prerm, "\tdeb-systemd-invoke stop '%s' >/dev/null || true\n", service.Unit)
+	prerm.WriteString("fi\n")
+
+	postrm := strings.Builder{}
+	fmt.Fprintf(&postrm, "# systemd unit '%s'\n", service.Unit)
+	postrm.WriteString("if [ \"$1\" = remove ] && [ -d /run/systemd/system ]; then\n")
+	postrm.WriteString("\tsystemctl --system daemon-reload >/dev/null || true\n")
+	postrm.WriteString("fi\n")
+	postrm.WriteString("if [ -x /usr/bin/deb-systemd-helper ]; then\n")
+	postrm.WriteString("\tif [ \"$1\" = remove ]; then\n")
+	fmt.Fprintf(&postrm, "\t\tdeb-systemd-helper mask '%s' >/dev/null || true\n", service.Unit)
+	postrm.WriteString("\tfi\n")
+	postrm.WriteString("\tif [ \"$1\" = purge ]; then\n")
+	fmt.Fprintf(&postrm, "\t\tdeb-systemd-helper purge '%s' >/dev/null || true\n", service.Unit)
+	fmt.Fprintf(&postrm, "\t\tdeb-systemd-helper unmask '%s' >/dev/null || true\n", service.Unit)
+	postrm.WriteString("\tfi\n")
+	postrm.WriteString("fi\n")
+
+	return postinst.String(), prerm.String(), postrm.String()
+}
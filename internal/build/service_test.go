@@ -0,0 +1,74 @@
+package build
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+func TestServiceScripts(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		postinst, prerm, postrm := serviceScripts(config.DefaultService("foo.service"))
+		if !strings.Contains(postinst, "deb-systemd-helper enable 'foo.service'") {
+			t.Fatal("expected postinst to enable unit")
+		}
+		if !strings.Contains(postinst, "deb-systemd-invoke restart 'foo.service'") {
+			t.Fatal("expected postinst to restart unit on upgrade")
+		}
+		if !strings.Contains(postinst, "deb-systemd-invoke start 'foo.service'") {
+			t.Fatal("expected postinst to start unit")
+		}
+		if !strings.Contains(prerm, "deb-systemd-invoke stop 'foo.service'") {
+			t.Fatal("expected prerm to stop unit")
+		}
+		if !strings.Contains(postrm, "deb-systemd-helper purge 'foo.service'") {
+			t.Fatal("expected postrm to purge unit")
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		postinst, prerm, _ := serviceScripts(config.Service{Unit: "foo.timer"})
+		if strings.Contains(postinst, "deb-systemd-helper enable") {
+			t.Fatal("expected postinst NOT to enable unit")
+		}
+		if strings.Contains(postinst, "deb-systemd-invoke") {
+			t.Fatal("expected postinst NOT to start or restart unit")
+		}
+		if !strings.Contains(prerm, "deb-systemd-invoke stop 'foo.timer'") {
+			t.Fatal("expected prerm to still stop unit")
+		}
+	})
+}
+
+func TestServicesDependOnInitSystemHelpers(t *testing.T) {
+	log := internal.NewLog(&DoNothingLogger{})
+	dst := ext.NewDisk(t.TempDir())
+	err := dst.WriteFile(dst.Path("lib", "systemd", "system", "foo.service"), strings.NewReader("[Service]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scripts := maintainerScripts{}
+	ok := services(nil, &scripts, log, dst, ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected services to succeed")
+	}
+	record := config.Record{Name: "foo"}
+	record.Metadata.Dependencies = "bar (>= 1.0)"
+	ok = control(record, scripts.depends, log, dst)
+	if !ok {
+		t.Fatal("expected control to succeed")
+	}
+
+	data, err := os.ReadFile(string(dst.Path("DEBIAN", "control")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "Depends: bar (>= 1.0), init-system-helpers (>= 1.54~)\n") {
+		t.Fatalf("expected control to depend on init-system-helpers, got:\n%s", data)
+	}
+}
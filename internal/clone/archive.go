@@ -0,0 +1,209 @@
+package clone
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	xzlib "github.com/ulikunitz/xz"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+type archiveEntry struct {
+	name    string
+	mode    os.FileMode
+	dir     bool
+	symlink string
+	open    func() (io.Reader, error)
+}
+
+// unpack writes the entries of a tar (plain, gzip or xz) or zip archive that
+// are under subpath into dst. A single top level directory, as release
+// tarballs usually have, is stripped first.
+func unpack(data []byte, subpath string, dst ext.Disk) error {
+	entries, err := readArchive(data)
+	if err != nil {
+		return err
+	}
+
+	subpath = strings.Trim(path.Clean("/"+subpath), "/")
+	strip := commonRoot(entries, subpath)
+
+	// symlinks are created last so no file is ever written through one
+	var symlinks []archiveEntry
+	written := 0
+	for _, entry := range entries {
+		name := strings.TrimPrefix(path.Clean("/"+entry.name), "/")
+		if len(strip) != 0 {
+			name = strings.TrimPrefix(strings.TrimPrefix(name, strip), "/")
+		}
+		if len(name) == 0 || entry.dir {
+			continue
+		}
+		if len(subpath) != 0 && name != subpath && !strings.HasPrefix(name, subpath+"/") {
+			continue
+		}
+
+		if len(entry.symlink) != 0 {
+			entry.name = name
+			symlinks = append(symlinks, entry)
+			continue
+		}
+
+		filePath := dst.Path(name)
+
+		reader, err := entry.open()
+		if err != nil {
+			return internal.ErrOf(err, "can not read archive entry '%s'", entry.name)
+		}
+		err = dst.WriteFile(filePath, reader)
+		if err != nil {
+			return err
+		}
+		err = dst.Chmod(filePath, entry.mode.Perm())
+		if err != nil {
+			return err
+		}
+		written++
+	}
+
+	for _, entry := range symlinks {
+		err = dst.Symlink(dst.Path(entry.name), entry.symlink)
+		if err != nil {
+			return err
+		}
+		written++
+	}
+
+	if written == 0 {
+		return internal.Err("archive has no files under '%s'", subpath)
+	}
+	return nil
+}
+
+func readArchive(data []byte) ([]archiveEntry, error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return readZip(data)
+	}
+
+	var reader io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, internal.ErrOf(err, "invalid gzip archive")
+		}
+		defer gz.Close()
+		reader = gz
+	} else if bytes.HasPrefix(data, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}) {
+		xz, err := xzlib.NewReader(reader)
+		if err != nil {
+			return nil, internal.ErrOf(err, "invalid xz archive")
+		}
+		reader = xz
+	}
+	return readTar(reader)
+}
+
+func readTar(src io.Reader) ([]archiveEntry, error) {
+	var entries []archiveEntry
+	reader := tar.NewReader(src)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, internal.ErrOf(err, "invalid tar archive")
+		}
+
+		entry := archiveEntry{name: header.Name, mode: os.FileMode(header.Mode)}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			entry.dir = true
+		case tar.TypeSymlink:
+			entry.symlink = header.Linkname
+		case tar.TypeReg:
+			contents, err := io.ReadAll(reader)
+			if err != nil {
+				return nil, internal.ErrOf(err, "can not read tar entry '%s'", header.Name)
+			}
+			entry.open = func() (io.Reader, error) {
+				return bytes.NewReader(contents), nil
+			}
+		default:
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func readZip(data []byte) ([]archiveEntry, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, internal.ErrOf(err, "invalid zip archive")
+	}
+
+	var entries []archiveEntry
+	for _, file := range reader.File {
+		entry := archiveEntry{name: file.Name, mode: file.Mode(), dir: file.FileInfo().IsDir()}
+		if file.Mode()&os.ModeSymlink != 0 {
+			link, err := file.Open()
+			if err != nil {
+				return nil, internal.ErrOf(err, "can not read zip entry '%s'", file.Name)
+			}
+			target, err := io.ReadAll(link)
+			link.Close()
+			if err != nil {
+				return nil, internal.ErrOf(err, "can not read zip entry '%s'", file.Name)
+			}
+			entry.symlink = string(target)
+		} else if !entry.dir {
+			entry.open = func() (io.Reader, error) {
+				src, err := file.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer src.Close()
+				contents, err := io.ReadAll(src)
+				if err != nil {
+					return nil, err
+				}
+				return bytes.NewReader(contents), nil
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// commonRoot returns the top level directory every entry is in, if there is
+// one and it is not subpath itself.
+func commonRoot(entries []archiveEntry, subpath string) string {
+	root := ""
+	for _, entry := range entries {
+		name := strings.TrimPrefix(path.Clean("/"+entry.name), "/")
+		if len(subpath) != 0 && (name == subpath || strings.HasPrefix(name, subpath+"/")) {
+			return ""
+		}
+		first, rest, nested := strings.Cut(name, "/")
+		if !nested && !entry.dir {
+			return ""
+		}
+		if len(rest) == 0 && !entry.dir {
+			return ""
+		}
+		if len(root) == 0 {
+			root = first
+		} else if root != first {
+			return ""
+		}
+	}
+	return root
+}
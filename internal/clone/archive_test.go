@@ -0,0 +1,123 @@
+package clone
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+func TestUnpackTarGz(t *testing.T) {
+	var buffer bytes.Buffer
+	gz := gzip.NewWriter(&buffer)
+	writer := tar.NewWriter(gz)
+	files := map[string]string{
+		"foo-1.2.3/README.md":                          "readme",
+		"foo-1.2.3/.catalogue/config.toml":             "name='foo'",
+		"foo-1.2.3/.catalogue/filemaps/root.all/bin/x": "#!/bin/sh",
+	}
+	for name, contents := range files {
+		mode := int64(0644)
+		if filepath.Base(name) == "x" {
+			mode = 0755
+		}
+		writer.WriteHeader(&tar.Header{Name: name, Mode: mode, Size: int64(len(contents)), Typeflag: tar.TypeReg})
+		writer.Write([]byte(contents))
+	}
+	writer.Close()
+	gz.Close()
+
+	dir := t.TempDir()
+	err := unpack(buffer.Bytes(), ".catalogue", ext.NewDisk(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ".catalogue", "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "name='foo'" {
+		t.Fatalf("unexpected config.toml '%s'", data)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, ".catalogue", "filemaps", "root.all", "bin", "x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Fatalf("expected mode 0755, got %s", info.Mode())
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "README.md"))
+	if !os.IsNotExist(err) {
+		t.Fatal("expected README.md NOT to be unpacked")
+	}
+}
+
+func TestUnpackZip(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	file, _ := writer.Create(".catalogue/config.toml")
+	file.Write([]byte("name='foo'"))
+	writer.Close()
+
+	dir := t.TempDir()
+	err := unpack(buffer.Bytes(), ".catalogue", ext.NewDisk(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = os.Stat(filepath.Join(dir, ".catalogue", "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnpackBlocksEscape(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := tar.NewWriter(&buffer)
+	writer.WriteHeader(&tar.Header{Name: "../../.catalogue/x", Mode: 0644, Size: 1, Typeflag: tar.TypeReg})
+	writer.Write([]byte("x"))
+	writer.Close()
+
+	dir := t.TempDir()
+	unpack(buffer.Bytes(), ".catalogue", ext.NewDisk(filepath.Join(dir, "clone")))
+
+	_, err := os.Stat(filepath.Join(dir, ".catalogue", "x"))
+	if !os.IsNotExist(err) {
+		t.Fatal("expected file NOT to be written outside clone directory")
+	}
+}
+
+func TestVersionsIn(t *testing.T) {
+	listing := `<a href="foo-1.9.0.tar.gz">foo-1.9.0.tar.gz</a>
+<a href="foo-1.10.2.tar.gz">foo-1.10.2.tar.gz</a>
+["v1.10.1", "2.0.0-rc1", "1.9.0"]`
+
+	actual := versionsIn(listing)
+	expected := []string{"1.9.0", "1.10.2", "1.10.1"}
+	if diff := cmp.Diff(actual, expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+
+	if len(versionsIn("nothing here 1.2")) != 0 {
+		t.Fatal("expected NO versions")
+	}
+}
+
+func TestExpandVersion(t *testing.T) {
+	actual, err := expandVersion(u("https://foo.com/releases/v{version}/foo-{version}.tar.gz"), "1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual.String() != "https://foo.com/releases/v1.2.3/foo-1.2.3.tar.gz" {
+		t.Fatalf("'%s' not correct", actual)
+	}
+}
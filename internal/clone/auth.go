@@ -0,0 +1,124 @@
+package clone
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	githttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/go-git/go-git/v6/plumbing/transport/ssh"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+const defaultTokenUsername = "x-access-token"
+
+// remoteCredential looks up the credential for the host of a remote. Without
+// access to the credentials file, as when not run as root, remotes are used
+// anonymously.
+func remoteCredential(remote config.Remote, log *internal.Log, api *ext.API) internal.Credential {
+	credential, found, err := api.Host.GetCredential(remote.URL.Hostname())
+	if err != nil {
+		log.Info(8, "not using credentials: %s", err.Error())
+		return internal.Credential{}
+	}
+	if !found {
+		return internal.Credential{}
+	}
+	log.Info(9, "using %s", credential)
+	return credential
+}
+
+// gitAuth returns how go-git authenticates to a remote with credential.
+func gitAuth(credential internal.Credential, remote *url.URL) (transport.AuthMethod, error) {
+	user := "git"
+	if remote.User != nil && len(remote.User.Username()) != 0 {
+		user = remote.User.Username()
+	}
+
+	switch {
+	case len(credential.Token) != 0:
+		username := credential.Username
+		if len(username) == 0 {
+			username = defaultTokenUsername
+		}
+		return &githttp.BasicAuth{Username: username, Password: credential.Token}, nil
+	case len(credential.SSHKey) != 0:
+		auth, err := ssh.NewPublicKeysFromFile(user, credential.SSHKey, "")
+		if err != nil {
+			return nil, internal.ErrOf(err, "can not read ssh key '%s'", credential.SSHKey)
+		}
+		return auth, nil
+	case credential.SSHAgent:
+		auth, err := ssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, internal.ErrOf(err, "can not use ssh agent")
+		}
+		return auth, nil
+	case credential.Helper:
+		username, password, err := credentialFill(remote)
+		if err != nil {
+			return nil, err
+		}
+		return &githttp.BasicAuth{Username: username, Password: password}, nil
+	}
+	return nil, nil
+}
+
+// credentialFill asks the git credential helpers configured on this machine
+// for the username and password of a remote.
+func credentialFill(remote *url.URL) (string, string, error) {
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=%s\nhost=%s\npath=%s\n\n", remote.Scheme, remote.Host, strings.TrimPrefix(remote.Path, "/")))
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", internal.ErrOf(err, "git credential helper has no credential for '%s'", remote.Host)
+	}
+
+	var username, password string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), "=")
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+	if len(password) == 0 {
+		return "", "", internal.Err("git credential helper has no password for '%s'", remote.Host)
+	}
+	return username, password, nil
+}
+
+// gitEnv passes credential to the git CLI through the environment, so that
+// secrets never show up in its arguments. Agents and helpers are already
+// used by the git CLI.
+func gitEnv(credential internal.Credential) []string {
+	env := append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	switch {
+	case len(credential.Token) != 0:
+		username := credential.Username
+		if len(username) == 0 {
+			username = defaultTokenUsername
+		}
+		basic := base64.StdEncoding.EncodeToString([]byte(username + ":" + credential.Token))
+		env = append(env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+basic,
+		)
+	case len(credential.SSHKey) != 0:
+		env = append(env, fmt.Sprintf("GIT_SSH_COMMAND=ssh -i '%s' -o IdentitiesOnly=yes", credential.SSHKey))
+	}
+	return env
+}
@@ -0,0 +1,49 @@
+package clone
+
+import (
+	"encoding/base64"
+	"slices"
+	"strings"
+	"testing"
+
+	githttp "github.com/go-git/go-git/v6/plumbing/transport/http"
+	"github.com/woolawin/catalogue/internal"
+)
+
+func TestGitAuthToken(t *testing.T) {
+	auth, err := gitAuth(internal.Credential{Host: "github.com", Token: "secret"}, u("https://github.com/foo/bar.git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic, ok := auth.(*githttp.BasicAuth)
+	if !ok {
+		t.Fatalf("expected basic auth, got %T", auth)
+	}
+	if basic.Username != defaultTokenUsername || basic.Password != "secret" {
+		t.Fatalf("unexpected basic auth '%s'", basic.Username)
+	}
+	if strings.Contains(auth.String(), "secret") {
+		t.Fatalf("'%s' leaks the token", auth.String())
+	}
+}
+
+func TestGitAuthAnonymous(t *testing.T) {
+	auth, err := gitAuth(internal.Credential{}, u("https://github.com/foo/bar.git"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if auth != nil {
+		t.Fatalf("expected no auth, got %T", auth)
+	}
+}
+
+func TestGitEnvToken(t *testing.T) {
+	env := gitEnv(internal.Credential{Host: "github.com", Username: "bob", Token: "secret"})
+	header := "GIT_CONFIG_VALUE_0=Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte("bob:secret"))
+	if !slices.Contains(env, header) || !slices.Contains(env, "GIT_CONFIG_KEY_0=http.extraHeader") {
+		t.Fatal("expected token to be passed as an extra header")
+	}
+	if !slices.Contains(env, "GIT_TERMINAL_PROMPT=0") {
+		t.Fatal("expected terminal prompts to be disabled")
+	}
+}
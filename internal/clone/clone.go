@@ -1,30 +1,58 @@
 package clone
 
 import (
-	"fmt"
-	"os/exec"
-	"strings"
+	"os"
+	"path/filepath"
 
 	semverlib "github.com/Masterminds/semver/v3"
-	gitlib "github.com/go-git/go-git/v6"
-	"github.com/go-git/go-git/v6/plumbing"
 	"github.com/woolawin/catalogue/internal"
 	"github.com/woolawin/catalogue/internal/config"
 	"github.com/woolawin/catalogue/internal/ext"
 )
 
+// VCS is where the versions of a remote come from. Each protocol has its own
+// implementation, MockVCS stands in for them in tests.
+type VCS interface {
+	Remote() config.Remote
+	// ListVersions returns every released version of the remote.
+	ListVersions() ([]config.Pin, error)
+	// Fetch writes the given paths of a version into dst and returns the pin
+	// of what was written.
+	Fetch(pin config.Pin, paths []string, dst ext.Disk) (config.Pin, error)
+	// Author returns 'name <email>' of a fetched version.
+	Author(pin config.Pin) (string, error)
+}
+
+// Open returns the VCS for the protocol of a remote.
+func Open(remote config.Remote, log *internal.Log, api *ext.API) (VCS, bool) {
+	switch remote.Protocol {
+	case config.Git:
+		return newGitVCS(remote, log, api, MirrorBase, remoteCredential(remote, log, api)), true
+	case config.HTTP:
+		return &httpVCS{remote: remote, api: api, credential: remoteCredential(remote, log, api)}, true
+	case config.Local:
+		return &localVCS{remote: remote, log: log, api: api, mirrors: MirrorBase}, true
+	}
+	log.Err(nil, "unsupported clone protocol '%s'", config.ProtocolDebugString(remote.Protocol))
+	return nil, false
+}
+
 type Opts struct {
-	remote config.Remote
-	local  string
-	path   string
-	pin    *config.Pin
+	local string
+	path  string
+	pin   *config.Pin
 }
 
-func NewOpts(remote config.Remote, local string, path string, pin *config.Pin) Opts {
-	return Opts{remote: remote, local: local, path: path, pin: pin}
+func NewOpts(local string, path string, pin *config.Pin) Opts {
+	return Opts{local: local, path: path, pin: pin}
 }
 
-func Clone(opts Opts, log *internal.Log, api *ext.API) (string, bool) {
+const defaultAuthor = "person <not@known.com>"
+
+// Clone fetches opts.path of a remote into opts.local. Without a pin the
+// latest version is cloned, the pin of what was cloned is returned together
+// with its author.
+func Clone(vcs VCS, opts Opts, log *internal.Log, api *ext.API) (config.Pin, string, bool) {
 	prev := log.Stage("clone")
 	defer prev()
 
@@ -32,171 +60,104 @@ func Clone(opts Opts, log *internal.Log, api *ext.API) (string, bool) {
 	exists, _, err := api.Disk.DirExists(localPath)
 	if err != nil {
 		log.Err(err, "failed to check clone destination to '%s'", opts.local)
-		return "", false
+		return config.Pin{}, "", false
 	}
 	if exists {
 		log.Err(nil, "clone destination '%s' is not empty", opts.local)
-		return "", false
-	}
-	switch opts.remote.Protocol {
-	case config.Git:
-		return gitClone(opts, log)
-	}
-	log.Err(nil, "unsupported clone protocol '%s'", config.ProtocolDebugString(opts.remote.Protocol))
-	return "", false
-}
-
-func gitClone(opts Opts, log *internal.Log) (string, bool) {
-	prev := log.Stage("git")
-	defer prev()
-	// Currently the go git library does not properly support partial clone. So we must use the git CLI
-	/*
-		gitopts := &gitlib.CloneOptions{
-			URL:          opts.remote.URL.String(),
-			Depth:        1,
-			NoCheckout:   true,
-		}
-		repo, err := gitlib.PlainClone(opts.local, gitopts)
-	*/
-	log.Info(9, "cloning git repository '%s'", opts.remote.URL.Redacted())
-	cmd := exec.Command("git", "clone", "--depth", "1", "--filter=blob:none", "--no-checkout", opts.remote.URL.String(), opts.local)
-	err := cmd.Run()
-	if err != nil {
-		log.Err(err, "failed to clone git repository '%s'", opts.remote.URL.Redacted())
-		return "", false
+		return config.Pin{}, "", false
 	}
 
+	remote := vcs.Remote()
+	var pin config.Pin
 	if opts.pin != nil {
-		ok := sparseCheckout(opts.pin.CommitHash, opts.local, opts.path, log)
-		if !ok {
-			return "", false
-		}
+		pin = *opts.pin
 	} else {
-		hash, ok := getLatestCommitHash(opts.local, log)
-		ok = sparseCheckout(hash, opts.local, opts.path, log)
-		if !ok {
-			return "", false
+		pins, err := vcs.ListVersions()
+		if err != nil {
+			log.Err(err, "failed to list versions of '%s'", remote.URL.Redacted())
+			return config.Pin{}, "", false
+		}
+		latest, found := Latest(pins)
+		if !found {
+			log.Err(nil, "no semantic versions found for '%s'", remote.URL.Redacted())
+			return config.Pin{}, "", false
 		}
+		pin = latest
 	}
-	defaultAuthor := "person <not@known.com>"
 
-	repo, err := gitlib.PlainOpen(".")
+	log.Info(9, "fetching '%s' at '%s' from '%s'", opts.path, pin.VersionName, remote.URL.Redacted())
+	fetched, err := vcs.Fetch(pin, []string{opts.path}, ext.NewDisk(opts.local))
 	if err != nil {
-		return defaultAuthor, true
+		log.Err(err, "failed to fetch '%s' at '%s' from '%s'", opts.path, pin.VersionName, remote.URL.Redacted())
+		return config.Pin{}, "", false
 	}
-
-	ref, err := repo.Head()
-	if err != nil {
-		return defaultAuthor, true
+	// what gets built must be exactly what the pin records
+	if len(pin.CommitHash) != 0 && fetched.CommitHash != pin.CommitHash {
+		log.Err(nil, "fetched '%s' of '%s' but '%s' is pinned", fetched.CommitHash, pin.VersionName, pin.CommitHash)
+		return config.Pin{}, "", false
 	}
 
-	commit, err := repo.CommitObject(ref.Hash())
+	author, err := vcs.Author(fetched)
 	if err != nil {
-		return defaultAuthor, true
+		log.Info(8, "no author for '%s', using default: %s", fetched.VersionName, err.Error())
+		author = defaultAuthor
 	}
 
-	return fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email), true
+	return fetched, author, true
 }
 
-func sparseCheckout(hash string, local string, path string, log *internal.Log) bool {
-	init := exec.Command("git", "-C", local, "sparse-checkout", "init", "--cone")
-	err := init.Run()
-	if err != nil {
-		log.Err(err, "failed to checkout (init sparse) hash '%s'", hash)
-		return false
-	}
-	set := exec.Command("git", "-C", local, "sparse-checkout", "set", path)
-	err = set.Run()
-	if err != nil {
-		log.Err(err, "failed to checkout (set sparse) hash '%s'", hash)
-		return false
-	}
-	checkout := exec.Command("git", "-C", local, "checkout", hash)
-	err = checkout.Run()
-	if err != nil {
-		log.Err(err, "failed to checkout hash '%s'", hash)
-		return false
+// Latest returns the pin with the highest semantic version.
+func Latest(pins []config.Pin) (config.Pin, bool) {
+	var latest *semverlib.Version
+	var pin config.Pin
+	for _, candidate := range pins {
+		version, err := semverlib.NewVersion(candidate.VersionName)
+		if err != nil {
+			continue
+		}
+		if latest == nil || version.GreaterThan(latest) {
+			latest = version
+			pin = candidate
+		}
 	}
-	return true
+	return pin, latest != nil
 }
 
-func getLatestCommitHash(local string, log *internal.Log) (string, bool) {
-	symbolicRef := exec.Command("git", "-C", local, "symbolic-ref", "refs/remotes/origin/HEAD")
-	out, err := symbolicRef.Output()
-	if err != nil {
-		log.Err(err, "failed to get symbolic ref")
-		return "", false
-	}
-	branchRef := strings.TrimSpace(string(out))
-	parts := strings.Split(branchRef, "/")
-	defaultBranch := parts[len(parts)-1]
+// CloneTree replaces the clone at local with the whole tree of pin, which is
+// what components that build from source need.
+func CloneTree(vcs VCS, pin config.Pin, local string, log *internal.Log) bool {
+	prev := log.Stage("clone")
+	defer prev()
 
-	revParse := exec.Command("git", "-C", local, "rev-parse", defaultBranch)
-	out, err = revParse.Output()
+	err := os.RemoveAll(local)
 	if err != nil {
-		log.Err(err, "failed to get latest commit from ref '%s'", branchRef)
-		return "", false
+		log.Err(err, "failed to clear clone destination '%s'", local)
+		return false
 	}
-	return strings.TrimSpace(string(out)), true
-}
 
-func CheckoutLatestVersion(dir string, log *internal.Log) (config.Pin, bool) {
-	repo, err := gitlib.PlainOpen(dir)
+	remote := vcs.Remote()
+	log.Info(9, "fetching tree at '%s' from '%s'", pin.VersionName, remote.URL.Redacted())
+	fetched, err := vcs.Fetch(pin, []string{""}, ext.NewDisk(local))
 	if err != nil {
-		log.Err(err, "failed to open repository at '%s'", dir)
-		return config.Pin{}, false
+		log.Err(err, "failed to fetch tree at '%s' from '%s'", pin.VersionName, remote.URL.Redacted())
+		return false
 	}
-
-	tags, err := repo.Tags()
-	if err != nil {
-		log.Err(err, "failed to get repository tags")
-		return config.Pin{}, false
+	if fetched.CommitHash != pin.CommitHash {
+		log.Err(nil, "fetched tree '%s' of '%s' but '%s' was cloned", fetched.CommitHash, pin.VersionName, pin.CommitHash)
+		return false
 	}
-	defer tags.Close()
-
-	var latest *semverlib.Version
-	var hash string
-
-	err = tags.ForEach(func(ref *plumbing.Reference) error {
-		tagName := ref.Name().Short()
+	return true
+}
 
-		version, err := semverlib.NewVersion(tagName)
+// ReadFile reads a file of the clone at local, fetching it at pin when it was
+// not cloned along with the rest.
+func ReadFile(vcs VCS, pin config.Pin, local string, path string, api *ext.API) ([]byte, error) {
+	filePath := filepath.Join(local, filepath.FromSlash(path))
+	if _, err := os.Stat(filePath); err != nil {
+		_, err = vcs.Fetch(pin, []string{path}, ext.NewDisk(local))
 		if err != nil {
-			return nil
-		}
-
-		if latest != nil && latest.GreaterThan(version) {
-			return nil
-		}
-
-		tagObj, err := repo.TagObject(ref.Hash())
-		if err == nil {
-			commit, err := tagObj.Commit()
-			if err != nil {
-				return nil
-			}
-			latest = version
-			hash = commit.Hash.String()
-		} else {
-			commit, err := repo.CommitObject(ref.Hash())
-			if err != nil {
-				return nil
-			}
-			latest = version
-			hash = commit.Hash.String()
+			return nil, internal.ErrOf(err, "can not fetch '%s'", path)
 		}
-		return nil
-	})
-
-	if latest == nil {
-		log.Err(nil, "no semantic versioned tags found")
-		return config.Pin{}, false
 	}
-
-	pin := config.Pin{
-		VersionName: latest.String(),
-		CommitHash:  hash,
-	}
-
-	return pin, true
+	return api.Host.ReadTmpFile(filePath)
 }
@@ -0,0 +1,80 @@
+package clone
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+func u(value string) *url.URL {
+	res, err := url.Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func TestCloneLatestVersion(t *testing.T) {
+	vcs := &MockVCS{
+		Source: config.Remote{Protocol: config.Git, URL: u("https://foo.com/bar.git")},
+		Versions: []config.Pin{
+			{VersionName: "1.2.0", CommitHash: "aaa"},
+			{VersionName: "1.10.0", CommitHash: "bbb"},
+			{VersionName: "1.9.0", CommitHash: "ccc"},
+		},
+		Files: map[string]map[string]string{
+			"bbb": {
+				".catalogue/config.toml": "name='foo'",
+				"README.md":              "readme",
+			},
+		},
+		Authors: map[string]string{"bbb": "Bob Doe <bob@foo.com>"},
+	}
+
+	local := filepath.Join(t.TempDir(), "clone")
+	pin, author, ok := Clone(vcs, NewOpts(local, ".catalogue", nil), internal.NewLog(&DoNothingLogger{}), ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected clone to succeed")
+	}
+
+	expected := config.Pin{VersionName: "1.10.0", CommitHash: "bbb"}
+	if diff := cmp.Diff(pin, expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+	if author != "Bob Doe <bob@foo.com>" {
+		t.Fatalf("unexpected author '%s'", author)
+	}
+
+	data, err := os.ReadFile(filepath.Join(local, ".catalogue", "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "name='foo'" {
+		t.Fatalf("unexpected config.toml '%s'", data)
+	}
+	if _, err := os.Stat(filepath.Join(local, "README.md")); !os.IsNotExist(err) {
+		t.Fatal("expected only the requested path to be fetched")
+	}
+}
+
+func TestCloneDefaultAuthor(t *testing.T) {
+	vcs := &MockVCS{
+		Versions: []config.Pin{{VersionName: "1.0.0", CommitHash: "aaa"}},
+		Files:    map[string]map[string]string{"aaa": {".catalogue/config.toml": "name='foo'"}},
+	}
+
+	local := filepath.Join(t.TempDir(), "clone")
+	_, author, ok := Clone(vcs, NewOpts(local, ".catalogue", nil), internal.NewLog(&DoNothingLogger{}), ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected clone to succeed")
+	}
+	if author != defaultAuthor {
+		t.Fatalf("unexpected author '%s'", author)
+	}
+}
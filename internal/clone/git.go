@@ -0,0 +1,423 @@
+package clone
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	semverlib "github.com/Masterminds/semver/v3"
+	gitlib "github.com/go-git/go-git/v6"
+	gitconfig "github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/transport"
+	"github.com/go-git/go-git/v6/storage/memory"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+const peeledSuffix = "^{}"
+
+// MirrorBase holds a bare mirror of every git remote, so that refreshing a
+// component only fetches what changed since the last time.
+const MirrorBase = "/var/cache/catalogue/git"
+
+// gitVCS reads versions from the tags of a git remote. go-git is used first,
+// the git CLI is only a fallback for remotes go-git can not talk to.
+type gitVCS struct {
+	remote     config.Remote
+	log        *internal.Log
+	api        *ext.API
+	mirrors    string
+	credential internal.Credential
+	auth       transport.AuthMethod
+	tags       map[string]string
+}
+
+func newGitVCS(remote config.Remote, log *internal.Log, api *ext.API, mirrors string, credential internal.Credential) *gitVCS {
+	return &gitVCS{remote: remote, log: log, api: api, mirrors: mirrors, credential: credential}
+}
+
+func (vcs *gitVCS) Remote() config.Remote {
+	return vcs.remote
+}
+
+// ListVersions lists the tags of the remote, or of the mirror when the remote
+// can not be reached.
+func (vcs *gitVCS) ListVersions() ([]config.Pin, error) {
+	pins, err := vcs.remoteVersions()
+	if err != nil {
+		repo, unlock, openErr := vcs.openMirror()
+		if openErr != nil {
+			return nil, err
+		}
+		defer unlock()
+		vcs.log.Info(8, "remote '%s' is unreachable, using the tags of its mirror", vcs.remote.URL.Redacted())
+		return listTags(repo)
+	}
+	return pins, nil
+}
+
+// remoteVersions lists the tags of the remote itself. It never opens the
+// mirror, so it can be called while holding its lock.
+func (vcs *gitVCS) remoteVersions() ([]config.Pin, error) {
+	remote := gitlib.NewRemote(memory.NewStorage(), &gitconfig.RemoteConfig{
+		Name: gitlib.DefaultRemoteName,
+		URLs: []string{vcs.remote.URL.String()},
+	})
+	auth, err := vcs.authMethod()
+	if err != nil {
+		return nil, err
+	}
+	refs, err := remote.List(&gitlib.ListOptions{Auth: auth, PeelingOption: gitlib.AppendPeeled})
+	if err != nil {
+		vcs.log.Info(8, "listing '%s' failed, falling back to the git CLI: %s", vcs.remote.URL.Redacted(), err.Error())
+		refs, err = vcs.lsRemote()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pins, tags := tagPins(refs)
+	vcs.tags = tags
+	return pins, nil
+}
+
+// Fetch brings the tag, or commit when the tag is not known, of a pin into
+// the mirror unless it is already there, and writes the paths from it.
+func (vcs *gitVCS) Fetch(pin config.Pin, paths []string, dst ext.Disk) (config.Pin, error) {
+	dir := vcs.mirrorDir()
+	repo, unlock, err := vcs.openMirror()
+	if err != nil {
+		vcs.log.Info(8, "no mirror for '%s', fetching into memory: %s", vcs.remote.URL.Redacted(), err.Error())
+		repo, err = gitlib.Init(memory.NewStorage())
+		if err != nil {
+			return config.Pin{}, internal.ErrOf(err, "can not create repository")
+		}
+		dir, unlock = "", func() {}
+	}
+	defer unlock()
+
+	hash := plumbing.NewHash(pin.CommitHash)
+	_, err = repo.CommitObject(hash)
+	fetched := err != nil
+	if fetched {
+		err = vcs.fetch(repo, dir, vcs.source(pin))
+		if err != nil {
+			return config.Pin{}, err
+		}
+	} else {
+		vcs.log.Info(9, "commit '%s' is already mirrored", pin.CommitHash)
+	}
+	if len(dir) != 0 {
+		err = vcs.fetchBlobs(repo, dir, hash, paths)
+		if err != nil {
+			return config.Pin{}, err
+		}
+		// go-git does not see packs the git CLI added after it opened the
+		// mirror
+		repo, err = gitlib.PlainOpen(dir)
+		if err != nil {
+			return config.Pin{}, internal.ErrOf(err, "can not open mirror '%s'", dir)
+		}
+	}
+	if fetched {
+		err = markShallow(repo, hash)
+		if err != nil {
+			return config.Pin{}, err
+		}
+	}
+
+	for _, subpath := range paths {
+		err = writeTree(repo, hash, subpath, dst, vcs)
+		if err != nil {
+			return config.Pin{}, err
+		}
+	}
+	pin.Date, err = commitDate(repo, hash)
+	if err != nil {
+		return config.Pin{}, err
+	}
+	return pin, nil
+}
+
+func (vcs *gitVCS) Author(pin config.Pin) (string, error) {
+	repo, unlock, err := vcs.openMirror()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	return commitAuthor(repo, plumbing.NewHash(pin.CommitHash))
+}
+
+// source is the tag reference of a pin, or its commit hash when the tag is
+// not known. It is called with the mirror locked, so only the remote is
+// asked for its tags.
+func (vcs *gitVCS) source(pin config.Pin) string {
+	if vcs.tags == nil {
+		vcs.remoteVersions()
+	}
+	if tag, found := vcs.tags[pin.CommitHash]; found {
+		return tag
+	}
+	return pin.CommitHash
+}
+
+// fetch brings source into repo. The git CLI can only be a fallback when repo
+// is on disk in dir.
+func (vcs *gitVCS) fetch(repo *gitlib.Repository, dir string, source string) error {
+	remote, err := repo.Remote(gitlib.DefaultRemoteName)
+	if err == gitlib.ErrRemoteNotFound {
+		remote, err = repo.CreateRemote(&gitconfig.RemoteConfig{
+			Name: gitlib.DefaultRemoteName,
+			URLs: []string{vcs.remote.URL.String()},
+		})
+	}
+	if err != nil {
+		return internal.ErrOf(err, "can not add remote")
+	}
+
+	refSpec := gitconfig.RefSpec("+" + source + ":" + source)
+	if !strings.HasPrefix(source, "refs/") {
+		refSpec = gitconfig.RefSpec(source + ":refs/catalogue/" + source)
+	}
+	auth, err := vcs.authMethod()
+	if err != nil {
+		return err
+	}
+	vcs.log.Info(9, "fetching '%s' from '%s'", source, vcs.remote.URL.Redacted())
+	// only the pinned commit is ever read, never its history
+	err = remote.Fetch(&gitlib.FetchOptions{
+		Auth:     auth,
+		RefSpecs: []gitconfig.RefSpec{refSpec},
+		Depth:    1,
+		Tags:     plumbing.NoTags,
+	})
+	if err == nil || err == gitlib.NoErrAlreadyUpToDate {
+		return nil
+	}
+
+	if len(dir) == 0 {
+		return internal.ErrOf(err, "can not fetch '%s' from '%s'", source, vcs.remote.URL.Redacted())
+	}
+	vcs.log.Info(8, "fetching '%s' failed, falling back to the git CLI: %s", source, err.Error())
+	// blobs are left out until fetchBlobs asks for those of the paths written,
+	// a server that can not do either is asked for the whole history
+	out, err := vcs.git(dir, nil, "fetch", "--quiet", "--no-tags", "--depth=1", "--filter=blob:none", gitlib.DefaultRemoteName, string(refSpec))
+	if err != nil {
+		vcs.log.Info(8, "shallow fetch of '%s' was rejected, fetching all of it: %s", source, out)
+		out, err = vcs.git(dir, nil, "fetch", "--quiet", "--no-tags", gitlib.DefaultRemoteName, string(refSpec))
+	}
+	if err != nil {
+		return internal.ErrOf(err, "can not fetch '%s' from '%s': %s", source, vcs.remote.URL.Redacted(), out)
+	}
+	return nil
+}
+
+// markShallow records a fetched commit whose parents were left out as
+// shallow. go-git does not record it itself, and the git CLI takes a commit
+// with missing parents for a broken mirror.
+func markShallow(repo *gitlib.Repository, hash plumbing.Hash) error {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return internal.ErrOf(err, "can not find commit '%s'", hash)
+	}
+	for _, parent := range commit.ParentHashes {
+		_, err = repo.Storer.EncodedObject(plumbing.CommitObject, parent)
+		if err == nil {
+			continue
+		}
+		shallows, err := repo.Storer.Shallow()
+		if err != nil {
+			return internal.ErrOf(err, "can not read shallow commits")
+		}
+		if slices.Contains(shallows, hash) {
+			return nil
+		}
+		err = repo.Storer.SetShallow(append(shallows, hash))
+		if err != nil {
+			return internal.ErrOf(err, "can not record commit '%s' as shallow", hash)
+		}
+		return nil
+	}
+	return nil
+}
+
+// fetchBlobs fetches the blobs of paths in a commit that a partial fetch left
+// out of the mirror, go-git can not fetch them itself when reading them.
+func (vcs *gitVCS) fetchBlobs(repo *gitlib.Repository, dir string, hash plumbing.Hash, paths []string) error {
+	cfg, err := repo.Config()
+	if err != nil {
+		return internal.ErrOf(err, "can not read config of mirror '%s'", dir)
+	}
+	if cfg.Raw.Section("remote").Subsection(gitlib.DefaultRemoteName).Option("promisor") != "true" {
+		return nil
+	}
+
+	args := []string{"rev-list", "--objects", "--missing=print", hash.String(), "--"}
+	for _, subpath := range paths {
+		subpath = strings.Trim(path.Clean("/"+subpath), "/")
+		if len(subpath) == 0 {
+			args = args[:len(args)-1]
+			break
+		}
+		args = append(args, subpath)
+	}
+	out, err := vcs.git(dir, nil, args...)
+	if err != nil {
+		return internal.ErrOf(err, "can not list objects of commit '%s': %s", hash, out)
+	}
+	var missing strings.Builder
+	for _, line := range strings.Split(out, "\n") {
+		if oid, found := strings.CutPrefix(line, "?"); found {
+			missing.WriteString(oid + "\n")
+		}
+	}
+	if missing.Len() == 0 {
+		return nil
+	}
+
+	vcs.log.Info(9, "fetching files of commit '%s' from '%s'", hash, vcs.remote.URL.Redacted())
+	out, err = vcs.git(dir, strings.NewReader(missing.String()), "-c", "fetch.negotiationAlgorithm=noop", "fetch", "--quiet", "--no-tags", "--no-write-fetch-head", "--recurse-submodules=no", "--filter=blob:none", "--stdin", gitlib.DefaultRemoteName)
+	if err != nil {
+		return internal.ErrOf(err, "can not fetch files of commit '%s' from '%s': %s", hash, vcs.remote.URL.Redacted(), out)
+	}
+	return nil
+}
+
+// git runs the git CLI on the mirror in dir, returning its output.
+func (vcs *gitVCS) git(dir string, stdin io.Reader, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"--git-dir", dir}, args...)...)
+	cmd.Env = gitEnv(vcs.credential)
+	cmd.Stdin = stdin
+	out, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+func (vcs *gitVCS) authMethod() (transport.AuthMethod, error) {
+	if vcs.auth == nil {
+		auth, err := gitAuth(vcs.credential, vcs.remote.URL)
+		if err != nil {
+			return nil, err
+		}
+		vcs.auth = auth
+	}
+	return vcs.auth, nil
+}
+
+// openMirror opens, creating it if needed, the bare mirror of the remote and
+// locks it until unlock is called.
+func (vcs *gitVCS) openMirror() (*gitlib.Repository, func(), error) {
+	dir := vcs.mirrorDir()
+	if len(dir) == 0 {
+		return nil, nil, internal.Err("mirrors are disabled")
+	}
+
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, nil, internal.ErrOf(err, "can not create mirror '%s'", dir)
+	}
+
+	lock, err := os.OpenFile(dir+".lock", os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, nil, internal.ErrOf(err, "can not open lock of mirror '%s'", dir)
+	}
+	err = syscall.Flock(int(lock.Fd()), syscall.LOCK_EX)
+	if err != nil {
+		lock.Close()
+		return nil, nil, internal.ErrOf(err, "can not lock mirror '%s'", dir)
+	}
+	unlock := func() {
+		syscall.Flock(int(lock.Fd()), syscall.LOCK_UN)
+		lock.Close()
+	}
+
+	repo, err := gitlib.PlainOpen(dir)
+	if err == gitlib.ErrRepositoryNotExists {
+		repo, err = gitlib.PlainInit(dir, true)
+	}
+	if err != nil {
+		unlock()
+		return nil, nil, internal.ErrOf(err, "can not open mirror '%s'", dir)
+	}
+	return repo, unlock, nil
+}
+
+func (vcs *gitVCS) mirrorDir() string {
+	if len(vcs.mirrors) == 0 {
+		return ""
+	}
+	return filepath.Join(vcs.mirrors, mirrorName(vcs.remote.URL))
+}
+
+// mirrorName is the directory of the mirror of a remote, credentials in the
+// URL do not change it.
+func mirrorName(remote *url.URL) string {
+	stripped := *remote
+	stripped.User = nil
+	hash := sha256.Sum256([]byte(stripped.String()))
+	return hex.EncodeToString(hash[:])
+}
+
+func (vcs *gitVCS) lsRemote() ([]*plumbing.Reference, error) {
+	cmd := exec.Command("git", "ls-remote", "--tags", vcs.remote.URL.String())
+	cmd.Env = gitEnv(vcs.credential)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not list tags of '%s'", vcs.remote.URL.Redacted())
+	}
+
+	var refs []*plumbing.Reference
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		hash, name, found := strings.Cut(scanner.Text(), "\t")
+		if !found {
+			continue
+		}
+		refs = append(refs, plumbing.NewHashReference(plumbing.ReferenceName(name), plumbing.NewHash(hash)))
+	}
+	return refs, nil
+}
+
+// tagPins returns a pin for every semantic versioned tag in refs, with
+// annotated tags peeled to their commit, and the tag reference of each commit.
+func tagPins(refs []*plumbing.Reference) ([]config.Pin, map[string]string) {
+	direct := make(map[string]string)
+	peeled := make(map[string]string)
+	for _, ref := range refs {
+		name := ref.Name().String()
+		if !strings.HasPrefix(name, "refs/tags/") {
+			continue
+		}
+		if base, found := strings.CutSuffix(name, peeledSuffix); found {
+			peeled[base] = ref.Hash().String()
+			continue
+		}
+		direct[name] = ref.Hash().String()
+	}
+
+	var pins []config.Pin
+	tags := make(map[string]string)
+	for name, hash := range direct {
+		version, err := semverlib.NewVersion(strings.TrimPrefix(name, "refs/tags/"))
+		if err != nil {
+			continue
+		}
+		if commit, found := peeled[name]; found {
+			hash = commit
+		}
+		pins = append(pins, config.Pin{VersionName: version.String(), CommitHash: hash})
+		tags[hash] = name
+	}
+	return pins, tags
+}
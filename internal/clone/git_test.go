@@ -0,0 +1,256 @@
+package clone
+
+import (
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	gitlib "github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+func TestGitVCS(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := gitlib.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commitFile(t, repo, dir, ".catalogue/config.toml", "name='old'")
+	head, _ := repo.Head()
+	_, err = repo.CreateTag("v1.0.0", head.Hash(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged := commitFile(t, repo, dir, ".catalogue/config.toml", "name='tagged'")
+	head, _ = repo.Head()
+	tagger := &object.Signature{Name: "Bob Doe", Email: "bob@foo.com", When: time.Now()}
+	_, err = repo.CreateTag("v1.1.0", head.Hash(), &gitlib.CreateTagOptions{Message: "release", Tagger: tagger})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, repo, dir, ".catalogue/config.toml", "name='head'")
+
+	log := internal.NewLog(&DoNothingLogger{})
+	vcs := newGitVCS(config.Remote{Protocol: config.Git, URL: u("file://" + dir)}, log, ext.NewAPI("/"), t.TempDir(), internal.Credential{})
+
+	local := filepath.Join(t.TempDir(), "clone")
+	pin, author, ok := Clone(vcs, NewOpts(local, ".catalogue", nil), log, ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected clone to succeed")
+	}
+	if pin.VersionName != "1.1.0" || pin.CommitHash != tagged {
+		t.Fatalf("unexpected pin %v, expected commit '%s'", pin, tagged)
+	}
+	if author != "Bob Doe <bob@foo.com>" {
+		t.Fatalf("unexpected author '%s'", author)
+	}
+
+	data, err := os.ReadFile(filepath.Join(local, ".catalogue", "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "name='tagged'" {
+		t.Fatalf("expected tagged config.toml, got '%s'", data)
+	}
+}
+
+func TestGitVCSUsesMirror(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := gitlib.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged := commitFile(t, repo, dir, ".catalogue/config.toml", "name='tagged'")
+	head, _ := repo.Head()
+	_, err = repo.CreateTag("v1.0.0", head.Hash(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := internal.NewLog(&DoNothingLogger{})
+	mirrors := t.TempDir()
+	remote := config.Remote{Protocol: config.Git, URL: u("file://" + dir)}
+
+	_, _, ok := Clone(newGitVCS(remote, log, ext.NewAPI("/"), mirrors, internal.Credential{}), NewOpts(filepath.Join(t.TempDir(), "clone"), ".catalogue", nil), log, ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected first clone to succeed")
+	}
+
+	err = os.RemoveAll(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := filepath.Join(t.TempDir(), "clone")
+	pin, author, ok := Clone(newGitVCS(remote, log, ext.NewAPI("/"), mirrors, internal.Credential{}), NewOpts(local, ".catalogue", nil), log, ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected clone from mirror to succeed")
+	}
+	if pin.VersionName != "1.0.0" || pin.CommitHash != tagged {
+		t.Fatalf("unexpected pin %v, expected commit '%s'", pin, tagged)
+	}
+	if author != "Bob Doe <bob@foo.com>" {
+		t.Fatalf("unexpected author '%s'", author)
+	}
+	data, err := os.ReadFile(filepath.Join(local, ".catalogue", "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "name='tagged'" {
+		t.Fatalf("expected tagged config.toml, got '%s'", data)
+	}
+}
+
+func TestGitVCSFetchUnreachableRemote(t *testing.T) {
+	log := internal.NewLog(&DoNothingLogger{})
+	remote := config.Remote{Protocol: config.Git, URL: u("file:///nonexistent/repo")}
+	vcs := newGitVCS(remote, log, ext.NewAPI("/"), t.TempDir(), internal.Credential{})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := vcs.Fetch(config.Pin{CommitHash: "0123456789abcdef0123456789abcdef01234567"}, []string{""}, ext.NewDisk(t.TempDir()))
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected fetching from an unreachable remote to fail")
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("fetching a pinned commit from an unreachable remote did not return")
+	}
+}
+
+func TestGitVCSFetchIsShallow(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := gitlib.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := commitFile(t, repo, dir, ".catalogue/config.toml", "name='old'")
+	tagged := commitFile(t, repo, dir, ".catalogue/config.toml", "name='tagged'")
+	_, err = repo.CreateTag("v1.0.0", plumbing.NewHash(tagged), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := internal.NewLog(&DoNothingLogger{})
+	vcs := newGitVCS(config.Remote{Protocol: config.Git, URL: u(httpRemote(t, dir))}, log, ext.NewAPI("/"), t.TempDir(), internal.Credential{})
+	_, _, ok := Clone(vcs, NewOpts(filepath.Join(t.TempDir(), "clone"), ".catalogue", nil), log, ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected clone to succeed")
+	}
+
+	assertShallow(t, vcs.mirrorDir(), tagged, old)
+}
+
+func TestGitVCSFetchPinnedCommitIsPartial(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := gitlib.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command("git", "-C", dir, "config", "uploadpack.allowFilter", "true").CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	old := commitFile(t, repo, dir, ".catalogue/config.toml", "name='old'")
+	commitFile(t, repo, dir, "src/main.c", "int main() {}")
+	pinned := commitFile(t, repo, dir, ".catalogue/config.toml", "name='pinned'")
+
+	log := internal.NewLog(&DoNothingLogger{})
+	vcs := newGitVCS(config.Remote{Protocol: config.Git, URL: u(httpRemote(t, dir))}, log, ext.NewAPI("/"), t.TempDir(), internal.Credential{})
+	pin := config.Pin{CommitHash: pinned}
+
+	local := t.TempDir()
+	_, err = vcs.Fetch(pin, []string{".catalogue"}, ext.NewDisk(local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertShallow(t, vcs.mirrorDir(), pinned, old)
+
+	mirror, err := gitlib.PlainOpen(vcs.mirrorDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := repo.CommitObject(plumbing.NewHash(pinned))
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := source.File("src/main.c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = mirror.BlobObject(file.Hash)
+	if err == nil {
+		t.Fatal("expected blobs outside of the paths written to be left out")
+	}
+
+	_, err = vcs.Fetch(pin, []string{""}, ext.NewDisk(local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(local, "src", "main.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "int main() {}" {
+		t.Fatalf("expected src/main.c of the pinned commit, got '%s'", data)
+	}
+}
+
+// httpRemote serves the repository in dir through git http-backend, go-git
+// serves file:// remotes itself and does not send only the commits asked for.
+func httpRemote(t *testing.T, dir string) string {
+	t.Helper()
+	out, err := exec.Command("git", "--exec-path").Output()
+	if err != nil {
+		t.Skip("git is not installed")
+	}
+	backend := filepath.Join(strings.TrimSpace(string(out)), "git-http-backend")
+	if _, err := os.Stat(backend); err != nil {
+		t.Skip("git http-backend is not installed")
+	}
+	server := httptest.NewServer(&cgi.Handler{
+		Path: backend,
+		Env:  []string{"GIT_PROJECT_ROOT=" + filepath.Dir(dir), "GIT_HTTP_EXPORT_ALL=1"},
+	})
+	t.Cleanup(server.Close)
+	return server.URL + "/" + filepath.Base(dir)
+}
+
+// assertShallow checks the mirror has only the pinned commit and none of the
+// history before it.
+func assertShallow(t *testing.T, mirror string, pinned string, old string) {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(mirror, "shallow"))
+	if err != nil {
+		t.Fatalf("expected a shallow mirror: %v", err)
+	}
+	if strings.TrimSpace(string(data)) != pinned {
+		t.Fatalf("expected the mirror to be shallow at '%s', got '%s'", pinned, data)
+	}
+	repo, err := gitlib.PlainOpen(mirror)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.CommitObject(plumbing.NewHash(old))
+	if err == nil {
+		t.Fatalf("expected commit '%s' before the pin not to be fetched", old)
+	}
+	out, err := exec.Command("git", "--git-dir", mirror, "rev-list", pinned).CombinedOutput()
+	if err != nil {
+		t.Fatalf("expected the git CLI to read the mirror: %s", out)
+	}
+}
@@ -0,0 +1,107 @@
+package clone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+const versionPlaceholder = "{version}"
+
+var versionPattern = regexp.MustCompile(`\bv?(\d+\.\d+\.\d+)(-(?:alpha|beta|rc|pre|dev)[0-9.]*)?\b`)
+
+type httpVCS struct {
+	remote     config.Remote
+	api        *ext.API
+	credential internal.Credential
+}
+
+func (vcs *httpVCS) Remote() config.Remote {
+	return vcs.remote
+}
+
+// ListVersions reads the versions from the versions source of the remote, or
+// from the archive name when the remote points at a single archive.
+func (vcs *httpVCS) ListVersions() ([]config.Pin, error) {
+	var text string
+	if vcs.remote.Versions == nil {
+		if strings.Contains(vcs.remote.URL.String(), versionPlaceholder) || strings.Contains(vcs.remote.URL.EscapedPath(), url.PathEscape(versionPlaceholder)) {
+			return nil, internal.Err("remote url has a '%s' placeholder but no versions source", versionPlaceholder)
+		}
+		text = path.Base(vcs.remote.URL.Path)
+	} else {
+		data, err := vcs.api.Http.FetchAuthorized(vcs.remote.Versions, vcs.credential)
+		if err != nil {
+			return nil, internal.ErrOf(err, "can not fetch versions from '%s'", vcs.remote.Versions.Redacted())
+		}
+		text = string(data)
+	}
+
+	var pins []config.Pin
+	for _, version := range versionsIn(text) {
+		pins = append(pins, config.Pin{VersionName: version})
+	}
+	return pins, nil
+}
+
+// Fetch downloads the archive of a version and unpacks paths from it. The pin
+// of an HTTP remote is the version and the SHA256 of the archive, which is
+// checked when the given pin has one.
+func (vcs *httpVCS) Fetch(pin config.Pin, paths []string, dst ext.Disk) (config.Pin, error) {
+	archiveURL, err := expandVersion(vcs.remote.URL, pin.VersionName)
+	if err != nil {
+		return config.Pin{}, internal.ErrOf(err, "invalid archive url for version '%s'", pin.VersionName)
+	}
+
+	data, err := vcs.api.Http.FetchAuthorized(archiveURL, vcs.credential)
+	if err != nil {
+		return config.Pin{}, internal.ErrOf(err, "can not download archive '%s'", archiveURL.Redacted())
+	}
+
+	hash := sha256.Sum256(data)
+	fetched := config.Pin{VersionName: pin.VersionName, CommitHash: hex.EncodeToString(hash[:])}
+	if len(pin.CommitHash) != 0 && pin.CommitHash != fetched.CommitHash {
+		return config.Pin{}, internal.Err("archive '%s' has SHA256 '%s', expected '%s'", archiveURL.Redacted(), fetched.CommitHash, pin.CommitHash)
+	}
+
+	for _, subpath := range paths {
+		err = unpack(data, subpath, dst)
+		if err != nil {
+			return config.Pin{}, internal.ErrOf(err, "can not unpack archive '%s'", archiveURL.Redacted())
+		}
+	}
+	return fetched, nil
+}
+
+func (vcs *httpVCS) Author(pin config.Pin) (string, error) {
+	return "", internal.Err("archives have no author")
+}
+
+// versionsIn finds the 'x.y.z' releases mentioned in text, it works on plain
+// lists, JSON and HTML directory indexes alike.
+func versionsIn(text string) []string {
+	var versions []string
+	for _, match := range versionPattern.FindAllStringSubmatch(text, -1) {
+		if len(match[2]) != 0 {
+			continue
+		}
+		if slices.Contains(versions, match[1]) {
+			continue
+		}
+		versions = append(versions, match[1])
+	}
+	return versions
+}
+
+func expandVersion(template *url.URL, version string) (*url.URL, error) {
+	replacer := strings.NewReplacer(versionPlaceholder, version, url.PathEscape(versionPlaceholder), version)
+	return url.Parse(replacer.Replace(template.String()))
+}
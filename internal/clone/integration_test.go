@@ -0,0 +1,138 @@
+package clone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gitlib "github.com/go-git/go-git/v6"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/build"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// The tagged commit is several commits behind HEAD, what gets built must be
+// the tagged tree and not whatever HEAD has.
+func TestBuildsPinnedCommit(t *testing.T) {
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		t.Skip("dpkg-deb is not installed")
+	}
+
+	dir := t.TempDir()
+	repo, err := gitlib.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commitFile(t, repo, dir, ".catalogue/config.toml", "name='foo'\ntype='package'\nsupported_targets=['all']")
+	commitFile(t, repo, dir, ".catalogue/filemaps/root.all/usr/share/foo/version.txt", "1.0.0")
+	tagged := commitFile(t, repo, dir, "README.md", "release")
+	head, _ := repo.Head()
+	_, err = repo.CreateTag("v1.0.0", head.Hash(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, repo, dir, ".catalogue/filemaps/root.all/usr/share/foo/version.txt", "head")
+	commitFile(t, repo, dir, "README.md", "unreleased")
+
+	log := internal.NewLog(&DoNothingLogger{})
+	remote := config.Remote{Protocol: config.Git, URL: u("file://" + dir)}
+	local := filepath.Join(t.TempDir(), "clone")
+
+	pin, _, ok := Clone(newGitVCS(remote, log, ext.NewAPI("/"), t.TempDir(), internal.Credential{}), NewOpts(local, ".catalogue", nil), log, ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected clone to succeed")
+	}
+	if pin.CommitHash != tagged {
+		t.Fatalf("expected pin of tagged commit '%s', got '%s'", tagged, pin.CommitHash)
+	}
+
+	deb := filepath.Join(t.TempDir(), "foo.deb")
+	file, err := os.Create(deb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	record := config.Record{Name: "foo", LatestPin: pin, Remote: remote}
+	record.Metadata.Architecture = "all"
+	record.Metadata.Maintainer = "Bob Doe"
+	record.Metadata.Description = "foo"
+	system := internal.System{Architecture: internal.AMD64}
+	ok = build.Build(file, record, build.Opts{}, log, system, ext.NewAPI(filepath.Join(local, ".catalogue")))
+	if !ok {
+		t.Fatal("expected build to succeed")
+	}
+
+	extracted := t.TempDir()
+	out, err := exec.Command("dpkg-deb", "-x", deb, extracted).CombinedOutput()
+	if err != nil {
+		t.Fatalf("can not extract package: %s", out)
+	}
+	data, err := os.ReadFile(filepath.Join(extracted, "usr", "share", "foo", "version.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "1.0.0" {
+		t.Fatalf("expected the tagged version.txt to be built, got '%s'", data)
+	}
+}
+
+// Two builds of the same pin, cloned and built at different times, must be
+// the same package byte for byte.
+func TestBuildIsReproducible(t *testing.T) {
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		t.Skip("dpkg-deb is not installed")
+	}
+
+	dir := t.TempDir()
+	repo, err := gitlib.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, repo, dir, ".catalogue/config.toml", "name='foo'\ntype='package'\nsupported_targets=['all']")
+	commitFile(t, repo, dir, ".catalogue/filemaps/root.all/usr/share/foo/a.txt", "a")
+	commitFile(t, repo, dir, ".catalogue/filemaps/root.all/usr/share/foo/b/c.txt", "c")
+	head, _ := repo.Head()
+	_, err = repo.CreateTag("v1.0.0", head.Hash(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	log := internal.NewLog(&DoNothingLogger{})
+	remote := config.Remote{Protocol: config.Git, URL: u("file://" + dir)}
+	mirrors := t.TempDir()
+	system := internal.System{Architecture: internal.AMD64}
+
+	var builds []string
+	for range 2 {
+		local := filepath.Join(t.TempDir(), "clone")
+		pin, _, ok := Clone(newGitVCS(remote, log, ext.NewAPI("/"), mirrors, internal.Credential{}), NewOpts(local, ".catalogue", nil), log, ext.NewAPI("/"))
+		if !ok {
+			t.Fatal("expected clone to succeed")
+		}
+
+		record := config.Record{Name: "foo", LatestPin: pin, Remote: remote}
+		record.Metadata.Architecture = "all"
+		record.Metadata.Maintainer = "Bob Doe"
+		record.Metadata.Description = "foo"
+		record.Metadata.Dependencies = "bar"
+
+		hasher := sha256.New()
+		ok = build.Build(hasher, record, build.Opts{}, log, system, ext.NewAPI(filepath.Join(local, ".catalogue")))
+		if !ok {
+			t.Fatal("expected build to succeed")
+		}
+		builds = append(builds, hex.EncodeToString(hasher.Sum(nil)))
+		time.Sleep(1100 * time.Millisecond)
+	}
+
+	if builds[0] != builds[1] {
+		t.Fatalf("expected the same package from the same pin, got '%s' and '%s'", builds[0], builds[1])
+	}
+}
@@ -0,0 +1,174 @@
+package clone
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing/format/config"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+const lfsMediaType = "application/vnd.git-lfs+json"
+
+type lfsObject struct {
+	OID     string               `json:"oid"`
+	Size    int64                `json:"size"`
+	Actions map[string]lfsAction `json:"actions,omitempty"`
+	Error   *lfsError            `json:"error,omitempty"`
+}
+
+type lfsAction struct {
+	Href   string            `json:"href"`
+	Header map[string]string `json:"header,omitempty"`
+}
+
+type lfsError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type lfsBatch struct {
+	Operation string      `json:"operation,omitempty"`
+	Transfers []string    `json:"transfers,omitempty"`
+	Objects   []lfsObject `json:"objects"`
+}
+
+// lfsEndpoint is the LFS server of a commit, set by the url in the lfs
+// section of its .lfsconfig or else next to the git remote.
+func lfsEndpoint(commit *object.Commit, remote *url.URL) (*url.URL, error) {
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not read tree of commit '%s'", commit.Hash)
+	}
+	file, err := tree.File(".lfsconfig")
+	if err == nil {
+		contents, err := file.Contents()
+		if err != nil {
+			return nil, internal.ErrOf(err, "can not read .lfsconfig")
+		}
+		lfsConfig := config.New()
+		err = config.NewDecoder(strings.NewReader(contents)).Decode(lfsConfig)
+		if err != nil {
+			return nil, internal.ErrOf(err, "can not parse .lfsconfig")
+		}
+		value := lfsConfig.Section("lfs").Option("url")
+		if len(value) != 0 {
+			endpoint, err := url.Parse(value)
+			if err != nil {
+				return nil, internal.ErrOf(err, "invalid lfs url '%s' in .lfsconfig", value)
+			}
+			return endpoint, nil
+		}
+	}
+
+	endpoint := *remote
+	endpoint.User = nil
+	if endpoint.Scheme == "ssh" {
+		endpoint.Scheme = "https"
+		endpoint.Host = endpoint.Hostname()
+	}
+	if !strings.HasSuffix(endpoint.Path, ".git") {
+		endpoint.Path += ".git"
+	}
+	endpoint.Path += "/info/lfs"
+	return &endpoint, nil
+}
+
+// fetchLFS downloads an object through the LFS batch API and checks it
+// against its pointer.
+func fetchLFS(endpoint *url.URL, pointer internal.LFSPointer, credential internal.Credential, api *ext.API) ([]byte, error) {
+	if len(credential.Token) != 0 && len(credential.Username) == 0 {
+		credential.Username = defaultTokenUsername
+	}
+
+	request, err := json.Marshal(lfsBatch{
+		Operation: "download",
+		Transfers: []string{"basic"},
+		Objects:   []lfsObject{{OID: pointer.OID, Size: pointer.Size}},
+	})
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not create lfs batch request")
+	}
+
+	batchURL := endpoint.JoinPath("objects", "batch")
+	headers := map[string]string{"Accept": lfsMediaType, "Content-Type": lfsMediaType}
+	data, err := api.Http.Send("POST", batchURL, headers, bytes.NewReader(request), credential)
+	if err != nil {
+		return nil, err
+	}
+
+	var response lfsBatch
+	err = json.Unmarshal(data, &response)
+	if err != nil {
+		return nil, internal.ErrOf(err, "invalid lfs batch response from '%s'", batchURL.Redacted())
+	}
+
+	for _, object := range response.Objects {
+		if object.OID != pointer.OID {
+			continue
+		}
+		if object.Error != nil {
+			return nil, internal.Err("lfs server has no object '%s': %s", pointer.OID, object.Error.Message)
+		}
+		download, found := object.Actions["download"]
+		if !found {
+			return nil, internal.Err("lfs server gave no download for object '%s'", pointer.OID)
+		}
+		href, err := url.Parse(download.Href)
+		if err != nil {
+			return nil, internal.ErrOf(err, "invalid lfs download url for object '%s'", pointer.OID)
+		}
+		// only send our own credential back to the LFS server itself
+		if href.Host != endpoint.Host {
+			credential = internal.Credential{}
+		}
+		data, err = api.Http.Send("GET", href, download.Header, nil, credential)
+		if err != nil {
+			return nil, err
+		}
+		return data, verifyLFS(data, pointer)
+	}
+	return nil, internal.Err("lfs server did not answer for object '%s'", pointer.OID)
+}
+
+func verifyLFS(data []byte, pointer internal.LFSPointer) error {
+	hash := sha256.Sum256(data)
+	if int64(len(data)) != pointer.Size || hex.EncodeToString(hash[:]) != pointer.OID {
+		return internal.Err("lfs object '%s' does not match its pointer", pointer.OID)
+	}
+	return nil
+}
+
+// lfsStorePath is where git-lfs keeps an object under its objects directory.
+func lfsStorePath(store string, oid string) string {
+	return filepath.Join(store, oid[0:2], oid[2:4], oid)
+}
+
+func readLFSStore(store string, pointer internal.LFSPointer) ([]byte, bool) {
+	data, err := os.ReadFile(lfsStorePath(store, pointer.OID))
+	if err != nil || verifyLFS(data, pointer) != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func writeLFSStore(store string, pointer internal.LFSPointer, data []byte) error {
+	path := lfsStorePath(store, pointer.OID)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return internal.ErrOf(err, "can not create lfs store '%s'", store)
+	}
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return internal.ErrOf(err, "can not store lfs object '%s'", pointer.OID)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package clone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gitlib "github.com/go-git/go-git/v6"
+	"github.com/google/go-cmp/cmp"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+func lfsPointer(contents string) (string, string) {
+	hash := sha256.Sum256([]byte(contents))
+	oid := hex.EncodeToString(hash[:])
+	return oid, fmt.Sprintf("version https://git-lfs.github.com/spec/v1\noid sha256:%s\nsize %d\n", oid, len(contents))
+}
+
+func TestLocalVCSResolvesLFS(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := gitlib.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oid, pointer := lfsPointer("large binary")
+	store := filepath.Join(dir, ".git", "lfs", "objects", oid[0:2], oid[2:4])
+	os.MkdirAll(store, 0755)
+	os.WriteFile(filepath.Join(store, oid), []byte("large binary"), 0644)
+	hash := commitFile(t, repo, dir, ".catalogue/filemaps/root.all/usr/bin/foo", pointer)
+
+	local := t.TempDir()
+	vcs := &localVCS{remote: config.Remote{Protocol: config.Local, URL: u("file://" + dir)}}
+	_, err = vcs.Fetch(config.Pin{CommitHash: hash}, []string{".catalogue"}, ext.NewDisk(local))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(local, ".catalogue", "filemaps", "root.all", "usr", "bin", "foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "large binary" {
+		t.Fatalf("expected lfs content, got '%s'", data)
+	}
+}
+
+func TestGitVCSFetchesLFS(t *testing.T) {
+	oid, pointer := lfsPointer("large binary")
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lfs/objects/batch":
+			requests++
+			if r.Header.Get("Content-Type") != lfsMediaType {
+				http.Error(w, "bad content type", http.StatusBadRequest)
+				return
+			}
+			var batch lfsBatch
+			json.NewDecoder(r.Body).Decode(&batch)
+			if batch.Operation != "download" || len(batch.Objects) != 1 || batch.Objects[0].OID != oid {
+				http.Error(w, "bad batch", http.StatusBadRequest)
+				return
+			}
+			object := lfsObject{OID: oid, Size: 12, Actions: map[string]lfsAction{
+				"download": {Href: "http://" + r.Host + "/objects/" + oid},
+			}}
+			json.NewEncoder(w).Encode(lfsBatch{Objects: []lfsObject{object}})
+		case "/objects/" + oid:
+			w.Write([]byte("large binary"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	repo, err := gitlib.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, repo, dir, ".lfsconfig", "[lfs]\n\turl = "+server.URL+"/lfs\n")
+	hash := commitFile(t, repo, dir, ".catalogue/filemaps/root.all/usr/bin/foo", pointer)
+
+	log := internal.NewLog(&DoNothingLogger{})
+	remote := config.Remote{Protocol: config.Git, URL: u("file://" + dir)}
+	vcs := newGitVCS(remote, log, ext.NewAPI("/"), t.TempDir(), internal.Credential{})
+	for range 2 {
+		local := t.TempDir()
+		_, err = vcs.Fetch(config.Pin{CommitHash: hash}, []string{".catalogue"}, ext.NewDisk(local))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filepath.Join(local, ".catalogue", "filemaps", "root.all", "usr", "bin", "foo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "large binary" {
+			t.Fatalf("expected lfs content, got '%s'", data)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("expected lfs object to be downloaded once, got %d batch requests", requests)
+	}
+}
+
+func TestSubmoduleRemote(t *testing.T) {
+	tests := []struct {
+		parent   string
+		value    string
+		expected config.Remote
+	}{
+		{
+			parent:   "https://github.com/foo/bar.git",
+			value:    "../baz.git",
+			expected: config.Remote{Protocol: config.Git, URL: u("https://github.com/foo/baz.git")},
+		},
+		{
+			parent:   "https://github.com/foo/bar",
+			value:    "./sub",
+			expected: config.Remote{Protocol: config.Git, URL: u("https://github.com/foo/bar/sub")},
+		},
+		{
+			parent:   "file:///src/bar",
+			value:    "/src/baz",
+			expected: config.Remote{Protocol: config.Local, URL: u("file:///src/baz")},
+		},
+		{
+			parent:   "file:///src/bar",
+			value:    "https://gitlab.com/foo/baz.git",
+			expected: config.Remote{Protocol: config.Git, URL: u("https://gitlab.com/foo/baz.git")},
+		},
+	}
+	for _, test := range tests {
+		actual, err := submoduleRemote(u(test.parent), test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(test.expected, actual); diff != "" {
+			t.Fatalf("submodule remote of '%s' mismatch (-want +got):\n%s", test.value, diff)
+		}
+	}
+
+	_, err := submoduleRemote(u("https://github.com/foo/bar"), "git@github.com:foo/baz.git")
+	if err == nil {
+		t.Fatal("expected scp-like submodule url to be rejected")
+	}
+
+	for _, value := range []string{"/etc/secrets", "file:///root/private.git"} {
+		_, err = submoduleRemote(u("https://github.com/foo/bar"), value)
+		if err == nil {
+			t.Fatalf("expected local submodule url '%s' of a remote repository to be rejected", value)
+		}
+	}
+}
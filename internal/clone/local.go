@@ -0,0 +1,200 @@
+package clone
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	gitlib "github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// localVCS reads from a directory on this machine without touching the
+// network. Git repositories, bare or not, are read at their tags. Plain
+// directories have a single version, their current contents.
+type localVCS struct {
+	remote  config.Remote
+	log     *internal.Log
+	api     *ext.API
+	mirrors string
+}
+
+func (vcs *localVCS) Remote() config.Remote {
+	return vcs.remote
+}
+
+func (vcs *localVCS) ListVersions() ([]config.Pin, error) {
+	repo, found, err := vcs.open()
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return listTags(repo)
+	}
+	pin, err := copyDir(vcs.remote.URL.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return []config.Pin{pin}, nil
+}
+
+func (vcs *localVCS) Fetch(pin config.Pin, paths []string, dst ext.Disk) (config.Pin, error) {
+	repo, found, err := vcs.open()
+	if err != nil {
+		return config.Pin{}, err
+	}
+	if found {
+		for _, subpath := range paths {
+			err = writeTree(repo, plumbing.NewHash(pin.CommitHash), subpath, dst, vcs)
+			if err != nil {
+				return config.Pin{}, err
+			}
+		}
+		pin.Date, err = commitDate(repo, plumbing.NewHash(pin.CommitHash))
+		if err != nil {
+			return config.Pin{}, err
+		}
+		return pin, nil
+	}
+
+	dir := vcs.remote.URL.Path
+	current, err := copyDir(dir, nil)
+	if err != nil {
+		return config.Pin{}, err
+	}
+	for _, subpath := range paths {
+		target := dst.Path(subpath)
+		if info, err := os.Stat(filepath.Join(dir, subpath)); err == nil && !info.IsDir() {
+			target = dst.Path(filepath.Dir(subpath))
+		}
+		_, err = copyDir(filepath.Join(dir, subpath), ext.NewDisk(string(target)))
+		if err != nil {
+			return config.Pin{}, err
+		}
+	}
+	return current, nil
+}
+
+func (vcs *localVCS) Author(pin config.Pin) (string, error) {
+	repo, found, err := vcs.open()
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "", internal.Err("directory '%s' is not a git repository", vcs.remote.URL.Path)
+	}
+	return commitAuthor(repo, plumbing.NewHash(pin.CommitHash))
+}
+
+func (vcs *localVCS) open() (*gitlib.Repository, bool, error) {
+	repo, err := gitlib.PlainOpen(vcs.remote.URL.Path)
+	if err == gitlib.ErrRepositoryNotExists {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, internal.ErrOf(err, "can not open repository at '%s'", vcs.remote.URL.Path)
+	}
+	return repo, true, nil
+}
+
+// copyDir copies src, a directory or a single file, into dst, or only
+// digests it when dst is nil. With no tags to go by, the version of a plain
+// directory is taken from when it last changed, and ends in a digest of its
+// contents which is also its commit hash. A deleted file changes its
+// directory and a file restored with an older modification time still
+// changes when its status changed, so the version increases with every
+// change.
+func copyDir(src string, dst ext.Disk) (config.Pin, error) {
+	hasher := sha256.New()
+	var newest int64
+
+	symlinks := make(map[string]string)
+	err := filepath.WalkDir(src, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if changed := changeTime(info); changed > newest {
+			newest = changed
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		relative, _ := filepath.Rel(src, path)
+		if relative == "." {
+			// src is a single file
+			relative = filepath.Base(path)
+		}
+		hasher.Write([]byte(relative))
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			hasher.Write([]byte(target))
+			if dst != nil {
+				symlinks[relative] = target
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if dst == nil {
+			_, err = io.Copy(hasher, file)
+			return err
+		}
+		err = dst.WriteFile(dst.Path(relative), io.TeeReader(file, hasher))
+		if err != nil {
+			return err
+		}
+		return dst.Chmod(dst.Path(relative), info.Mode().Perm())
+	})
+	if err != nil {
+		return config.Pin{}, internal.ErrOf(err, "can not copy directory '%s'", src)
+	}
+
+	for relative, target := range symlinks {
+		err = dst.Symlink(dst.Path(relative), target)
+		if err != nil {
+			return config.Pin{}, err
+		}
+	}
+
+	digest := hex.EncodeToString(hasher.Sum(nil))
+	pin := config.Pin{
+		VersionName: fmt.Sprintf("0.0.0+local.%d.%s", newest, digest[:12]),
+		CommitHash:  digest,
+		Date:        time.Unix(newest, 0).UTC(),
+	}
+	return pin, nil
+}
+
+// changeTime is the latest of when a file was modified and when its status
+// changed.
+func changeTime(info os.FileInfo) int64 {
+	changed := info.ModTime().Unix()
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Ctim.Sec > changed {
+		changed = stat.Ctim.Sec
+	}
+	return changed
+}
@@ -0,0 +1,182 @@
+package clone
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	gitlib "github.com/go-git/go-git/v6"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+type DoNothingLogger struct {
+}
+
+func (log *DoNothingLogger) Log(stmt *internal.LogStatement) {
+
+}
+
+func commitFile(t *testing.T, repo *gitlib.Repository, dir string, name string, contents string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	err := os.WriteFile(path, []byte(contents), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = worktree.Add(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := &object.Signature{Name: "Bob Doe", Email: "bob@foo.com", When: time.Now()}
+	hash, err := worktree.Commit("commit "+name, &gitlib.CommitOptions{Author: signature})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash.String()
+}
+
+func TestLocalCloneReadsTaggedCommit(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := gitlib.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tagged := commitFile(t, repo, dir, ".catalogue/config.toml", "name='tagged'")
+	head, _ := repo.Head()
+	_, err = repo.CreateTag("v1.2.0", head.Hash(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, repo, dir, ".catalogue/config.toml", "name='head'")
+
+	local := filepath.Join(t.TempDir(), "clone")
+	vcs := &localVCS{remote: config.Remote{Protocol: config.Local, URL: u("file://" + dir)}}
+	pin, author, ok := Clone(vcs, NewOpts(local, ".catalogue", nil), internal.NewLog(&DoNothingLogger{}), ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected local clone to succeed")
+	}
+
+	if pin.VersionName != "1.2.0" || pin.CommitHash != tagged {
+		t.Fatalf("unexpected pin %v, expected commit '%s'", pin, tagged)
+	}
+	if author != "Bob Doe <bob@foo.com>" {
+		t.Fatalf("unexpected author '%s'", author)
+	}
+
+	data, err := os.ReadFile(filepath.Join(local, ".catalogue", "config.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "name='tagged'" {
+		t.Fatalf("expected tagged config.toml, got '%s'", data)
+	}
+}
+
+func TestLocalCloneCopiesDirectory(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".catalogue", "filemaps", "root.all", "bin"), 0755)
+	os.WriteFile(filepath.Join(dir, ".catalogue", "config.toml"), []byte("name='foo'"), 0644)
+	os.WriteFile(filepath.Join(dir, ".catalogue", "filemaps", "root.all", "bin", "foo"), []byte("#!/bin/sh"), 0755)
+
+	local := filepath.Join(t.TempDir(), "clone")
+	vcs := &localVCS{remote: config.Remote{Protocol: config.Local, URL: u("file://" + dir)}}
+	pin, _, ok := Clone(vcs, NewOpts(local, ".catalogue", nil), internal.NewLog(&DoNothingLogger{}), ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected local clone to succeed")
+	}
+	if len(pin.CommitHash) != 64 {
+		t.Fatalf("expected content digest as commit hash, got '%s'", pin.CommitHash)
+	}
+
+	info, err := os.Stat(filepath.Join(local, ".catalogue", "filemaps", "root.all", "bin", "foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Fatalf("expected executable bit to be kept, got %s", info.Mode())
+	}
+}
+
+func TestReadFileFetchesSingleFile(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := gitlib.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, repo, dir, "docs/other.md", "other")
+	commitFile(t, repo, dir, ".catalogue/config.toml", "name='foo'")
+	hash := commitFile(t, repo, dir, "README.md", "# foo")
+
+	local := filepath.Join(t.TempDir(), "clone")
+	vcs := &localVCS{remote: config.Remote{Protocol: config.Local, URL: u("file://" + dir)}}
+	data, err := ReadFile(vcs, config.Pin{CommitHash: hash}, local, "README.md", ext.NewAPI("/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "# foo" {
+		t.Fatalf("unexpected README.md '%s'", data)
+	}
+	if _, err = os.Stat(filepath.Join(local, "docs")); !os.IsNotExist(err) {
+		t.Fatal("expected only README.md to be fetched")
+	}
+
+	os.RemoveAll(filepath.Join(dir, ".git"))
+	local = filepath.Join(t.TempDir(), "clone")
+	data, err = ReadFile(vcs, config.Pin{}, local, "docs/other.md", ext.NewAPI("/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "other" {
+		t.Fatalf("unexpected docs/other.md '%s'", data)
+	}
+}
+
+// Deleting a file or putting back one with an older modification time must
+// still give a newer version.
+func TestCopyDirVersionIncreases(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.txt"), []byte("b"), 0644)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(dir, "a.txt"), old, old)
+
+	first, err := copyDir(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the version only has second precision
+	time.Sleep(1100 * time.Millisecond)
+	os.Remove(filepath.Join(dir, "b.txt"))
+	deleted, err := copyDir(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted.CommitHash == first.CommitHash {
+		t.Fatal("expected the digest to change when a file is deleted")
+	}
+	if internal.CompareDebVersions(deleted.VersionName, first.VersionName) <= 0 {
+		t.Fatalf("expected '%s' after deleting a file to be newer than '%s'", deleted.VersionName, first.VersionName)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("older a"), 0644)
+	os.Chtimes(filepath.Join(dir, "a.txt"), old, old)
+	restored, err := copyDir(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if internal.CompareDebVersions(restored.VersionName, deleted.VersionName) <= 0 {
+		t.Fatalf("expected '%s' after restoring an older file to be newer than '%s'", restored.VersionName, deleted.VersionName)
+	}
+}
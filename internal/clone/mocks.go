@@ -0,0 +1,54 @@
+package clone
+
+import (
+	"path"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// MockVCS is an in memory remote. Files holds the contents of every file,
+// by path, of each commit hash in Versions.
+type MockVCS struct {
+	Source   config.Remote
+	Versions []config.Pin
+	Files    map[string]map[string]string
+	Authors  map[string]string
+}
+
+func (mock *MockVCS) Remote() config.Remote {
+	return mock.Source
+}
+
+func (mock *MockVCS) ListVersions() ([]config.Pin, error) {
+	return mock.Versions, nil
+}
+
+func (mock *MockVCS) Fetch(pin config.Pin, paths []string, dst ext.Disk) (config.Pin, error) {
+	files, found := mock.Files[pin.CommitHash]
+	if !found {
+		return config.Pin{}, internal.Err("unknown commit '%s'", pin.CommitHash)
+	}
+	for name, contents := range files {
+		for _, subpath := range paths {
+			if name != subpath && !strings.HasPrefix(name, path.Clean(subpath)+"/") {
+				continue
+			}
+			err := dst.WriteFile(dst.Path(name), strings.NewReader(contents))
+			if err != nil {
+				return config.Pin{}, err
+			}
+		}
+	}
+	return pin, nil
+}
+
+func (mock *MockVCS) Author(pin config.Pin) (string, error) {
+	author, found := mock.Authors[pin.CommitHash]
+	if !found {
+		return "", internal.Err("no author for '%s'", pin.CommitHash)
+	}
+	return author, nil
+}
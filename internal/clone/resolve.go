@@ -0,0 +1,155 @@
+package clone
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// lfs downloads an object from the LFS server of the remote, objects are kept
+// next to the mirror so a version is only ever downloaded once.
+func (vcs *gitVCS) lfs(commit *object.Commit, pointer internal.LFSPointer) ([]byte, error) {
+	store := ""
+	if dir := vcs.mirrorDir(); len(dir) != 0 {
+		store = filepath.Join(dir, "lfs", "objects")
+		if data, found := readLFSStore(store, pointer); found {
+			vcs.log.Info(9, "lfs object '%s' is already mirrored", pointer.OID)
+			return data, nil
+		}
+	}
+
+	endpoint, err := lfsEndpoint(commit, vcs.remote.URL)
+	if err != nil {
+		return nil, err
+	}
+	credential := vcs.credential
+	if endpoint.Hostname() != vcs.remote.URL.Hostname() {
+		credential = remoteCredential(config.Remote{URL: endpoint}, vcs.log, vcs.api)
+	}
+
+	vcs.log.Info(9, "fetching lfs object '%s' from '%s'", pointer.OID, endpoint.Redacted())
+	data, err := fetchLFS(endpoint, pointer, credential, vcs.api)
+	if err != nil {
+		return nil, err
+	}
+	if len(store) != 0 {
+		err = writeLFSStore(store, pointer, data)
+		if err != nil {
+			vcs.log.Info(8, "not keeping lfs object: %s", err.Error())
+		}
+	}
+	return data, nil
+}
+
+func (vcs *gitVCS) submodule(path string, moduleURL string, hash plumbing.Hash, dst ext.Disk) error {
+	remote, err := submoduleRemote(vcs.remote.URL, moduleURL)
+	if err != nil {
+		return err
+	}
+	return fetchSubmodule(remote, hash, dst, vcs.log, vcs.api, vcs.mirrors)
+}
+
+// lfs reads an object from the LFS store of the repository, git-lfs puts
+// every object it has checked out there.
+func (vcs *localVCS) lfs(commit *object.Commit, pointer internal.LFSPointer) ([]byte, error) {
+	data, found := readLFSStore(filepath.Join(vcs.gitDir(), "lfs", "objects"), pointer)
+	if !found {
+		return nil, internal.Err("lfs object '%s' is not in '%s', run 'git lfs fetch' there first", pointer.OID, vcs.remote.URL.Path)
+	}
+	return data, nil
+}
+
+// submodule reads a submodule from where git keeps its checkout, or else
+// from its URL.
+func (vcs *localVCS) submodule(path string, moduleURL string, hash plumbing.Hash, dst ext.Disk) error {
+	modules := filepath.Join(vcs.gitDir(), "modules", filepath.FromSlash(path))
+	info, err := os.Stat(modules)
+	if err == nil && info.IsDir() {
+		remote := config.Remote{Protocol: config.Local, URL: &url.URL{Scheme: "file", Path: modules}}
+		return fetchSubmodule(remote, hash, dst, vcs.log, vcs.api, vcs.mirrors)
+	}
+
+	remote, err := submoduleRemote(vcs.remote.URL, moduleURL)
+	if err != nil {
+		return err
+	}
+	return fetchSubmodule(remote, hash, dst, vcs.log, vcs.api, vcs.mirrors)
+}
+
+// gitDir is the .git directory of the repository, or the repository itself
+// when it is bare.
+func (vcs *localVCS) gitDir() string {
+	dir := filepath.Join(vcs.remote.URL.Path, ".git")
+	info, err := os.Stat(dir)
+	if err == nil && info.IsDir() {
+		return dir
+	}
+	return vcs.remote.URL.Path
+}
+
+// fetchSubmodule writes the whole tree of a submodule commit into dst.
+func fetchSubmodule(remote config.Remote, hash plumbing.Hash, dst ext.Disk, log *internal.Log, api *ext.API, mirrors string) error {
+	var vcs VCS
+	switch remote.Protocol {
+	case config.Local:
+		local := &localVCS{remote: remote, log: log, api: api, mirrors: mirrors}
+		_, found, err := local.open()
+		if err != nil {
+			return err
+		}
+		if !found {
+			return internal.Err("submodule '%s' is not a git repository", remote.URL.Path)
+		}
+		vcs = local
+	default:
+		vcs = newGitVCS(remote, log, api, mirrors, remoteCredential(remote, log, api))
+	}
+	log.Info(9, "fetching submodule '%s' at '%s'", remote.URL.Redacted(), hash)
+	_, err := vcs.Fetch(config.Pin{CommitHash: hash.String()}, []string{""}, dst)
+	return err
+}
+
+// submoduleRemote resolves the URL of a submodule from .gitmodules, relative
+// URLs are relative to the remote of the repository that has the submodule.
+// Only a submodule of a local repository may itself be local, otherwise a
+// remote repository could have any repository on the host copied into a
+// package.
+func submoduleRemote(parent *url.URL, value string) (config.Remote, error) {
+	var resolved *url.URL
+	switch {
+	case strings.HasPrefix(value, "./") || strings.HasPrefix(value, "../"):
+		base := *parent
+		base.Path = strings.TrimSuffix(base.Path, "/") + "/"
+		relative, err := url.Parse(value)
+		if err != nil {
+			return config.Remote{}, internal.ErrOf(err, "invalid submodule url '%s'", value)
+		}
+		resolved = base.ResolveReference(relative)
+	case filepath.IsAbs(value):
+		resolved = &url.URL{Scheme: "file", Path: filepath.Clean(value)}
+	default:
+		parsed, err := url.Parse(value)
+		if err != nil || len(parsed.Scheme) == 0 {
+			return config.Remote{}, internal.Err("unsupported submodule url '%s'", value)
+		}
+		resolved = parsed
+	}
+
+	switch resolved.Scheme {
+	case "file":
+		if parent.Scheme != "file" {
+			return config.Remote{}, internal.Err("submodule url '%s' is local but '%s' is not", value, parent.Redacted())
+		}
+		return config.Remote{Protocol: config.Local, URL: resolved}, nil
+	case "http", "https", "ssh", "git":
+		return config.Remote{Protocol: config.Git, URL: resolved}, nil
+	}
+	return config.Remote{}, internal.Err("unsupported submodule url '%s'", value)
+}
@@ -0,0 +1,243 @@
+package clone
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	semverlib "github.com/Masterminds/semver/v3"
+	gitlib "github.com/go-git/go-git/v6"
+	gitconfig "github.com/go-git/go-git/v6/config"
+	"github.com/go-git/go-git/v6/plumbing"
+	"github.com/go-git/go-git/v6/plumbing/filemode"
+	"github.com/go-git/go-git/v6/plumbing/object"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// resolver fetches what a commit only points to: the content of git-lfs
+// pointers and submodules.
+type resolver interface {
+	lfs(commit *object.Commit, pointer internal.LFSPointer) ([]byte, error)
+	submodule(path string, url string, hash plumbing.Hash, dst ext.Disk) error
+}
+
+// writeTree writes the files under subpath of a commit into dst, or only
+// subpath when it is a file, keeping the executable bit and symlinks. It
+// works on bare repositories as well. LFS pointers and submodules are
+// fetched through the resolver, when it is nil pointers are written as they
+// are.
+func writeTree(repo *gitlib.Repository, hash plumbing.Hash, subpath string, dst ext.Disk, resolver resolver) error {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return internal.ErrOf(err, "can not find commit '%s'", hash)
+	}
+
+	root, err := commit.Tree()
+	if err != nil {
+		return internal.ErrOf(err, "can not read tree of commit '%s'", hash)
+	}
+
+	tree := root
+	subpath = strings.Trim(path.Clean("/"+subpath), "/")
+	// a single file is written by walking only the directory it is in
+	only := ""
+	if len(subpath) != 0 {
+		entry, err := root.FindEntry(subpath)
+		if err != nil {
+			return internal.ErrOf(err, "commit '%s' has no '%s'", hash, subpath)
+		}
+		if entry.Mode.IsFile() {
+			only = path.Base(subpath)
+			subpath = path.Dir(subpath)
+			if subpath == "." {
+				subpath = ""
+			}
+		}
+	}
+	if len(subpath) != 0 {
+		tree, err = root.Tree(subpath)
+		if err != nil {
+			return internal.ErrOf(err, "commit '%s' has no directory '%s'", hash, subpath)
+		}
+	}
+
+	// symlinks are created last so no file is ever written through one
+	symlinks := make(map[string]string)
+	submodules := make(map[string]plumbing.Hash)
+	walker := object.NewTreeWalker(tree, len(only) == 0, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return internal.ErrOf(err, "can not walk tree of commit '%s'", hash)
+		}
+		if len(only) != 0 && name != only {
+			continue
+		}
+
+		repoPath := path.Join(subpath, name)
+		filePath := dst.Path(subpath, name)
+		switch entry.Mode {
+		case filemode.Dir:
+			continue
+		case filemode.Submodule:
+			submodules[repoPath] = entry.Hash
+			continue
+		}
+
+		blob, err := repo.BlobObject(entry.Hash)
+		if err != nil {
+			return internal.ErrOf(err, "can not read file '%s'", name)
+		}
+		reader, err := blob.Reader()
+		if err != nil {
+			return internal.ErrOf(err, "can not read file '%s'", name)
+		}
+
+		if entry.Mode == filemode.Symlink || blob.Size <= internal.LFSPointerMaxSize {
+			data, err := io.ReadAll(reader)
+			reader.Close()
+			if err != nil {
+				return internal.ErrOf(err, "can not read file '%s'", name)
+			}
+			if entry.Mode == filemode.Symlink {
+				symlinks[string(filePath)] = string(data)
+				continue
+			}
+			if pointer, isPointer := internal.ParseLFSPointer(data); isPointer && resolver != nil {
+				data, err = resolver.lfs(commit, pointer)
+				if err != nil {
+					return internal.ErrOf(err, "can not fetch git-lfs file '%s'", repoPath)
+				}
+			}
+			err = dst.WriteFile(filePath, bytes.NewReader(data))
+		} else {
+			err = dst.WriteFile(filePath, reader)
+			reader.Close()
+		}
+		if err != nil {
+			return err
+		}
+		mode := os.FileMode(0644)
+		if entry.Mode == filemode.Executable {
+			mode = 0755
+		}
+		err = dst.Chmod(filePath, mode)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(submodules) != 0 {
+		if resolver == nil {
+			return internal.Err("commit '%s' has submodules under '%s' that can not be fetched", hash, subpath)
+		}
+		urls, err := submoduleURLs(root)
+		if err != nil {
+			return err
+		}
+		for modulePath, moduleHash := range submodules {
+			url, found := urls[modulePath]
+			if !found {
+				return internal.Err("submodule '%s' is not in .gitmodules", modulePath)
+			}
+			err = resolver.submodule(modulePath, url, moduleHash, ext.NewDisk(string(dst.Path(modulePath))))
+			if err != nil {
+				return internal.ErrOf(err, "can not fetch submodule '%s'", modulePath)
+			}
+		}
+	}
+
+	for filePath, target := range symlinks {
+		err = dst.Symlink(ext.DiskPath(filePath), target)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// submoduleURLs reads the URL of every submodule, by path, from .gitmodules.
+func submoduleURLs(root *object.Tree) (map[string]string, error) {
+	file, err := root.File(".gitmodules")
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not find .gitmodules")
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not read .gitmodules")
+	}
+
+	modules := gitconfig.NewModules()
+	err = modules.Unmarshal([]byte(contents))
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not parse .gitmodules")
+	}
+
+	urls := make(map[string]string)
+	for _, module := range modules.Submodules {
+		urls[path.Clean(module.Path)] = module.URL
+	}
+	return urls, nil
+}
+
+func commitAuthor(repo *gitlib.Repository, hash plumbing.Hash) (string, error) {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return "", internal.ErrOf(err, "can not find commit '%s'", hash)
+	}
+	return fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email), nil
+}
+
+// commitDate is when a commit was made, which is when it was committed rather
+// than authored so that rebased commits are dated to the rebase.
+func commitDate(repo *gitlib.Repository, hash plumbing.Hash) (time.Time, error) {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return time.Time{}, internal.ErrOf(err, "can not find commit '%s'", hash)
+	}
+	return commit.Committer.When.UTC(), nil
+}
+
+// listTags returns a pin for every semantic versioned tag of a repository,
+// annotated tags are peeled to their commit.
+func listTags(repo *gitlib.Repository) ([]config.Pin, error) {
+	tags, err := repo.Tags()
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not get repository tags")
+	}
+	defer tags.Close()
+
+	var pins []config.Pin
+	err = tags.ForEach(func(ref *plumbing.Reference) error {
+		version, err := semverlib.NewVersion(ref.Name().Short())
+		if err != nil {
+			return nil
+		}
+
+		hash := ref.Hash()
+		tagObj, err := repo.TagObject(hash)
+		if err == nil {
+			commit, err := tagObj.Commit()
+			if err != nil {
+				return nil
+			}
+			hash = commit.Hash
+		}
+		pins = append(pins, config.Pin{VersionName: version.String(), CommitHash: hash.String()})
+		return nil
+	})
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not read repository tags")
+	}
+	return pins, nil
+}
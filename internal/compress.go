@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+
+	"github.com/klauspost/compress/zstd"
+	xzlib "github.com/ulikunitz/xz"
+)
+
+func XZ(in []byte) ([]byte, error) {
+
+	var compressed bytes.Buffer
+
+	writer, err := xzlib.NewWriter(&compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = writer.Write(in)
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	// the stream is only complete once the writer is closed
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return compressed.Bytes(), nil
+}
+
+func Gzip(in []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+
+	writer, err := gzip.NewWriterLevel(&compressed, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = writer.Write(in)
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return compressed.Bytes(), nil
+}
+
+func Zstd(in []byte) ([]byte, error) {
+	writer, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	defer writer.Close()
+	return writer.EncodeAll(in, nil), nil
+}
@@ -0,0 +1,44 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	xzlib "github.com/ulikunitz/xz"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	in := []byte(strings.Repeat("Package: foo\nVersion: 1.0.0\n\n", 100))
+
+	decompress := map[string]func([]byte) (io.Reader, error){
+		"xz": func(data []byte) (io.Reader, error) { return xzlib.NewReader(bytes.NewReader(data)) },
+		"gz": func(data []byte) (io.Reader, error) { return gzip.NewReader(bytes.NewReader(data)) },
+		"zst": func(data []byte) (io.Reader, error) {
+			decoder, err := zstd.NewReader(bytes.NewReader(data))
+			return decoder, err
+		},
+	}
+	compress := map[string]func([]byte) ([]byte, error){"xz": XZ, "gz": Gzip, "zst": Zstd}
+
+	for name, fn := range compress {
+		compressed, err := fn(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader, err := decompress[name](compressed)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		out, err := io.ReadAll(reader)
+		if err != nil {
+			t.Fatalf("%s stream is not complete: %s", name, err)
+		}
+		if !bytes.Equal(out, in) {
+			t.Fatalf("%s did not round trip", name)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package internal
 
 import (
 	"io"
+	"net/url"
+	"regexp"
 	"strings"
 
 	pgplib "github.com/ProtonMail/go-crypto/openpgp"
@@ -12,19 +14,45 @@ type Config struct {
 	DefaultUser      string
 	APTDistroVersion string
 	Port             int
+	Anchors          map[string]string
+	Aliases          map[string]string
+	HomeUsers        []string
+	HomeSkel         bool
+	Repositories     map[string]RepositoryConfig
+	Listen           []string
+	TLS              TLSConfig
+	Auth             AuthConfig
 	PrivateAPTKey    *pgplib.Entity
 }
 
 const DefaultPort = 6111
 
+// userNamePattern is what useradd accepts as a user or group name by
+// default.
+var userNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]*[$]?$`)
+
+// ValidUserName is whether name is a user or group name that is safe to put
+// into a maintainer script.
+func ValidUserName(name string) bool {
+	return userNamePattern.MatchString(name)
+}
+
 func DefaultConfig() Config {
 	return Config{Port: DefaultPort}
 }
 
 type ConfigTOML struct {
-	DefaultUser      string `toml:"default_user"`
-	APTDistroVersion string `toml:"apt_distro_version"`
-	Port             int    `toml:"port"`
+	DefaultUser      string                          `toml:"default_user"`
+	APTDistroVersion string                          `toml:"apt_distro_version"`
+	Port             int                             `toml:"port"`
+	Anchors          map[string]string               `toml:"anchors,omitempty"`
+	Aliases          map[string]string               `toml:"aliases,omitempty"`
+	HomeUsers        []string                        `toml:"home_users,omitempty"`
+	HomeSkel         bool                            `toml:"home_skel"`
+	Repositories     map[string]RepositoryConfigTOML `toml:"repositories,omitempty"`
+	Listen           []string                        `toml:"listen,omitempty"`
+	TLS              TLSConfigTOML                   `toml:"tls,omitempty"`
+	Auth             AuthConfigTOML                  `toml:"auth,omitempty"`
 }
 
 func SerializeConfig(dst io.Writer, config Config) error {
@@ -32,6 +60,17 @@ func SerializeConfig(dst io.Writer, config Config) error {
 		DefaultUser:      config.DefaultUser,
 		APTDistroVersion: config.APTDistroVersion,
 		Port:             config.Port,
+		Anchors:          config.Anchors,
+		Aliases:          config.Aliases,
+		HomeUsers:        config.HomeUsers,
+		HomeSkel:         config.HomeSkel,
+		Repositories:     toRepositoriesTOML(config.Repositories),
+		Listen:           config.Listen,
+		TLS:              TLSConfigTOML{Cert: config.TLS.Cert, Key: config.TLS.Key, SelfSigned: config.TLS.SelfSigned},
+		Auth:             AuthConfigTOML{Mode: string(config.Auth.Mode), ClientCA: config.Auth.ClientCA},
+	}
+	if !config.Auth.TrustHost {
+		toml.Auth.TrustHost = &config.Auth.TrustHost
 	}
 
 	return tomllib.NewEncoder(dst).Encode(&toml)
@@ -47,6 +86,15 @@ func ParseConfig(src io.Reader) (Config, error) {
 	config := Config{
 		DefaultUser:      strings.TrimSpace(toml.DefaultUser),
 		APTDistroVersion: strings.TrimSpace(toml.APTDistroVersion),
+		HomeSkel:         toml.HomeSkel,
+	}
+
+	for _, user := range toml.HomeUsers {
+		user = strings.TrimSpace(user)
+		if !ValidUserName(user) {
+			return Config{}, Err("invalid home user '%s'", user)
+		}
+		config.HomeUsers = append(config.HomeUsers, user)
 	}
 
 	if toml.Port < 1024 {
@@ -55,6 +103,63 @@ func ParseConfig(src io.Reader) (Config, error) {
 		config.Port = toml.Port
 	}
 
+	for name, path := range toml.Anchors {
+		name = strings.TrimSpace(name)
+		path = strings.TrimSpace(path)
+		err := ValidateName(name)
+		if err != nil {
+			return Config{}, ErrOf(err, "invalid anchor name '%s'", name)
+		}
+		if !strings.HasPrefix(path, "/") && path != "~" && !strings.HasPrefix(path, "~/") {
+			return Config{}, Err("anchor '%s' path '%s' must be absolute or start with '~/'", name, path)
+		}
+		if config.Anchors == nil {
+			config.Anchors = make(map[string]string)
+		}
+		config.Anchors[name] = path
+	}
+
+	for name, base := range toml.Aliases {
+		name = strings.TrimSpace(name)
+		base = strings.TrimSpace(base)
+		err := ValidateName(name)
+		if err != nil || len(name) == 0 {
+			return Config{}, ErrOf(err, "invalid alias name '%s'", name)
+		}
+		parsed, err := url.Parse(base)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http" && parsed.Scheme != "ssh") || len(parsed.Host) == 0 {
+			return Config{}, Err("alias '%s' must be an https, http or ssh URL, not '%s'", name, base)
+		}
+		if _, hasPassword := parsed.User.Password(); hasPassword {
+			return Config{}, Err("alias '%s' must not contain a password", name)
+		}
+		if !strings.HasSuffix(base, "/") {
+			base += "/"
+		}
+		if config.Aliases == nil {
+			config.Aliases = make(map[string]string)
+		}
+		config.Aliases[name] = base
+	}
+
+	config.Repositories, err = loadRepositories(toml.Repositories)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.Listen, err = loadListen(toml.Listen, config.Port)
+	if err != nil {
+		return Config{}, err
+	}
+	config.TLS, err = loadTLS(toml.TLS)
+	if err != nil {
+		return Config{}, err
+	}
+	config.Auth, err = loadAuth(toml.Auth, config.TLS)
+	if err != nil {
+		return Config{}, err
+	}
+
 	return config, nil
 
 }
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+)
+
+// Build is a build from source, its commands run from the root of the tree
+// with $STAGE as the directory to install into, as in
+// 'make install DESTDIR=$STAGE'.
+type Build struct {
+	Commands []string
+	Network  bool
+	// Depends is what must be installed to build, in Build-Depends syntax.
+	Depends string
+}
+
+type BuildTOML struct {
+	Commands []string `toml:"commands"`
+	Network  bool     `toml:"network"`
+	Depends  string   `toml:"build_depends"`
+}
+
+func loadBuild(deserialized *BuildTOML) (*Build, error) {
+	if deserialized == nil {
+		return nil, nil
+	}
+	build := Build{Network: deserialized.Network, Depends: strings.TrimSpace(deserialized.Depends)}
+	_, err := internal.ParseDebRelations(build.Depends)
+	if err != nil {
+		return nil, internal.ErrOf(err, "invalid build_depends")
+	}
+	for _, command := range deserialized.Commands {
+		command = strings.TrimSpace(command)
+		if len(command) == 0 {
+			return nil, internal.Err("build commands can not be empty")
+		}
+		build.Commands = append(build.Commands, command)
+	}
+	if len(build.Commands) == 0 {
+		return nil, internal.Err("missing build commands")
+	}
+	return &build, nil
+}
+
+func toBuildTOML(build *Build) *BuildTOML {
+	return &BuildTOML{Commands: build.Commands, Network: build.Network, Depends: build.Depends}
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseBuild(t *testing.T) {
+	input := `
+name='foo'
+type='package'
+
+[build]
+commands=['  make  ', 'make install DESTDIR=$STAGE']
+network=true
+build_depends='make, gcc (>= 10)'
+`
+	actual, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Build{Commands: []string{"make", "make install DESTDIR=$STAGE"}, Network: true, Depends: "make, gcc (>= 10)"}
+	if diff := cmp.Diff(actual.Build, expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+
+	_, err = loadBuild(&BuildTOML{})
+	if err == nil {
+		t.Fatal("expected build without commands to fail")
+	}
+
+	_, err = loadBuild(&BuildTOML{Commands: []string{"make"}, Depends: "gcc (~ 10)"})
+	if err == nil {
+		t.Fatal("expected invalid build_depends to fail")
+	}
+}
@@ -28,6 +28,9 @@ type ComponentTOML struct {
 	Metadata         map[string]MetadataTOML            `toml:"metadata"`
 	Target           map[string]TargetTOML              `toml:"target"`
 	Download         map[string]map[string]DownloadTOML `toml:"download"`
+	Files            map[string]FileTOML                `toml:"files"`
+	Service          map[string]ServiceTOML             `toml:"service"`
+	Build            *BuildTOML                         `toml:"build"`
 }
 
 type Component struct {
@@ -38,6 +41,9 @@ type Component struct {
 	Targets          []internal.Target
 	Downloads        map[string][]*Download
 	FileMaps         map[string][]*FileMap
+	Files            []*File
+	Services         []*Service
+	Build            *Build
 }
 
 func Parse(src io.Reader) (Component, error) {
@@ -102,6 +108,18 @@ func load(deserialized *ComponentTOML) (Component, error) {
 	if err != nil {
 		return Component{}, internal.ErrOf(err, "invalid config metadata")
 	}
+	files, err := loadFiles(deserialized.Files)
+	if err != nil {
+		return Component{}, internal.ErrOf(err, "invalid config files")
+	}
+	services, err := loadServices(deserialized.Service)
+	if err != nil {
+		return Component{}, internal.ErrOf(err, "invalid config service")
+	}
+	build, err := loadBuild(deserialized.Build)
+	if err != nil {
+		return Component{}, internal.ErrOf(err, "invalid config build")
+	}
 	config := Component{
 		Name:             name,
 		Type:             ctype,
@@ -109,6 +127,9 @@ func load(deserialized *ComponentTOML) (Component, error) {
 		Targets:          targets,
 		Metadata:         metadatas,
 		Downloads:        downloads,
+		Files:            files,
+		Services:         services,
+		Build:            build,
 	}
 	return config, nil
 }
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+)
+
+type File struct {
+	Path    string
+	Mode    os.FileMode
+	Owner   string
+	Group   string
+	Symlink string
+}
+
+type FileTOML struct {
+	Mode    string `toml:"mode"`
+	Owner   string `toml:"owner"`
+	Group   string `toml:"group"`
+	Symlink string `toml:"symlink"`
+}
+
+func loadFiles(deserialized map[string]FileTOML) ([]*File, error) {
+	var files []*File
+	for path, toml := range deserialized {
+		file, err := toml.validate(strings.TrimSpace(path))
+		if err != nil {
+			return nil, internal.ErrOf(err, "invalid file '%s'", path)
+		}
+		files = append(files, &file)
+	}
+	return files, nil
+}
+
+func (toml *FileTOML) validate(path string) (File, error) {
+	if !strings.HasPrefix(path, "/") && !strings.HasPrefix(path, "~/") {
+		return File{}, internal.Err("path must be absolute or start with '~/'")
+	}
+	if path != filepath.Clean(path) {
+		return File{}, internal.Err("path must be clean, expected '%s'", filepath.Clean(path))
+	}
+
+	file := File{
+		Path:    path,
+		Owner:   strings.TrimSpace(toml.Owner),
+		Group:   strings.TrimSpace(toml.Group),
+		Symlink: strings.TrimSpace(toml.Symlink),
+	}
+
+	// owner and group are only resolved when the package is installed
+	for _, name := range []string{file.Owner, file.Group} {
+		if len(name) != 0 && !internal.ValidUserName(name) {
+			return File{}, internal.Err("invalid user or group name '%s'", name)
+		}
+	}
+
+	mode := strings.TrimSpace(toml.Mode)
+	if len(mode) != 0 {
+		value, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil || value > 0o7777 {
+			return File{}, internal.Err("invalid mode '%s', expected octal like '0755'", mode)
+		}
+		file.Mode = os.FileMode(value & 0o777)
+		if value&0o4000 != 0 {
+			file.Mode |= os.ModeSetuid
+		}
+		if value&0o2000 != 0 {
+			file.Mode |= os.ModeSetgid
+		}
+		if value&0o1000 != 0 {
+			file.Mode |= os.ModeSticky
+		}
+	}
+
+	if len(file.Symlink) != 0 && len(mode) != 0 {
+		return File{}, internal.Err("symlink can not have a mode")
+	}
+
+	if len(file.Symlink) == 0 && len(mode) == 0 && len(file.Owner) == 0 && len(file.Group) == 0 {
+		return File{}, internal.Err("must specify at least one of mode, owner, group or symlink")
+	}
+
+	return file, nil
+}
+
+func toFileTOML(file *File) FileTOML {
+	toml := FileTOML{
+		Owner:   file.Owner,
+		Group:   file.Group,
+		Symlink: file.Symlink,
+	}
+	if file.Mode != 0 {
+		value := uint32(file.Mode.Perm())
+		if file.Mode&os.ModeSetuid != 0 {
+			value |= 0o4000
+		}
+		if file.Mode&os.ModeSetgid != 0 {
+			value |= 0o2000
+		}
+		if file.Mode&os.ModeSticky != 0 {
+			value |= 0o1000
+		}
+		toml.Mode = "0" + strconv.FormatUint(uint64(value), 8)
+	}
+	return toml
+}
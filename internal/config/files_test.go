@@ -0,0 +1,74 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestLoadFiles(t *testing.T) {
+	deserialized := map[string]FileTOML{
+		"/usr/bin/foo": {
+			Mode:  " 0755 ",
+			Owner: " root ",
+			Group: " staff ",
+		},
+		"/usr/bin/bar": {
+			Symlink: "/opt/foo/bin/foo",
+		},
+		"~/.config/foo/token": {
+			Mode: "600",
+		},
+		"/usr/bin/sudoish": {
+			Mode: "4755",
+		},
+	}
+
+	actual, err := loadFiles(deserialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*File{
+		{Path: "/usr/bin/foo", Mode: 0755, Owner: "root", Group: "staff"},
+		{Path: "/usr/bin/bar", Symlink: "/opt/foo/bin/foo"},
+		{Path: "~/.config/foo/token", Mode: 0600},
+		{Path: "/usr/bin/sudoish", Mode: 0755 | os.ModeSetuid},
+	}
+
+	sortByPath := func(a, b *File) int {
+		return strings.Compare(a.Path, b.Path)
+	}
+
+	if diff := cmp.Diff(actual, expected, cmpopts.SortSlices(sortByPath)); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+
+	if toFileTOML(expected[3]).Mode != "04755" {
+		t.Fatalf("expected mode to serialize as '04755', got '%s'", toFileTOML(expected[3]).Mode)
+	}
+}
+
+func TestLoadFilesInvalid(t *testing.T) {
+	cases := map[string]map[string]FileTOML{
+		"relative":      {"usr/bin/foo": {Mode: "0755"}},
+		"unclean":       {"/usr/bin/../foo": {Mode: "0755"}},
+		"bad_mode":      {"/usr/bin/foo": {Mode: "rwx"}},
+		"symlink_mode":  {"/usr/bin/foo": {Mode: "0755", Symlink: "/opt/foo"}},
+		"nothing_given": {"/usr/bin/foo": {}},
+		"bad_owner":     {"/usr/bin/foo": {Owner: "root'; rm -rf /"}},
+		"bad_group":     {"/usr/bin/foo": {Group: "staff group"}},
+	}
+
+	for name, deserialized := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := loadFiles(deserialized)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+)
+
+// Forge expands a '{forge}/{path}' shorthand into the git remote of path.
+type Forge struct {
+	Base string
+	// Nested forges allow groups of groups, as in 'group/sub/repo'.
+	Nested bool
+	// OwnerPrefix is put in front of the owner when it is missing.
+	OwnerPrefix string
+	Suffix      string
+}
+
+var Forges = map[string]Forge{
+	"github":    {Base: "https://github.com/", Suffix: ".git"},
+	"gitlab":    {Base: "https://gitlab.com/", Nested: true, Suffix: ".git"},
+	"codeberg":  {Base: "https://codeberg.org/", Suffix: ".git"},
+	"bitbucket": {Base: "https://bitbucket.org/", Suffix: ".git"},
+	"sr.ht":     {Base: "https://git.sr.ht/", OwnerPrefix: "~"},
+}
+
+var shorthandSegment = regexp.MustCompile(`^[A-Za-z0-9_.~-]+$`)
+
+// ExpandShorthand turns a shorthand such as 'github/{owner}/{repo}' into a
+// git remote URL. Aliases from config.toml are checked before the built-in
+// forges so a self-hosted forge can take over a name.
+func ExpandShorthand(value string, aliases map[string]string) (string, error) {
+	name, path, found := strings.Cut(strings.TrimSpace(value), "/")
+	if !found {
+		return "", internal.Err("invalid component '%s', expected '{forge}/{owner}/{repo}'", value)
+	}
+
+	forge, known := Forges[name]
+	if base, isAlias := aliases[name]; isAlias {
+		forge, known = Forge{Base: base, Nested: true, Suffix: ".git"}, true
+	}
+	if !known {
+		return "", internal.Err("unknown forge '%s' in '%s'", name, value)
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segments) < 2 || (!forge.Nested && len(segments) != 2) {
+		if forge.Nested {
+			return "", internal.Err("invalid component '%s', expected '%s/{group}/.../{repo}'", value, name)
+		}
+		return "", internal.Err("invalid component '%s', expected '%s/{owner}/{repo}'", value, name)
+	}
+	for _, segment := range segments {
+		if !shorthandSegment.MatchString(segment) || segment == "." || segment == ".." {
+			return "", internal.Err("invalid path segment '%s' in '%s'", segment, value)
+		}
+	}
+
+	if !strings.HasPrefix(segments[0], forge.OwnerPrefix) {
+		segments[0] = forge.OwnerPrefix + segments[0]
+	}
+	remote := forge.Base + strings.Join(segments, "/")
+	if !strings.HasSuffix(remote, forge.Suffix) {
+		remote += forge.Suffix
+	}
+	return remote, nil
+}
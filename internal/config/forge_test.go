@@ -0,0 +1,44 @@
+package config
+
+import "testing"
+
+func TestExpandShorthand(t *testing.T) {
+	aliases := map[string]string{
+		"work":   "https://git.example.com/",
+		"gitlab": "https://gitlab.foo.com/",
+	}
+	tests := map[string]string{
+		"github/foo/bar":        "https://github.com/foo/bar.git",
+		"codeberg/foo/bar":      "https://codeberg.org/foo/bar.git",
+		"bitbucket/foo/bar":     "https://bitbucket.org/foo/bar.git",
+		"sr.ht/foo/bar":         "https://git.sr.ht/~foo/bar",
+		"sr.ht/~foo/bar":        "https://git.sr.ht/~foo/bar",
+		"work/team/tools/bar":   "https://git.example.com/team/tools/bar.git",
+		"work/team/bar.git":     "https://git.example.com/team/bar.git",
+		"gitlab/group/sub/repo": "https://gitlab.foo.com/group/sub/repo.git",
+	}
+	for value, expected := range tests {
+		actual, err := ExpandShorthand(value, aliases)
+		if err != nil {
+			t.Fatalf("'%s': %s", value, err)
+		}
+		if actual != expected {
+			t.Fatalf("'%s' expanded to '%s', expected '%s'", value, actual, expected)
+		}
+	}
+
+	actual, err := ExpandShorthand("gitlab/group/sub/repo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != "https://gitlab.com/group/sub/repo.git" {
+		t.Fatalf("unexpected nested gitlab remote '%s'", actual)
+	}
+
+	for _, value := range []string{"github/foo", "github/foo/bar/baz", "nowhere/foo/bar", "github/../bar", "github/foo/b?r", "github"} {
+		_, err := ExpandShorthand(value, aliases)
+		if err == nil {
+			t.Fatalf("expected '%s' to fail", value)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"path"
 	"strings"
 
 	"github.com/woolawin/catalogue/internal"
@@ -12,16 +13,23 @@ type Metadata struct {
 	Homepage     string
 	Maintainer   string
 	Description  string
-	Architecture string
+	// Summary is the synopsis of the description.
+	Summary string
+	// DescriptionFile is a file, relative to the .catalogue directory, the
+	// long description is read from. Markdown files are stripped to text.
+	DescriptionFile string
+	Architecture    string
 }
 
 type MetadataTOML struct {
-	Dependencies string `toml:"dependencies"`
-	Category     string `toml:"category"`
-	Homepage     string `toml:"homepage"`
-	Maintainer   string `toml:"maintainer"`
-	Description  string `toml:"description"`
-	Architecture string `toml:"architecture"`
+	Dependencies    string `toml:"dependencies"`
+	Category        string `toml:"category"`
+	Homepage        string `toml:"homepage"`
+	Maintainer      string `toml:"maintainer"`
+	Description     string `toml:"description"`
+	Summary         string `toml:"summary,omitempty"`
+	DescriptionFile string `toml:"description_file,omitempty"`
+	Architecture    string `toml:"architecture"`
 }
 
 type TargetMetadata struct {
@@ -50,6 +58,12 @@ func loadTargetMetadata(deserialized map[string]MetadataTOML, targets []internal
 			Target:   tgt,
 			Metadata: loadMetadata(meta),
 		}
+		if len(metadata.DescriptionFile) != 0 {
+			_, err = DescriptionPath(metadata.DescriptionFile)
+			if err != nil {
+				return nil, internal.ErrOf(err, "invalid metadata target %s", targetStr)
+			}
+		}
 		metadatas = append(metadatas, &metadata)
 	}
 	return metadatas, nil
@@ -57,73 +71,139 @@ func loadTargetMetadata(deserialized map[string]MetadataTOML, targets []internal
 
 func loadMetadata(toml MetadataTOML) Metadata {
 	return Metadata{
-		Dependencies: strings.TrimSpace(toml.Dependencies),
-		Category:     strings.TrimSpace(toml.Category),
-		Homepage:     strings.TrimSpace(toml.Homepage),
-		Maintainer:   strings.TrimSpace(toml.Maintainer),
-		Description:  strings.TrimSpace(toml.Description),
-		Architecture: strings.TrimSpace(toml.Architecture),
+		Dependencies:    strings.TrimSpace(toml.Dependencies),
+		Category:        strings.TrimSpace(toml.Category),
+		Homepage:        strings.TrimSpace(toml.Homepage),
+		Maintainer:      strings.TrimSpace(toml.Maintainer),
+		Description:     strings.TrimSpace(toml.Description),
+		Summary:         strings.TrimSpace(toml.Summary),
+		DescriptionFile: strings.TrimSpace(toml.DescriptionFile),
+		Architecture:    strings.TrimSpace(toml.Architecture),
+	}
+}
+
+// DescriptionPath is where a description file is within the repository.
+func DescriptionPath(file string) (string, error) {
+	if path.IsAbs(file) {
+		return "", internal.Err("description file '%s' must be relative", file)
 	}
+	resolved := path.Join(".catalogue", file)
+	if resolved == ".." || strings.HasPrefix(resolved, "../") {
+		return "", internal.Err("description file '%s' is outside of the repository", file)
+	}
+	return resolved, nil
 }
 
-func BuildMetadata(metadatas []*TargetMetadata, remote Remote, author string, log *internal.Log, system internal.System) (TargetMetadata, error) {
+// ResolveMetadata picks each metadata value from the highest ranked target
+// that sets it, falling back to defaults. Alongside the metadata it returns,
+// per field, the name of the target the value came from or "default".
+func ResolveMetadata(metadatas []*TargetMetadata, remote Remote, author string, system internal.System) (TargetMetadata, map[string]string) {
 	metadata := TargetMetadata{}
-	for _, data := range internal.Ranked(system, metadatas) {
-		if len(metadata.Dependencies) == 0 && len(data.Dependencies) != 0 {
-			log.Info(7, "using metadata.dependencies from '%s' '%s'", metadata.Target.Name, data.Dependencies)
-			metadata.Dependencies = data.Dependencies
+	sources := make(map[string]string)
+	pick := func(field string, dst *string, value string, target string) {
+		if len(*dst) == 0 && len(value) != 0 {
+			*dst = value
+			sources[field] = target
 		}
+	}
+	for _, data := range internal.Ranked(system, metadatas) {
+		pick("dependencies", &metadata.Dependencies, data.Dependencies, data.Target.Name)
+		pick("category", &metadata.Category, data.Category, data.Target.Name)
+		pick("homepage", &metadata.Homepage, data.Homepage, data.Target.Name)
+		pick("maintainer", &metadata.Maintainer, data.Maintainer, data.Target.Name)
+		pick("description", &metadata.Description, data.Description, data.Target.Name)
+		pick("summary", &metadata.Summary, data.Summary, data.Target.Name)
+		pick("description_file", &metadata.DescriptionFile, data.DescriptionFile, data.Target.Name)
+		pick("architecture", &metadata.Architecture, data.Architecture, data.Target.Name)
+	}
 
-		if len(metadata.Category) == 0 && len(data.Category) != 0 {
-			log.Info(7, "using metadata.category from '%s' '%s'", metadata.Target.Name, data.Category)
-			metadata.Category = data.Category
-		}
+	// without any description the synopsis can not come from its first line
+	if len(metadata.Description) == 0 && len(metadata.DescriptionFile) == 0 {
+		pick("summary", &metadata.Summary, "Description not provided", "default")
+	}
+	pick("architecture", &metadata.Architecture, string(system.Architecture), "default")
+	pick("category", &metadata.Category, "Other", "default")
+	if remote.URL != nil {
+		homepage, _ := strings.CutSuffix(remote.URL.Redacted(), ".git")
+		pick("homepage", &metadata.Homepage, homepage, "default")
+	}
+	pick("maintainer", &metadata.Maintainer, author, "default")
 
-		if len(metadata.Homepage) == 0 && len(data.Homepage) != 0 {
-			log.Info(7, "using metadata.homepage from '%s' '%s'", metadata.Target.Name, data.Homepage)
-			metadata.Homepage = data.Homepage
-		}
+	return metadata, sources
+}
 
-		if len(metadata.Maintainer) == 0 && len(data.Maintainer) != 0 {
-			log.Info(7, "using metadata.maintainer from '%s' '%s'", metadata.Target.Name, data.Maintainer)
-			metadata.Maintainer = data.Maintainer
+// BuildMetadata resolves the metadata of a component and renders its
+// Description field, readFile reads a file of the repository by path.
+func BuildMetadata(metadatas []*TargetMetadata, remote Remote, author string, readFile func(path string) ([]byte, error), log *internal.Log, system internal.System) (TargetMetadata, error) {
+	metadata, sources := ResolveMetadata(metadatas, remote, author, system)
+	for _, field := range MetadataFields {
+		source, ok := sources[field]
+		if !ok {
+			continue
 		}
-
-		if len(metadata.Description) == 0 && len(data.Description) != 0 {
-			log.Info(7, "using metadata.description from '%s' '%s'", metadata.Target.Name, data.Description)
-			metadata.Description = data.Description
+		if source == "default" {
+			log.Info(7, "metadata.%s not specified, defaulting to '%s'", field, metadata.Field(field))
+		} else {
+			log.Info(7, "using metadata.%s from '%s' '%s'", field, source, metadata.Field(field))
 		}
+	}
 
-		if len(metadata.Architecture) == 0 && len(data.Architecture) != 0 {
-			log.Info(7, "using metadata.architecture from '%s' '%s'", metadata.Target.Name, data.Architecture)
-			metadata.Architecture = data.Architecture
+	body := metadata.Description
+	if len(metadata.DescriptionFile) != 0 {
+		file, err := DescriptionPath(metadata.DescriptionFile)
+		if err != nil {
+			return TargetMetadata{}, err
+		}
+		data, err := readFile(file)
+		if err != nil {
+			return TargetMetadata{}, internal.ErrOf(err, "can not read description file '%s'", metadata.DescriptionFile)
+		}
+		body = string(data)
+		switch strings.ToLower(path.Ext(file)) {
+		case ".md", ".markdown":
+			body = internal.MarkdownText(body)
 		}
-	}
-	if len(metadata.Description) == 0 {
-		log.Info(7, "metadata.description not specified, defaulting to generic statment")
-		metadata.Description = "Description not provided"
 	}
 
-	if len(metadata.Architecture) == 0 {
-		log.Info(7, "metadata.architecture not specified, defaulting to system '%s'", system.Architecture)
-		metadata.Architecture = string(system.Architecture)
+	synopsis := metadata.Summary
+	if len(synopsis) == 0 {
+		synopsis, body = firstLine(body)
+		log.Info(7, "metadata.summary not specified, using the first line of the description '%s'", synopsis)
 	}
-
-	if len(metadata.Category) == 0 {
-		log.Info(7, "metadata.category not specified, defaulting to 'Other'")
-		metadata.Category = "Other"
+	if len(synopsis) == 0 {
+		synopsis = "Description not provided"
 	}
+	metadata.Description = internal.DebDescription(synopsis, body)
+	return metadata, nil
+}
 
-	if len(metadata.Homepage) == 0 {
-		homepage, _ := strings.CutSuffix(remote.URL.Redacted(), ".git")
-		log.Info(7, "metadata.homepage not specified, defaulting to remote.url '%s'", homepage)
-		metadata.Homepage = homepage
-	}
+func firstLine(text string) (string, string) {
+	text = strings.TrimLeft(text, " \t\n")
+	line, rest, _ := strings.Cut(text, "\n")
+	return strings.TrimSpace(line), rest
+}
 
-	if len(metadata.Maintainer) == 0 {
-		log.Info(7, "metadata.maintainer not specified, defaulting to clone author '%s'", author)
-		metadata.Maintainer = author
+var MetadataFields = []string{"dependencies", "category", "homepage", "maintainer", "description", "summary", "description_file", "architecture"}
+
+func (metadata *Metadata) Field(name string) string {
+	switch name {
+	case "dependencies":
+		return metadata.Dependencies
+	case "category":
+		return metadata.Category
+	case "homepage":
+		return metadata.Homepage
+	case "maintainer":
+		return metadata.Maintainer
+	case "description":
+		return metadata.Description
+	case "summary":
+		return metadata.Summary
+	case "description_file":
+		return metadata.DescriptionFile
+	case "architecture":
+		return metadata.Architecture
+	default:
+		return ""
 	}
-
-	return metadata, nil
 }
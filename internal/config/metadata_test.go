@@ -126,7 +126,7 @@ func TestBuildMetadata(t *testing.T) {
 		},
 	}
 
-	actual, err := BuildMetadata(metadatas, Remote{}, "", log, system)
+	actual, err := BuildMetadata(metadatas, Remote{}, "", nil, log, system)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -155,7 +155,7 @@ func TestBuildMetadataWithDefaults(t *testing.T) {
 		},
 	}
 
-	actual, err := BuildMetadata(metadatas, Remote{URL: u("https://foo.com/bar.git")}, "bob <bob@mail.com>", log, system)
+	actual, err := BuildMetadata(metadatas, Remote{URL: u("https://foo.com/bar.git")}, "bob <bob@mail.com>", nil, log, system)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -164,6 +164,7 @@ func TestBuildMetadataWithDefaults(t *testing.T) {
 		Category:     "Other",
 		Homepage:     "https://foo.com/bar",
 		Description:  "Description not provided",
+		Summary:      "Description not provided",
 		Maintainer:   "bob <bob@mail.com>",
 		Architecture: "amd64",
 	}
@@ -172,3 +173,60 @@ func TestBuildMetadataWithDefaults(t *testing.T) {
 		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
 	}
 }
+
+func TestBuildMetadataDescriptionFile(t *testing.T) {
+	log := internal.NewLog(&DoNothingLogger{})
+	system := internal.System{Architecture: internal.AMD64}
+
+	metadatas := []*TargetMetadata{
+		{
+			Target: internal.Target{Name: "all", All: true},
+			Metadata: Metadata{
+				Summary:         "command line tool for foo",
+				DescriptionFile: "../README.md",
+			},
+		},
+	}
+
+	var read string
+	readFile := func(path string) ([]byte, error) {
+		read = path
+		return []byte("# foo\n\nFoo does **bar**, see [the docs](https://foo.com).\n"), nil
+	}
+
+	actual, err := BuildMetadata(metadatas, Remote{}, "", readFile, log, system)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != "README.md" {
+		t.Fatalf("read '%s' instead of README.md", read)
+	}
+	expected := "command line tool for foo\nfoo\n\nFoo does bar, see the docs."
+	if diff := cmp.Diff(actual.Description, expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+}
+
+func TestDescriptionPath(t *testing.T) {
+	for file, expected := range map[string]string{
+		"../README.md":  "README.md",
+		"DESCRIPTION":   ".catalogue/DESCRIPTION",
+		"../docs/x.md":  "docs/x.md",
+		"../../etc/foo": "",
+		"/etc/foo":      "",
+	} {
+		actual, err := DescriptionPath(file)
+		if len(expected) == 0 {
+			if err == nil {
+				t.Fatalf("expected '%s' to be rejected", file)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Fatalf("expected '%s' for '%s', got '%s'", expected, file, actual)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/url"
 	"strings"
+	"time"
 
 	tomllib "github.com/pelletier/go-toml/v2"
 	"github.com/woolawin/catalogue/internal"
@@ -13,17 +14,28 @@ import (
 type Protocol int
 
 const (
-	Git Protocol = 1
+	Git   Protocol = 1
+	HTTP  Protocol = 2
+	Local Protocol = 3
 )
 
 type Remote struct {
 	Protocol Protocol
 	URL      *url.URL
+	// Versions is where versions of an HTTP remote are listed, its URL may
+	// then contain a '{version}' placeholder.
+	Versions *url.URL
+	// Alias is the shorthand the remote was added with, as in
+	// 'gitlab/group/repo', if any.
+	Alias string
 }
 
 type Pin struct {
 	VersionName string
 	CommitHash  string
+	// Date is when the pinned commit was made, builds of the pin are dated
+	// to it so they come out the same every time.
+	Date time.Time
 }
 
 type BuildFile struct {
@@ -40,16 +52,22 @@ type Record struct {
 	Remote    Remote
 	Metadata  Metadata
 	Builds    []BuildFile
+	// Priority is the apt pin priority of the package, zero leaves it to
+	// apt to pick between versions.
+	Priority int
 }
 
 type RemoteTOML struct {
 	Protocol string `toml:"protocol"`
 	URL      string `toml:"url"`
+	Versions string `toml:"versions,omitempty"`
+	Alias    string `toml:"alias,omitempty"`
 }
 
 type PinTOML struct {
 	VersionName string `toml:"version_name"`
 	CommitHash  string `toml:"commit_hash"`
+	Date        string `toml:"date,omitempty"`
 }
 
 type BuildFileTOML struct {
@@ -66,6 +84,7 @@ type RecordTOML struct {
 	Remote    RemoteTOML      `toml:"remote"`
 	Metadata  MetadataTOML    `toml:"metadata"`
 	Builds    []BuildFileTOML `toml:"builds"`
+	Priority  int             `toml:"priority,omitempty"`
 }
 
 func DeserializeRecord(src io.Reader) (Record, error) {
@@ -85,8 +104,9 @@ func loadRecord(toml RecordTOML) (Record, error) {
 	}
 
 	record := Record{
-		Name:   strings.TrimSpace(toml.Name),
-		Remote: Remote{Protocol: protocol},
+		Name:     strings.TrimSpace(toml.Name),
+		Remote:   Remote{Protocol: protocol, Alias: strings.TrimSpace(toml.Remote.Alias)},
+		Priority: toml.Priority,
 	}
 
 	remoteURL := strings.TrimSpace(toml.Remote.URL)
@@ -98,10 +118,27 @@ func loadRecord(toml RecordTOML) (Record, error) {
 		record.Remote.URL = parsed
 	}
 
+	versionsURL := strings.TrimSpace(toml.Remote.Versions)
+	if len(versionsURL) != 0 {
+		parsed, err := url.Parse(versionsURL)
+		if err != nil {
+			return Record{}, internal.ErrOf(err, "invalid remote versions url '%s'", versionsURL)
+		}
+		record.Remote.Versions = parsed
+	}
+
 	record.LatestPin = Pin{
 		VersionName: strings.TrimSpace(toml.LatestPin.VersionName),
 		CommitHash:  strings.TrimSpace(toml.LatestPin.CommitHash),
 	}
+	date := strings.TrimSpace(toml.LatestPin.Date)
+	if len(date) != 0 {
+		parsed, err := time.Parse(time.RFC3339, date)
+		if err != nil {
+			return Record{}, internal.ErrOf(err, "invalid pin date '%s'", date)
+		}
+		record.LatestPin.Date = parsed.UTC()
+	}
 
 	for _, build := range toml.Builds {
 		record.Builds = append(record.Builds, BuildFile{
@@ -137,8 +174,16 @@ func toRecordTOML(record Record) RecordTOML {
 		Remote: RemoteTOML{
 			Protocol: ProtocolDebugString(record.Remote.Protocol),
 			URL:      record.Remote.URL.String(),
+			Alias:    record.Remote.Alias,
 		},
 		Metadata: toMetadataTOML(record.Metadata),
+		Priority: record.Priority,
+	}
+	if !record.LatestPin.Date.IsZero() {
+		toml.LatestPin.Date = record.LatestPin.Date.UTC().Format(time.RFC3339)
+	}
+	if record.Remote.Versions != nil {
+		toml.Remote.Versions = record.Remote.Versions.String()
 	}
 	for _, build := range record.Builds {
 		toml.Builds = append(toml.Builds, BuildFileTOML{
@@ -156,6 +201,10 @@ func ProtocolString(protocol Protocol) (string, bool) {
 	switch protocol {
 	case Git:
 		return "git", true
+	case HTTP:
+		return "http", true
+	case Local:
+		return "local", true
 	default:
 		return fmt.Sprintf("unknown value '%d'", protocol), false
 	}
@@ -165,6 +214,10 @@ func ProtocolDebugString(protocol Protocol) string {
 	switch protocol {
 	case Git:
 		return "git"
+	case HTTP:
+		return "http"
+	case Local:
+		return "local"
 	default:
 		return fmt.Sprintf("unknown value '%d'", protocol)
 	}
@@ -174,6 +227,10 @@ func FromProtocolString(value string) (Protocol, bool) {
 	switch value {
 	case "git":
 		return Git, true
+	case "http":
+		return HTTP, true
+	case "local":
+		return Local, true
 	default:
 		return 0, false
 	}
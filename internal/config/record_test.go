@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
@@ -11,10 +12,12 @@ import (
 func TestDeseralizeRecord(t *testing.T) {
 	value := `
 name='Foo Bar'
+priority=1001
 
 [latest_pin]
 version_name='v0.54.2'
 commit_hash='c7t43c374c34yh43fc43'
+date='2025-01-02T03:04:05Z'
 
 [metadata]
 dependencies='foo,bar'
@@ -27,6 +30,7 @@ architecture='amd64'
 [remote]
 protocol='git'
 url='https://github.com/foo/bar.git'
+alias='github/foo/bar'
 `
 
 	actual, err := DeserializeRecord(strings.NewReader(value))
@@ -36,10 +40,11 @@ url='https://github.com/foo/bar.git'
 
 	expected := Record{
 		Name:      "Foo Bar",
-		LatestPin: Pin{VersionName: "v0.54.2", CommitHash: "c7t43c374c34yh43fc43"},
+		LatestPin: Pin{VersionName: "v0.54.2", CommitHash: "c7t43c374c34yh43fc43", Date: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
 		Remote: Remote{
 			Protocol: Git,
 			URL:      u("https://github.com/foo/bar.git"),
+			Alias:    "github/foo/bar",
 		},
 		Metadata: Metadata{
 			Dependencies: "foo,bar",
@@ -49,6 +54,7 @@ url='https://github.com/foo/bar.git'
 			Maintainer:   "Bob Doe",
 			Architecture: "amd64",
 		},
+		Priority: 1001,
 	}
 
 	if diff := cmp.Diff(actual, expected); diff != "" {
@@ -60,10 +66,11 @@ url='https://github.com/foo/bar.git'
 func TestToRecordTOML(t *testing.T) {
 	record := Record{
 		Name:      "Foo Bar",
-		LatestPin: Pin{VersionName: "v0.54.2", CommitHash: "c7t43c374c34yh43fc43"},
+		LatestPin: Pin{VersionName: "v0.54.2", CommitHash: "c7t43c374c34yh43fc43", Date: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)},
 		Remote: Remote{
 			Protocol: Git,
 			URL:      u("https://github.com/foo/bar.git"),
+			Alias:    "github/foo/bar",
 		},
 		Metadata: Metadata{
 			Dependencies: "foo,bar",
@@ -73,15 +80,17 @@ func TestToRecordTOML(t *testing.T) {
 			Maintainer:   "Bob Doe",
 			Architecture: "amd64",
 		},
+		Priority: 1001,
 	}
 
 	actual := toRecordTOML(record)
 	expected := RecordTOML{
 		Name:      "Foo Bar",
-		LatestPin: PinTOML{VersionName: "v0.54.2", CommitHash: "c7t43c374c34yh43fc43"},
+		LatestPin: PinTOML{VersionName: "v0.54.2", CommitHash: "c7t43c374c34yh43fc43", Date: "2025-01-02T03:04:05Z"},
 		Remote: RemoteTOML{
 			Protocol: "git",
 			URL:      "https://github.com/foo/bar.git",
+			Alias:    "github/foo/bar",
 		},
 		Metadata: MetadataTOML{
 			Dependencies: "foo,bar",
@@ -91,6 +100,7 @@ func TestToRecordTOML(t *testing.T) {
 			Maintainer:   "Bob Doe",
 			Architecture: "amd64",
 		},
+		Priority: 1001,
 	}
 
 	if diff := cmp.Diff(actual, expected); diff != "" {
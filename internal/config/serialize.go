@@ -62,6 +62,24 @@ func Serialize(config Component, writer io.Writer) error {
 		}
 	}
 
+	for _, file := range config.Files {
+		if toml.Files == nil {
+			toml.Files = make(map[string]FileTOML)
+		}
+		toml.Files[file.Path] = toFileTOML(file)
+	}
+
+	for _, service := range config.Services {
+		if toml.Service == nil {
+			toml.Service = make(map[string]ServiceTOML)
+		}
+		toml.Service[service.Unit] = toServiceTOML(service)
+	}
+
+	if config.Build != nil {
+		toml.Build = toBuildTOML(config.Build)
+	}
+
 	err := tomllib.NewEncoder(writer).Encode(&toml)
 	if err != nil {
 		return internal.ErrOf(err, "failed to serialize component config")
@@ -71,11 +89,13 @@ func Serialize(config Component, writer io.Writer) error {
 
 func toMetadataTOML(metadata Metadata) MetadataTOML {
 	return MetadataTOML{
-		Dependencies: strings.TrimSpace(metadata.Dependencies),
-		Category:     strings.TrimSpace(metadata.Category),
-		Homepage:     strings.TrimSpace(metadata.Homepage),
-		Maintainer:   strings.TrimSpace(metadata.Maintainer),
-		Description:  strings.TrimSpace(metadata.Description),
-		Architecture: strings.TrimSpace(metadata.Architecture),
+		Dependencies:    strings.TrimSpace(metadata.Dependencies),
+		Category:        strings.TrimSpace(metadata.Category),
+		Homepage:        strings.TrimSpace(metadata.Homepage),
+		Maintainer:      strings.TrimSpace(metadata.Maintainer),
+		Description:     strings.TrimSpace(metadata.Description),
+		Summary:         strings.TrimSpace(metadata.Summary),
+		DescriptionFile: strings.TrimSpace(metadata.DescriptionFile),
+		Architecture:    strings.TrimSpace(metadata.Architecture),
 	}
 }
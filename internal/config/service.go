@@ -0,0 +1,71 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+)
+
+var ServiceUnitSuffixes = []string{".service", ".socket", ".timer", ".path", ".mount", ".target"}
+
+type Service struct {
+	Unit    string
+	Enable  bool
+	Start   bool
+	Restart bool
+}
+
+type ServiceTOML struct {
+	Enable  *bool `toml:"enable"`
+	Start   *bool `toml:"start"`
+	Restart *bool `toml:"restart_on_upgrade"`
+}
+
+// DefaultService is how units found under the systemd_system anchor without a
+// [service] table are handled.
+func DefaultService(unit string) Service {
+	return Service{Unit: unit, Enable: true, Start: true, Restart: true}
+}
+
+func IsServiceUnit(name string) bool {
+	for _, suffix := range ServiceUnitSuffixes {
+		if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadServices(deserialized map[string]ServiceTOML) ([]*Service, error) {
+	var services []*Service
+	for unit, toml := range deserialized {
+		unit = strings.TrimSpace(unit)
+		if !IsServiceUnit(unit) {
+			return nil, internal.Err("service '%s' is not a systemd unit name, expected one of %s", unit, strings.Join(ServiceUnitSuffixes, ", "))
+		}
+		if filepath.Base(unit) != unit || strings.ContainsAny(unit, "'\"$` ") {
+			return nil, internal.Err("invalid service unit name '%s'", unit)
+		}
+		service := DefaultService(unit)
+		if toml.Enable != nil {
+			service.Enable = *toml.Enable
+		}
+		if toml.Start != nil {
+			service.Start = *toml.Start
+		}
+		if toml.Restart != nil {
+			service.Restart = *toml.Restart
+		}
+		services = append(services, &service)
+	}
+	return services, nil
+}
+
+func toServiceTOML(service *Service) ServiceTOML {
+	return ServiceTOML{
+		Enable:  &service.Enable,
+		Start:   &service.Start,
+		Restart: &service.Restart,
+	}
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestLoadServices(t *testing.T) {
+	no := false
+
+	deserialized := map[string]ServiceTOML{
+		" foo.service ": {},
+		"foo.timer": {
+			Start:   &no,
+			Restart: &no,
+		},
+	}
+
+	actual, err := loadServices(deserialized)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*Service{
+		{Unit: "foo.service", Enable: true, Start: true, Restart: true},
+		{Unit: "foo.timer", Enable: true},
+	}
+
+	sortByUnit := func(a, b *Service) int {
+		return strings.Compare(a.Unit, b.Unit)
+	}
+
+	if diff := cmp.Diff(actual, expected, cmpopts.SortSlices(sortByUnit)); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+
+	_, err = loadServices(map[string]ServiceTOML{"foo": {}})
+	if err == nil {
+		t.Fatal("expected non unit name to fail")
+	}
+}
@@ -1,9 +1,11 @@
 package config
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/woolawin/catalogue/internal"
 )
 
@@ -30,14 +32,17 @@ func TestLoadTargets(t *testing.T) {
 		{
 			Name:         "amd64",
 			Architecture: internal.AMD64,
+			BuiltIn:      true,
 		},
 		{
 			Name:         "arm64",
 			Architecture: internal.ARM64,
+			BuiltIn:      true,
 		},
 		{
-			Name: "all",
-			All:  true,
+			Name:    "all",
+			All:     true,
+			BuiltIn: true,
 		},
 		{
 			Name:        "ubuntu",
@@ -52,7 +57,11 @@ func TestLoadTargets(t *testing.T) {
 		},
 	}
 
-	if diff := cmp.Diff(actual, expected); diff != "" {
+	if diff := cmp.Diff(actual, expected, cmpopts.SortSlices(sortTargets)); diff != "" {
 		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
 	}
 }
+
+func sortTargets(a, b internal.Target) int {
+	return strings.Compare(a.Name, b.Name)
+}
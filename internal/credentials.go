@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	tomllib "github.com/pelletier/go-toml/v2"
+)
+
+// Credential is how to authenticate to a host. Only one of Token, SSHKey,
+// SSHAgent and Helper is set.
+type Credential struct {
+	Host     string
+	Username string
+	Token    string
+	SSHKey   string
+	SSHAgent bool
+	Helper   bool
+}
+
+// String describes the credential without any secret so it can be logged.
+func (credential Credential) String() string {
+	switch {
+	case len(credential.Token) != 0:
+		return fmt.Sprintf("token for '%s'", credential.Host)
+	case len(credential.SSHKey) != 0:
+		return fmt.Sprintf("ssh key '%s' for '%s'", credential.SSHKey, credential.Host)
+	case credential.SSHAgent:
+		return fmt.Sprintf("ssh agent for '%s'", credential.Host)
+	case credential.Helper:
+		return fmt.Sprintf("git credential helper for '%s'", credential.Host)
+	}
+	return fmt.Sprintf("no credential for '%s'", credential.Host)
+}
+
+type CredentialTOML struct {
+	Username string `toml:"username"`
+	Token    string `toml:"token"`
+	SSHKey   string `toml:"ssh_key"`
+	SSHAgent bool   `toml:"ssh_agent"`
+	Helper   bool   `toml:"credential_helper"`
+}
+
+type CredentialsTOML struct {
+	Hosts map[string]CredentialTOML `toml:"host"`
+}
+
+// ParseCredentials reads credentials.toml, credentials are keyed by host.
+func ParseCredentials(src io.Reader) (map[string]Credential, error) {
+	toml := CredentialsTOML{}
+	err := tomllib.NewDecoder(src).Decode(&toml)
+	if err != nil {
+		return nil, ErrOf(err, "can not deserialize credentials")
+	}
+
+	credentials := make(map[string]Credential)
+	for host, value := range toml.Hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if len(host) == 0 || strings.ContainsAny(host, "/@ \t") {
+			return nil, Err("invalid credentials host '%s'", host)
+		}
+
+		credential := Credential{
+			Host:     host,
+			Username: strings.TrimSpace(value.Username),
+			Token:    strings.TrimSpace(value.Token),
+			SSHKey:   strings.TrimSpace(value.SSHKey),
+			SSHAgent: value.SSHAgent,
+			Helper:   value.Helper,
+		}
+
+		count := 0
+		for _, set := range []bool{len(credential.Token) != 0, len(credential.SSHKey) != 0, credential.SSHAgent, credential.Helper} {
+			if set {
+				count++
+			}
+		}
+		if count != 1 {
+			return nil, Err("credentials for '%s' must set one of token, ssh_key, ssh_agent or credential_helper", host)
+		}
+		if len(credential.SSHKey) != 0 && (!filepath.IsAbs(credential.SSHKey) || strings.ContainsAny(credential.SSHKey, "'\"")) {
+			return nil, Err("ssh_key of '%s' must be an absolute path without quotes", host)
+		}
+
+		credentials[host] = credential
+	}
+	return credentials, nil
+}
+
+type ClientTOML struct {
+	Token string `toml:"token"`
+}
+
+type ClientsTOML struct {
+	Clients map[string]ClientTOML `toml:"client"`
+}
+
+// ParseClients reads clients.toml, the tokens clients of the repository
+// authenticate with keyed by the client they were handed to.
+func ParseClients(src io.Reader) (map[string]string, error) {
+	toml := ClientsTOML{}
+	err := tomllib.NewDecoder(src).Decode(&toml)
+	if err != nil {
+		return nil, ErrOf(err, "can not deserialize clients")
+	}
+
+	tokens := make(map[string]string)
+	for name, client := range toml.Clients {
+		name = strings.TrimSpace(name)
+		token := strings.TrimSpace(client.Token)
+		if len(name) == 0 {
+			return nil, Err("client name can not be empty")
+		}
+		// shorter tokens can be guessed
+		if len(token) < 16 {
+			return nil, Err("token of client '%s' must be at least 16 characters", name)
+		}
+		for other, otherToken := range tokens {
+			if otherToken == token {
+				return nil, Err("clients '%s' and '%s' have the same token", other, name)
+			}
+		}
+		tokens[name] = token
+	}
+	return tokens, nil
+}
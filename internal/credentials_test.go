@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseCredentials(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		input := `
+[host.'GitHub.com']
+token='ghp_secret'
+
+[host.'gitlab.foo.com']
+username='bob'
+token='glpat-secret'
+
+[host.'git.foo.com']
+ssh_key='/root/.ssh/id_ed25519'
+
+[host.'bar.com']
+ssh_agent=true
+
+[host.'baz.com']
+credential_helper=true
+`
+		actual, err := ParseCredentials(strings.NewReader(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := map[string]Credential{
+			"github.com":     {Host: "github.com", Token: "ghp_secret"},
+			"gitlab.foo.com": {Host: "gitlab.foo.com", Username: "bob", Token: "glpat-secret"},
+			"git.foo.com":    {Host: "git.foo.com", SSHKey: "/root/.ssh/id_ed25519"},
+			"bar.com":        {Host: "bar.com", SSHAgent: true},
+			"baz.com":        {Host: "baz.com", Helper: true},
+		}
+		if diff := cmp.Diff(actual, expected); diff != "" {
+			t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+		}
+	})
+
+	t.Run("two_kinds", func(t *testing.T) {
+		input := `
+[host.'github.com']
+token='ghp_secret'
+ssh_agent=true
+`
+		_, err := ParseCredentials(strings.NewReader(input))
+		if err == nil {
+			t.Fatal("expected two kinds of credential to fail")
+		}
+	})
+
+	t.Run("relative_key", func(t *testing.T) {
+		input := `
+[host.'github.com']
+ssh_key='id_ed25519'
+`
+		_, err := ParseCredentials(strings.NewReader(input))
+		if err == nil {
+			t.Fatal("expected relative ssh key to fail")
+		}
+	})
+}
+
+func TestCredentialStringHasNoSecret(t *testing.T) {
+	credential := Credential{Host: "github.com", Username: "bob", Token: "ghp_secret"}
+	if strings.Contains(credential.String(), "ghp_secret") {
+		t.Fatalf("'%s' leaks the token", credential.String())
+	}
+}
+
+func TestParseClients(t *testing.T) {
+	input := `
+[client.laptop]
+token = ' 0123456789abcdef0123 '
+`
+	actual, err := ParseClients(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"laptop": "0123456789abcdef0123"}
+	if diff := cmp.Diff(actual, expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+
+	for _, input := range []string{
+		"[client.laptop]\ntoken = 'short'",
+		"[client.laptop]\ntoken = '0123456789abcdef'\n[client.desktop]\ntoken = '0123456789abcdef'",
+	} {
+		_, err := ParseClients(strings.NewReader(input))
+		if err == nil {
+			t.Fatalf("expected '%s' to fail", input)
+		}
+	}
+}
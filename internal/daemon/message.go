@@ -8,6 +8,7 @@ import (
 
 var ErrNotStringArg = errors.New("not a string argument")
 var ErrNotIntArg = errors.New("not a int argument")
+var ErrNotBoolArg = errors.New("not a bool argument")
 
 type Message struct {
 	Cmd *Cmd
@@ -23,6 +24,8 @@ const (
 	ListPackages Command = 3
 	Update       Command = 4
 	Delete       Command = 5
+	VerifyBuild  Command = 6
+	Prefs        Command = 7
 )
 
 type Cmd struct {
@@ -77,6 +80,18 @@ func (cmd *Cmd) IntArg(name string) (int, bool, any, error) {
 	return 0, false, value, ErrNotIntArg
 }
 
+func (cmd *Cmd) BoolArg(name string) (bool, bool, error) {
+	value, ok := cmd.Args[name]
+	if !ok || value == nil {
+		return false, false, nil
+	}
+	val, ok := value.(bool)
+	if ok {
+		return val, true, nil
+	}
+	return false, false, ErrNotBoolArg
+}
+
 type Log struct {
 	Statement *internal.LogStatement
 }
@@ -36,3 +36,31 @@ func TestSerializeLog(t *testing.T) {
 		fmt.Printf("Mismatch (-actual +expected):\n%s", diff)
 	}
 }
+
+func TestBoolArg(t *testing.T) {
+	buffer := bytes.NewBuffer([]byte{})
+	sent := Cmd{Command: Add, Args: map[string]any{"install_build_deps": true, "remote": "foo"}}
+	err := msgpacklib.NewEncoder(buffer).Encode(&sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := Cmd{}
+	err = msgpacklib.NewDecoder(buffer).Decode(&cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, found, err := cmd.BoolArg("install_build_deps")
+	if err != nil || !found || !value {
+		t.Fatalf("expected install_build_deps to be true, got %t %t %v", value, found, err)
+	}
+	_, found, _ = cmd.BoolArg("missing")
+	if found {
+		t.Fatal("expected missing argument not to be found")
+	}
+	_, _, err = cmd.BoolArg("remote")
+	if err != ErrNotBoolArg {
+		t.Fatal("expected string argument not to be a bool")
+	}
+}
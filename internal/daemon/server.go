@@ -4,6 +4,7 @@ import (
 	"errors"
 	"log/slog"
 	"net"
+	"net/url"
 	"os"
 	"os/user"
 	"strconv"
@@ -11,10 +12,14 @@ import (
 	msgpacklib "github.com/vmihailenco/msgpack/v5"
 	"github.com/woolawin/catalogue/internal"
 	"github.com/woolawin/catalogue/internal/add"
+	"github.com/woolawin/catalogue/internal/build"
+	"github.com/woolawin/catalogue/internal/clone"
 	"github.com/woolawin/catalogue/internal/config"
 	"github.com/woolawin/catalogue/internal/ext"
+	"github.com/woolawin/catalogue/internal/prefs"
 	"github.com/woolawin/catalogue/internal/registry"
 	"github.com/woolawin/catalogue/internal/update"
+	"github.com/woolawin/catalogue/internal/verify"
 )
 
 var ErrInvalidMessage = errors.New("invalid command syntax")
@@ -153,6 +158,10 @@ func (server *Server) handle(conn net.Conn) {
 		server.list(&session)
 	case Delete:
 		server.delete(&session)
+	case VerifyBuild:
+		server.verifyBuild(&session)
+	case Prefs:
+		server.prefs(&session)
 	}
 
 }
@@ -183,7 +192,54 @@ func (server *Server) add(session *Session) {
 		return
 	}
 
-	ok = add.Add(config.Protocol(protocol), remote, session.log, server.system, server.api)
+	versions, _, err := session.msg.Cmd.StringArg("versions")
+	if err != nil {
+		session.log.Err(err, "can not get versions argument")
+		session.end(false, nil)
+		return
+	}
+
+	alias, _, err := session.msg.Cmd.StringArg("alias")
+	if err != nil {
+		session.log.Err(err, "can not get alias argument")
+		session.end(false, nil)
+		return
+	}
+
+	remoteURL, err := url.Parse(remote)
+	if err != nil {
+		session.log.Err(err, "invalid remote '%s'", remote)
+		session.end(false, nil)
+		return
+	}
+
+	parsed := config.Remote{Protocol: config.Protocol(protocol), URL: remoteURL, Alias: alias}
+	if len(versions) != 0 {
+		parsed.Versions, err = url.Parse(versions)
+		if err != nil {
+			session.log.Err(err, "invalid versions '%s'", versions)
+			session.end(false, nil)
+			return
+		}
+	}
+
+	installBuildDeps, _, err := session.msg.Cmd.BoolArg("install_build_deps")
+	if err != nil {
+		session.log.Err(err, "can not get install_build_deps argument")
+		session.end(false, nil)
+		return
+	}
+
+	vcs, ok := clone.Open(parsed, session.log, server.api)
+	if !ok {
+		session.end(false, nil)
+		return
+	}
+
+	ok = add.Add(vcs, build.NewOpts(installBuildDeps), session.log, server.system, server.api)
+	if ok {
+		ok = server.writePrefs(session.log)
+	}
 	session.end(ok, nil)
 }
 
@@ -228,7 +284,7 @@ func (server *Server) delete(session *Session) {
 
 	session.log.Info(9, "removed package '%s'", name)
 
-	session.end(true, nil)
+	session.end(server.writePrefs(session.log), nil)
 }
 
 func (server *Server) update(session *Session) {
@@ -259,6 +315,115 @@ func (server *Server) update(session *Session) {
 		return
 	}
 
-	_, _, ok := update.Update(record, session.log, server.system, server.api)
+	installBuildDeps, _, err := session.msg.Cmd.BoolArg("install_build_deps")
+	if err != nil {
+		session.log.Err(err, "can not get install_build_deps argument")
+		session.end(false, nil)
+		return
+	}
+
+	vcs, ok := clone.Open(record.Remote, session.log, server.api)
+	if !ok {
+		session.end(false, nil)
+		return
+	}
+
+	_, _, ok = update.Update(record, vcs, build.NewOpts(installBuildDeps), session.log, server.system, server.api)
 	session.end(ok, nil)
 }
+
+func (server *Server) verifyBuild(session *Session) {
+	session.log.Stage("server")
+	component, found, err := session.msg.Cmd.StringArg("component")
+	if err != nil {
+		session.log.Err(err, "failed to get component argument from client")
+		session.end(false, nil)
+		return
+	}
+
+	if !found {
+		session.log.Err(nil, "missing package name from client")
+		session.end(false, nil)
+		return
+	}
+
+	record, found, err := registry.GetPackageRecord(component)
+	if err != nil {
+		session.log.Err(err, "failed to get package record")
+		session.end(false, nil)
+		return
+	}
+
+	if !found {
+		session.log.Err(nil, "could not find package '%s'", component)
+		session.end(false, nil)
+		return
+	}
+
+	vcs, ok := clone.Open(record.Remote, session.log, server.api)
+	if !ok {
+		session.end(false, nil)
+		return
+	}
+
+	ok = verify.Verify(record, vcs, session.log, server.system, server.api)
+	session.end(ok, nil)
+}
+
+// prefs sets the priority of a package when one is given, and regenerates the
+// apt preferences either way.
+func (server *Server) prefs(session *Session) {
+	session.log.Stage("server")
+	component, found, err := session.msg.Cmd.StringArg("component")
+	if err != nil {
+		session.log.Err(err, "failed to get component argument from client")
+		session.end(false, nil)
+		return
+	}
+
+	if found {
+		priority, ok, raw, err := session.msg.Cmd.IntArg("priority")
+		if err != nil {
+			session.log.Err(err, "invalid priority value '%v'", raw)
+			session.end(false, nil)
+			return
+		}
+		if !ok {
+			session.log.Err(nil, "missing required priority argument")
+			session.end(false, nil)
+			return
+		}
+
+		record, found, err := registry.GetPackageRecord(component)
+		if err != nil {
+			session.log.Err(err, "failed to get package record")
+			session.end(false, nil)
+			return
+		}
+		if !found {
+			session.log.Err(nil, "could not find package '%s'", component)
+			session.end(false, nil)
+			return
+		}
+
+		record.Priority = priority
+		err = registry.WriteRecord(record)
+		if err != nil {
+			session.log.Err(err, "failed to write record.toml")
+			session.end(false, nil)
+			return
+		}
+		session.log.Info(9, "set priority of package '%s' to %d", component, priority)
+	}
+
+	session.end(server.writePrefs(session.log), nil)
+}
+
+func (server *Server) writePrefs(log *internal.Log) bool {
+	cfg, err := server.api.Host.GetConfig()
+	if err != nil {
+		log.Err(err, "failed to read config")
+		return false
+	}
+	return prefs.Write(cfg, log)
+}
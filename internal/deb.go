@@ -7,52 +7,81 @@ import (
 	"strings"
 )
 
-func DebMultiLine(lines []string) string {
-	str := strings.Builder{}
-	for idx, line := range lines {
-		if idx > 0 {
-			str.WriteString(" ")
+// Field is a field of a deb822 paragraph. A value spanning lines holds them
+// separated by newlines, without the folding of the file.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Paragraph is a deb822 paragraph, its fields keep the order they were set in.
+type Paragraph []Field
+
+// Get is the value of a field, field names are case insensitive.
+func (paragraph Paragraph) Get(name string) string {
+	value, _ := paragraph.Lookup(name)
+	return value
+}
+
+// Lookup is the value of a field and whether the paragraph has it.
+func (paragraph Paragraph) Lookup(name string) (string, bool) {
+	for _, field := range paragraph {
+		if strings.EqualFold(field.Name, name) {
+			return field.Value, true
 		}
-		str.WriteString(line)
-		if idx < (len(lines) - 1) {
-			str.WriteString("\n")
+	}
+	return "", false
+}
+
+// Set replaces the value of a field where it is, or adds it at the end.
+func (paragraph *Paragraph) Set(name string, value string) {
+	for idx, field := range *paragraph {
+		if strings.EqualFold(field.Name, name) {
+			(*paragraph)[idx].Value = value
+			return
 		}
 	}
-	return str.String()
+	*paragraph = append(*paragraph, Field{Name: name, Value: value})
 }
 
-func DeserializeDebFile(src io.Reader) ([]map[string]string, error) {
-	var output []map[string]string
+func DeserializeDebFile(src io.Reader) ([]Paragraph, error) {
+	var output []Paragraph
 
-	paragraph := make(map[string]string)
+	var paragraph Paragraph
 	var key string
 	value := strings.Builder{}
 
 	scanner := bufio.NewScanner(src)
+	scanner.Buffer(nil, 1024*1024)
 
 	for scanner.Scan() {
 		line := scanner.Text()
-		if len(line) == 0 {
+		if len(strings.TrimSpace(line)) == 0 {
 			if key != "" {
-				paragraph[key] = value.String()
+				paragraph.Set(key, value.String())
 			}
 			if len(paragraph) != 0 {
 				output = append(output, paragraph)
 			}
-			paragraph = make(map[string]string)
+			paragraph = nil
 			key = ""
 			value.Reset()
 			continue
 		}
 
 		if line[0] == ' ' || line[0] == '\t' {
+			if key == "" {
+				return nil, fmt.Errorf("line not valid, continues no field : %s", line)
+			}
 			value.WriteString("\n")
-			value.WriteString(line[1:])
+			if line[1:] != "." {
+				value.WriteString(line[1:])
+			}
 			continue
 		}
 
 		if key != "" {
-			paragraph[key] = value.String()
+			paragraph.Set(key, value.String())
 			key = ""
 			value.Reset()
 		}
@@ -62,18 +91,14 @@ func DeserializeDebFile(src io.Reader) ([]map[string]string, error) {
 			return nil, fmt.Errorf("line not valid, missing ':' : %s", line)
 		}
 		key = line[:index]
-		valueStart := index + 1
-		if valueStart != index {
-			if line[valueStart] == ' ' || line[valueStart] == '\t' {
-				valueStart++
-			}
-			lineValue := line[valueStart:]
-			value.WriteString(lineValue)
-		}
+		value.WriteString(strings.TrimSpace(line[index+1:]))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
 
 	if key != "" {
-		paragraph[key] = value.String()
+		paragraph.Set(key, value.String())
 	}
 
 	if len(paragraph) != 0 {
@@ -83,45 +108,48 @@ func DeserializeDebFile(src io.Reader) ([]map[string]string, error) {
 	return output, nil
 }
 
-func SerializeDebParagraph(data map[string]string) string {
-	if len(data) == 0 {
-		return ""
-	}
-
+// SerializeDebParagraph writes the fields of a paragraph in order, leaving
+// out empty ones. Values spanning lines are folded, with empty lines as ' .'.
+func SerializeDebParagraph(paragraph Paragraph) string {
 	deb := strings.Builder{}
-	for key, value := range data {
-		deb.WriteString(key)
-		deb.WriteString(": ")
-		deb.WriteString(strings.ReplaceAll(value, "\n", " "))
-		deb.WriteString("\n")
-	}
-	deb.WriteString("\n")
-
+	writeDebParagraph(&deb, paragraph)
 	return deb.String()
 }
 
-func SerializeDebFile(data []map[string]string) string {
+func SerializeDebFile(paragraphs []Paragraph) string {
 	deb := strings.Builder{}
+	for _, paragraph := range paragraphs {
+		writeDebParagraph(&deb, paragraph)
+	}
+	return deb.String()
+}
 
-	for _, paragraph := range data {
-		if len(paragraph) == 0 {
+func writeDebParagraph(deb *strings.Builder, paragraph Paragraph) {
+	written := false
+	for _, field := range paragraph {
+		if len(strings.TrimSpace(field.Value)) == 0 {
 			continue
 		}
-		for key, value := range paragraph {
-			deb.WriteString(key)
-			deb.WriteString(":")
-			if len(value) == 0 {
-				deb.WriteString("\n")
-				continue
-			}
-			if value[0] != '\n' {
-				deb.WriteString(" ")
+		written = true
+		lines := strings.Split(strings.TrimRight(field.Value, "\n"), "\n")
+		deb.WriteString(field.Name)
+		deb.WriteString(":")
+		if len(lines[0]) != 0 {
+			deb.WriteString(" ")
+			deb.WriteString(lines[0])
+		}
+		deb.WriteString("\n")
+		for _, line := range lines[1:] {
+			deb.WriteString(" ")
+			if len(strings.TrimSpace(line)) == 0 {
+				deb.WriteString(".")
+			} else {
+				deb.WriteString(line)
 			}
-			deb.WriteString(value)
 			deb.WriteString("\n")
 		}
+	}
+	if written {
 		deb.WriteString("\n")
 	}
-
-	return deb.String()
 }
@@ -1,7 +1,6 @@
 package internal
 
 import (
-	"fmt"
 	"strings"
 	"testing"
 
@@ -9,50 +8,67 @@ import (
 )
 
 func TestSerializeDebFile(t *testing.T) {
-	t.Run("one_paragraph", func(t *testing.T) {
-		in := []map[string]string{
-			{
-				"Name": "Bob",
-				"Age":  "65",
-			},
-		}
-
-		actual := SerializeDebFile(in)
-		fmt.Println(strings.TrimSpace(actual))
-
-	})
-
 	t.Run("two_paragraphs", func(t *testing.T) {
-		in := []map[string]string{
-			{
-				"Name": "Bob",
-				"Age":  "65",
-			},
-			{
-				"Job":     "Engineer",
-				"Company": "FooBar Inc",
-			},
+		in := []Paragraph{
+			{{"Name", "Bob"}, {"Age", "65"}},
+			{{"Job", "Engineer"}, {"Company", "FooBar Inc"}},
 		}
 
-		actual := SerializeDebFile(in)
-		fmt.Println(strings.TrimSpace(actual))
-
+		expected := "Name: Bob\nAge: 65\n\nJob: Engineer\nCompany: FooBar Inc\n\n"
+		if diff := cmp.Diff(SerializeDebFile(in), expected); diff != "" {
+			t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+		}
 	})
 
 	t.Run("multi_line", func(t *testing.T) {
-		in := []map[string]string{
+		in := []Paragraph{
 			{
-				"ID":        "1",
-				"Checksums": DebMultiLine([]string{"", "SHA1 YES", "MD5 NO", "SHA256 YES"}),
-				"Active":    "true",
-				"C":         "D",
+				{"ID", "1"},
+				{"Checksums", "\nSHA1 YES\nMD5 NO\nSHA256 YES"},
+				{"Description", "synopsis\nfirst\n\nsecond"},
+				{"Empty", ""},
+				{"C", "D"},
 			},
 		}
 
-		actual := SerializeDebFile(in)
-		fmt.Println(actual)
+		expected := "ID: 1\nChecksums:\n SHA1 YES\n MD5 NO\n SHA256 YES\nDescription: synopsis\n first\n .\n second\nC: D\n\n"
+		if diff := cmp.Diff(SerializeDebFile(in), expected); diff != "" {
+			t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+		}
 	})
+}
+
+func TestSerializeDebParagraphOrder(t *testing.T) {
+	var in Paragraph
+	in.Set("Package", "foo")
+	in.Set("Version", "1.0.0")
+	in.Set("Architecture", "amd64")
+	in.Set("version", "2.0.0")
+	in.Set("Description", "foo")
+
+	expected := "Package: foo\nVersion: 2.0.0\nArchitecture: amd64\nDescription: foo\n\n"
+	if diff := cmp.Diff(SerializeDebParagraph(in), expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+}
 
+func TestDebFileRoundTrip(t *testing.T) {
+	in := []Paragraph{
+		{
+			{"Package", "foo"},
+			{"Description", "synopsis\n first verbatim\n\nsecond"},
+			{"SHA256", "\nabc 1 Packages\ndef 2 Packages.xz"},
+		},
+		{{"Package", "bar"}},
+	}
+
+	actual, err := DeserializeDebFile(strings.NewReader(SerializeDebFile(in)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(actual, in); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
 }
 
 func TestDeserialzeDebFile(t *testing.T) {
@@ -67,11 +83,8 @@ Age: 65
 			t.Fatal(err)
 		}
 
-		expected := []map[string]string{
-			{
-				"Name": "Bob",
-				"Age":  "65",
-			},
+		expected := []Paragraph{
+			{{"Name", "Bob"}, {"Age", "65"}},
 		}
 
 		if diff := cmp.Diff(actual, expected); diff != "" {
@@ -93,15 +106,9 @@ Company: FooBar Inc
 			t.Fatal(err)
 		}
 
-		expected := []map[string]string{
-			{
-				"Name": "Bob",
-				"Age":  "65",
-			},
-			{
-				"Job":     "Engineer",
-				"Company": "FooBar Inc",
-			},
+		expected := []Paragraph{
+			{{"Name", "Bob"}, {"Age", "65"}},
+			{{"Job", "Engineer"}, {"Company", "FooBar Inc"}},
 		}
 
 		if diff := cmp.Diff(actual, expected); diff != "" {
@@ -116,6 +123,10 @@ Age: 65
 
 Job: Software
  Engineer
+ .
+ Builder
+Checksums:
+ SHA1 YES
 Company: FooBar Inc
 `
 
@@ -124,15 +135,9 @@ Company: FooBar Inc
 			t.Fatal(err)
 		}
 
-		expected := []map[string]string{
-			{
-				"Name": "Bob",
-				"Age":  "65",
-			},
-			{
-				"Job":     "Software\nEngineer",
-				"Company": "FooBar Inc",
-			},
+		expected := []Paragraph{
+			{{"Name", "Bob"}, {"Age", "65"}},
+			{{"Job", "Software\nEngineer\n\nBuilder"}, {"Checksums", "\nSHA1 YES"}, {"Company", "FooBar Inc"}},
 		}
 
 		if diff := cmp.Diff(actual, expected); diff != "" {
@@ -0,0 +1,132 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DebDependency is a single package in a relationship field such as Depends
+// or Build-Depends, with an optional version constraint.
+type DebDependency struct {
+	Name     string
+	Relation string
+	Version  string
+}
+
+func (dependency DebDependency) String() string {
+	if len(dependency.Relation) == 0 {
+		return dependency.Name
+	}
+	return dependency.Name + " (" + dependency.Relation + " " + dependency.Version + ")"
+}
+
+var debDependencyPattern = regexp.MustCompile(`^([a-z0-9][a-z0-9+.-]+)(:[a-z0-9-]+)?\s*(\(\s*(<<|<=|=|>=|>>)\s*([A-Za-z0-9.+~:-]+)\s*\))?$`)
+
+// ParseDebRelations parses a relationship field into its comma separated
+// relations, each being alternatives separated by '|'. Architecture and
+// build profile restrictions are not supported.
+func ParseDebRelations(value string) ([][]DebDependency, error) {
+	var relations [][]DebDependency
+	for _, relation := range strings.Split(value, ",") {
+		relation = strings.TrimSpace(relation)
+		if len(relation) == 0 {
+			continue
+		}
+		var alternatives []DebDependency
+		for _, alternative := range strings.Split(relation, "|") {
+			alternative = strings.TrimSpace(alternative)
+			match := debDependencyPattern.FindStringSubmatch(alternative)
+			if match == nil {
+				return nil, Err("invalid dependency '%s'", alternative)
+			}
+			alternatives = append(alternatives, DebDependency{Name: match[1], Relation: match[4], Version: match[5]})
+		}
+		relations = append(relations, alternatives)
+	}
+	return relations, nil
+}
+
+// DpkgStatus is what packages are installed, and what they provide, from the
+// dpkg status file.
+type DpkgStatus struct {
+	installed map[string][]DebDependency
+}
+
+// NewDpkgStatus keeps the installed packages from the paragraphs of a dpkg
+// status file.
+func NewDpkgStatus(paragraphs []Paragraph) DpkgStatus {
+	status := DpkgStatus{installed: make(map[string][]DebDependency)}
+	for _, paragraph := range paragraphs {
+		fields := strings.Fields(paragraph.Get("Status"))
+		if len(fields) != 3 || fields[2] != "installed" {
+			continue
+		}
+		name := paragraph.Get("Package")
+		status.add(DebDependency{Name: name, Relation: "=", Version: paragraph.Get("Version")})
+
+		provides, err := ParseDebRelations(paragraph.Get("Provides"))
+		if err != nil {
+			continue
+		}
+		for _, alternatives := range provides {
+			status.add(alternatives[0])
+		}
+	}
+	return status
+}
+
+func (status *DpkgStatus) add(provided DebDependency) {
+	status.installed[provided.Name] = append(status.installed[provided.Name], provided)
+}
+
+// Satisfies is whether an installed package, or one it provides, meets the
+// dependency. Unversioned provides only satisfy unversioned dependencies.
+func (status *DpkgStatus) Satisfies(dependency DebDependency) bool {
+	for _, provided := range status.installed[dependency.Name] {
+		if len(dependency.Relation) == 0 {
+			return true
+		}
+		if provided.Relation != "=" {
+			continue
+		}
+		result := CompareDebVersions(provided.Version, dependency.Version)
+		switch dependency.Relation {
+		case "<<":
+			if result < 0 {
+				return true
+			}
+		case "<=":
+			if result <= 0 {
+				return true
+			}
+		case "=":
+			if result == 0 {
+				return true
+			}
+		case ">=":
+			if result >= 0 {
+				return true
+			}
+		case ">>":
+			if result > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Missing returns the relations no alternative of is satisfied.
+func (status *DpkgStatus) Missing(relations [][]DebDependency) [][]DebDependency {
+	var missing [][]DebDependency
+	for _, alternatives := range relations {
+		satisfied := false
+		for _, alternative := range alternatives {
+			satisfied = satisfied || status.Satisfies(alternative)
+		}
+		if !satisfied {
+			missing = append(missing, alternatives)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseDebRelations(t *testing.T) {
+	actual, err := ParseDebRelations("make, gcc (>= 4:10), libfoo-dev | libbar-dev, python3:any")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]DebDependency{
+		{{Name: "make"}},
+		{{Name: "gcc", Relation: ">=", Version: "4:10"}},
+		{{Name: "libfoo-dev"}, {Name: "libbar-dev"}},
+		{{Name: "python3"}},
+	}
+	if diff := cmp.Diff(actual, expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+
+	_, err = ParseDebRelations("foo [amd64]")
+	if err == nil {
+		t.Fatal("expected architecture restriction to fail")
+	}
+}
+
+func TestDpkgStatusMissing(t *testing.T) {
+	input := `Package: make
+Status: install ok installed
+Version: 4.3-4.1
+Conffiles:
+ /etc/make.conf 0123456789abcdef0123456789abcdef
+
+Package: gcc
+Status: deinstall ok config-files
+Version: 4:12.2.0-3
+
+Package: mawk
+Status: install ok installed
+Version: 1.3.4
+Provides: awk
+`
+	paragraphs, err := DeserializeDebFile(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := NewDpkgStatus(paragraphs)
+
+	relations, err := ParseDebRelations("make (>= 4), awk, gcc | clang, make (>> 5)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := [][]DebDependency{
+		{{Name: "gcc"}, {Name: "clang"}},
+		{{Name: "make", Relation: ">>", Version: "5"}},
+	}
+	if diff := cmp.Diff(status.Missing(relations), expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+}
@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareDebVersions orders two Debian versions the way dpkg does, it returns
+// a negative number when a is older than b, zero when they are equal and a
+// positive number when a is newer.
+func CompareDebVersions(a string, b string) int {
+	epochA, upstreamA, revisionA := splitDebVersion(a)
+	epochB, upstreamB, revisionB := splitDebVersion(b)
+	if epochA != epochB {
+		if epochA < epochB {
+			return -1
+		}
+		return 1
+	}
+	if result := compareDebFragment(upstreamA, upstreamB); result != 0 {
+		return result
+	}
+	return compareDebFragment(revisionA, revisionB)
+}
+
+func splitDebVersion(version string) (int, string, string) {
+	version = strings.TrimSpace(version)
+	epoch := 0
+	if value, rest, found := strings.Cut(version, ":"); found {
+		epoch, _ = strconv.Atoi(value)
+		version = rest
+	}
+	revision := ""
+	if index := strings.LastIndex(version, "-"); index != -1 {
+		revision = version[index+1:]
+		version = version[:index]
+	}
+	return epoch, version, revision
+}
+
+// compareDebFragment compares alternating runs of non digits, where '~' sorts
+// before everything and letters before other characters, and of digits.
+func compareDebFragment(a string, b string) int {
+	for len(a) != 0 || len(b) != 0 {
+		for (len(a) != 0 && !isDigit(a[0])) || (len(b) != 0 && !isDigit(b[0])) {
+			orderA, orderB := debCharOrder(a), debCharOrder(b)
+			if orderA != orderB {
+				return orderA - orderB
+			}
+			a, b = a[1:], b[1:]
+		}
+
+		for len(a) != 0 && a[0] == '0' {
+			a = a[1:]
+		}
+		for len(b) != 0 && b[0] == '0' {
+			b = b[1:]
+		}
+		result := 0
+		for len(a) != 0 && isDigit(a[0]) && len(b) != 0 && isDigit(b[0]) {
+			if result == 0 {
+				result = int(a[0]) - int(b[0])
+			}
+			a, b = a[1:], b[1:]
+		}
+		if len(a) != 0 && isDigit(a[0]) {
+			return 1
+		}
+		if len(b) != 0 && isDigit(b[0]) {
+			return -1
+		}
+		if result != 0 {
+			return result
+		}
+	}
+	return 0
+}
+
+func debCharOrder(value string) int {
+	if len(value) == 0 || isDigit(value[0]) {
+		return 0
+	}
+	c := value[0]
+	switch {
+	case c == '~':
+		return -1
+	case (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		return int(c)
+	}
+	return int(c) + 256
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
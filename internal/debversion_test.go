@@ -0,0 +1,28 @@
+package internal
+
+import "testing"
+
+func TestCompareDebVersions(t *testing.T) {
+	tests := []struct {
+		a        string
+		b        string
+		expected int
+	}{
+		{a: "1.0", b: "1.0", expected: 0},
+		{a: "1.0", b: "1.1", expected: -1},
+		{a: "1.10", b: "1.9", expected: 1},
+		{a: "1.0~rc1", b: "1.0", expected: -1},
+		{a: "1.0", b: "1.0+b1", expected: -1},
+		{a: "1:0.9", b: "2.0", expected: 1},
+		{a: "2.0-1", b: "2.0-2", expected: -1},
+		{a: "2.0-1ubuntu1", b: "2.0-1", expected: 1},
+		{a: "1.0a", b: "1.0+", expected: -1},
+		{a: "01.2", b: "1.2", expected: 0},
+	}
+	for _, test := range tests {
+		actual := CompareDebVersions(test.a, test.b)
+		if (actual < 0 && test.expected >= 0) || (actual > 0 && test.expected <= 0) || (actual == 0 && test.expected != 0) {
+			t.Fatalf("expected '%s' compared to '%s' to be %d, got %d", test.a, test.b, test.expected, actual)
+		}
+	}
+}
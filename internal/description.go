@@ -0,0 +1,167 @@
+package internal
+
+import (
+	"regexp"
+	"strings"
+)
+
+// debDescriptionWidth is how wide lines of an extended description are
+// wrapped, leaving room for the space that folds them.
+const debDescriptionWidth = 78
+
+var (
+	markdownFence     = regexp.MustCompile("^\\s*(```|~~~)")
+	markdownHeading   = regexp.MustCompile(`^\s{0,3}#{1,6}\s+(.*?)[\s#]*$`)
+	markdownRule      = regexp.MustCompile(`^\s{0,3}([-*_=]\s*){3,}$`)
+	markdownListItem  = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	markdownQuote     = regexp.MustCompile(`^\s{0,3}>\s?`)
+	markdownLinkDef   = regexp.MustCompile(`^\s{0,3}\[[^\]]+\]:\s`)
+	markdownTableRule = regexp.MustCompile(`^\s*\|?[\s:|-]+\|[\s:|-]*$`)
+	markdownComment   = regexp.MustCompile(`<!--.*?-->`)
+	markdownImage     = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	markdownLink      = regexp.MustCompile(`\[([^\]]*)\](\([^)]*\)|\[[^\]]*\])`)
+	markdownHTML      = regexp.MustCompile(`</?[a-zA-Z][^>@]*>`)
+	markdownCode      = regexp.MustCompile("`+([^`]+)`+")
+	markdownStrong    = regexp.MustCompile(`(\*\*|__)(\S(?:.*?\S)?)(\*\*|__)`)
+	markdownEmphasis  = regexp.MustCompile(`\*(\S(?:.*?\S)?)\*|\b_(\S(?:.*?\S)?)_\b`)
+	markdownStrike    = regexp.MustCompile(`~~(.+?)~~`)
+)
+
+// MarkdownText strips markdown down to plain text. Code blocks, lists and
+// tables are kept as they are laid out and indented by a space, the way an
+// extended description marks lines to be shown verbatim.
+func MarkdownText(src string) string {
+	var lines []string
+	fenced := false
+	comment := false
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		line = strings.ReplaceAll(line, "\t", "    ")
+		if markdownFence.MatchString(line) {
+			fenced = !fenced
+			lines = append(lines, "")
+			continue
+		}
+		if fenced {
+			lines = append(lines, " "+line)
+			continue
+		}
+
+		line = markdownComment.ReplaceAllString(line, "")
+		if comment {
+			end := strings.Index(line, "-->")
+			if end == -1 {
+				continue
+			}
+			line, comment = line[end+3:], false
+		}
+		if start := strings.Index(line, "<!--"); start != -1 {
+			line, comment = line[:start], true
+		}
+
+		if len(strings.TrimSpace(line)) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		if markdownLinkDef.MatchString(line) || markdownTableRule.MatchString(line) {
+			continue
+		}
+		if markdownRule.MatchString(line) {
+			lines = append(lines, "")
+			continue
+		}
+		line = markdownQuote.ReplaceAllString(line, "")
+
+		if match := markdownHeading.FindStringSubmatch(line); match != nil {
+			lines = append(lines, markdownInline(match[1]), "")
+			continue
+		}
+		if match := markdownListItem.FindStringSubmatch(line); match != nil {
+			marker := match[2]
+			if marker == "-" || marker == "+" {
+				marker = "*"
+			}
+			lines = append(lines, " "+match[1]+marker+" "+markdownInline(match[3]))
+			continue
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "|") || strings.HasPrefix(line, "    ") {
+			lines = append(lines, " "+strings.TrimRight(markdownInline(line), " "))
+			continue
+		}
+
+		text := strings.TrimSpace(markdownInline(line))
+		if len(text) != 0 {
+			lines = append(lines, text)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func markdownInline(text string) string {
+	text = markdownImage.ReplaceAllString(text, "")
+	text = markdownLink.ReplaceAllString(text, "$1")
+	text = markdownHTML.ReplaceAllString(text, "")
+	text = markdownCode.ReplaceAllString(text, "$1")
+	text = markdownStrong.ReplaceAllString(text, "$2")
+	text = markdownEmphasis.ReplaceAllString(text, "$1$2")
+	text = markdownStrike.ReplaceAllString(text, "$1")
+	return text
+}
+
+// DebDescription is the value of a Description field: the synopsis on the
+// first line followed by the extended description. Paragraphs of body are
+// wrapped, lines starting with a space are kept verbatim and empty lines
+// separate paragraphs.
+func DebDescription(synopsis string, body string) string {
+	lines := []string{strings.Join(strings.Fields(synopsis), " ")}
+	blank := false
+	emit := func(line string) {
+		if blank && len(lines) > 1 {
+			lines = append(lines, "")
+		}
+		blank = false
+		lines = append(lines, line)
+	}
+
+	var paragraph []string
+	flush := func() {
+		for _, line := range wrapWords(strings.Join(paragraph, " "), debDescriptionWidth) {
+			emit(line)
+		}
+		paragraph = nil
+	}
+
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(strings.ReplaceAll(line, "\t", "    "), " ")
+		switch {
+		case len(line) == 0:
+			flush()
+			blank = true
+		case line[0] == ' ':
+			flush()
+			emit(line)
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	flush()
+	return strings.Join(lines, "\n")
+}
+
+func wrapWords(text string, width int) []string {
+	var lines []string
+	line := strings.Builder{}
+	for _, word := range strings.Fields(text) {
+		if line.Len() != 0 && line.Len()+1+len(word) > width {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+		if line.Len() != 0 {
+			line.WriteString(" ")
+		}
+		line.WriteString(word)
+	}
+	if line.Len() != 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMarkdownText(t *testing.T) {
+	in := `# Catalogue
+
+[![build](https://ci.example.com/badge.svg)](https://ci.example.com)
+<!-- a comment -->
+Catalogue *builds* **packages** from ` + "`git`" + ` remotes,
+see [the guide][guide].
+
+## Install
+
+- run setup
+- add a remote
+  1. nested
+
+` + "```sh" + `
+catalogue add foo
+` + "```" + `
+
+> quoted text
+
+[guide]: https://example.com/guide
+`
+
+	expected := "Catalogue\n\n\n\nCatalogue builds packages from git remotes,\nsee the guide.\n\nInstall\n\n\n * run setup\n * add a remote\n   1. nested\n\n\n catalogue add foo\n\n\nquoted text\n\n"
+	if diff := cmp.Diff(MarkdownText(in), expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+}
+
+func TestDebDescription(t *testing.T) {
+	body := "\n\nFirst paragraph that is\nsplit over lines.\n\n\n * item\n * other\nAfter the list, a paragraph long enough that it has to be wrapped over more than one line of text.\n\n"
+
+	expected := "the synopsis\n" +
+		"First paragraph that is split over lines.\n" +
+		"\n" +
+		" * item\n" +
+		" * other\n" +
+		"After the list, a paragraph long enough that it has to be wrapped over more\n" +
+		"than one line of text."
+	if diff := cmp.Diff(DebDescription("  the\tsynopsis ", body), expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+}
@@ -0,0 +1,153 @@
+package explain
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+type MetadataChoice struct {
+	Field  string
+	Value  string
+	Source string
+}
+
+type FileMapChoice struct {
+	Anchor    string
+	Path      string
+	AnchorErr error
+	FileMaps  []string
+}
+
+type DownloadChoice struct {
+	Name        string
+	ID          string
+	Source      string
+	Destination string
+}
+
+type Explanation struct {
+	Component        string
+	Supported        bool
+	SupportedTargets []string
+	Metadata         []MetadataChoice
+	FileMaps         []FileMapChoice
+	Downloads        []DownloadChoice
+}
+
+// Explain resolves a component against a system the same way add, update and
+// build do, without building anything.
+func Explain(component config.Component, remote config.Remote, author string, system internal.System, host *ext.Host) Explanation {
+	explanation := Explanation{Component: component.Name}
+
+	for _, tgt := range internal.Ranked(system, component.SupportedTargets) {
+		explanation.SupportedTargets = append(explanation.SupportedTargets, tgt.Name)
+	}
+	explanation.Supported = len(explanation.SupportedTargets) != 0
+
+	metadata, sources := config.ResolveMetadata(component.Metadata, remote, author, system)
+	for _, field := range config.MetadataFields {
+		explanation.Metadata = append(explanation.Metadata, MetadataChoice{
+			Field:  field,
+			Value:  metadata.Field(field),
+			Source: sources[field],
+		})
+	}
+
+	var anchors []string
+	for anchor := range component.FileMaps {
+		anchors = append(anchors, anchor)
+	}
+	slices.Sort(anchors)
+	for _, anchor := range anchors {
+		choice := FileMapChoice{Anchor: anchor}
+		resolved, err := host.ResolveAnchor(anchor)
+		choice.Path, choice.AnchorErr = resolved.String(), err
+		for _, filemap := range internal.Ranked(system, component.FileMaps[anchor]) {
+			choice.FileMaps = append(choice.FileMaps, filemap.ID)
+		}
+		explanation.FileMaps = append(explanation.FileMaps, choice)
+	}
+
+	var names []string
+	for name := range component.Downloads {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		choice := DownloadChoice{Name: name}
+		download, matched := internal.RankedFirst(system, component.Downloads[name], &config.Download{})
+		if matched {
+			choice.ID = download.ID
+			choice.Source = download.Source.Redacted()
+			choice.Destination = download.Destination.String()
+		}
+		explanation.Downloads = append(explanation.Downloads, choice)
+	}
+
+	return explanation
+}
+
+func (explanation *Explanation) String() string {
+	out := strings.Builder{}
+
+	out.WriteString(fmt.Sprintf("component: %s\n", explanation.Component))
+	if explanation.Supported {
+		out.WriteString(fmt.Sprintf("supported: yes, by %s\n", quoteAll(explanation.SupportedTargets)))
+	} else {
+		out.WriteString("supported: no, no supported_targets match this system\n")
+	}
+
+	out.WriteString("\nmetadata:\n")
+	for _, choice := range explanation.Metadata {
+		source := "not set"
+		if choice.Source == "default" {
+			source = "default"
+		} else if len(choice.Source) != 0 {
+			source = fmt.Sprintf("from '%s'", choice.Source)
+		}
+		out.WriteString(fmt.Sprintf("  %s = '%s' (%s)\n", choice.Field, choice.Value, source))
+	}
+
+	out.WriteString("\nfilemaps:\n")
+	if len(explanation.FileMaps) == 0 {
+		out.WriteString("  none\n")
+	}
+	for _, choice := range explanation.FileMaps {
+		path := choice.Path
+		if choice.AnchorErr != nil {
+			path = "unresolved, " + strings.ReplaceAll(choice.AnchorErr.Error(), "\n", " ")
+		}
+		applied := "none apply"
+		if len(choice.FileMaps) != 0 {
+			applied = quoteAll(choice.FileMaps)
+		}
+		out.WriteString(fmt.Sprintf("  %s (%s): %s\n", choice.Anchor, path, applied))
+	}
+
+	out.WriteString("\ndownloads:\n")
+	if len(explanation.Downloads) == 0 {
+		out.WriteString("  none\n")
+	}
+	for _, choice := range explanation.Downloads {
+		if len(choice.ID) == 0 {
+			out.WriteString(fmt.Sprintf("  %s: no variant applies\n", choice.Name))
+			continue
+		}
+		out.WriteString(fmt.Sprintf("  %s: '%s' %s -> %s\n", choice.Name, choice.ID, choice.Source, choice.Destination))
+	}
+
+	return out.String()
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for idx, value := range values {
+		quoted[idx] = "'" + value + "'"
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,88 @@
+package explain
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+func TestExplain(t *testing.T) {
+	input := `
+name='foobar'
+type='package'
+supported_targets=['ubuntu']
+
+[target.ubuntu]
+os_release_id='ubuntu'
+
+[metadata.all]
+category='utilities'
+maintainer='Bob Doe'
+
+[metadata.amd64]
+maintainer='Jane Doe'
+
+[download.bin.amd64]
+src="https://foo.com/bin-x86-64"
+dst="path://root/usr/bin/foo"
+
+[download.bin.arm64]
+src="https://foo.com/bin-arm"
+dst="path://root/usr/bin/foo"
+`
+	component, err := config.Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	component.FileMaps = map[string][]*config.FileMap{
+		"root": {
+			{ID: "root.all", Anchor: "root", Target: internal.Target{Name: "all", All: true}},
+			{ID: "root.arm64", Anchor: "root", Target: internal.Target{Name: "arm64", Architecture: internal.ARM64}},
+			{ID: "root.amd64", Anchor: "root", Target: internal.Target{Name: "amd64", Architecture: internal.AMD64}},
+		},
+	}
+
+	system := internal.System{Architecture: internal.AMD64, OSReleaseID: "ubuntu"}
+	remote := config.Remote{Protocol: config.Git, URL: u("https://github.com/foo/bar.git")}
+
+	actual := Explain(component, remote, "Clone Author", system, ext.NewHost())
+
+	expected := Explanation{
+		Component:        "foobar",
+		Supported:        true,
+		SupportedTargets: []string{"ubuntu"},
+		Metadata: []MetadataChoice{
+			{Field: "dependencies"},
+			{Field: "category", Value: "utilities", Source: "all"},
+			{Field: "homepage", Value: "https://github.com/foo/bar", Source: "default"},
+			{Field: "maintainer", Value: "Jane Doe", Source: "amd64"},
+			{Field: "description"},
+			{Field: "summary", Value: "Description not provided", Source: "default"},
+			{Field: "description_file"},
+			{Field: "architecture", Value: "amd64", Source: "default"},
+		},
+		FileMaps: []FileMapChoice{
+			{Anchor: "root", Path: "/", FileMaps: []string{"root.amd64", "root.all"}},
+		},
+		Downloads: []DownloadChoice{
+			{Name: "bin", ID: "bin.amd64", Source: "https://foo.com/bin-x86-64", Destination: "path://root/usr/bin/foo"},
+		},
+	}
+
+	if diff := cmp.Diff(actual, expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+}
+
+func u(value string) *url.URL {
+	res, err := url.Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
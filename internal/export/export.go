@@ -0,0 +1,362 @@
+package export
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	pgplib "github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+	"github.com/woolawin/catalogue/internal/index"
+	"github.com/woolawin/catalogue/internal/registry"
+)
+
+// Repository is what a static repository is exported as besides its
+// packages.
+type Repository struct {
+	Suite        string
+	Release      internal.ReleaseConfig
+	Version      string
+	Architecture internal.Architecture
+	// PrivateKey signs the Release, PublicKey is exported next to it for
+	// client-setup.
+	PrivateKey *pgplib.Entity
+	PublicKey  []byte
+}
+
+// Export writes the packages of the registry into dir as a static repository
+// of a suite, laid out the way the repository is served.
+func Export(dir string, repo string, suite string, log *internal.Log, api *ext.API) bool {
+	prev := log.Stage("export")
+	defer prev()
+
+	if !internal.ValidReleaseName(suite) {
+		log.Err(nil, "invalid suite name '%s'", suite)
+		return false
+	}
+
+	cfg, err := api.Host.GetConfig()
+	if err != nil {
+		log.Err(err, "failed to read config")
+		return false
+	}
+	if cfg.PrivateAPTKey == nil {
+		log.Err(nil, "no apt repository key in %s, run 'catalogue setup' first", ext.APTKeyRingPath)
+		return false
+	}
+	public, err := os.ReadFile(ext.APTPublicGPGKeyPath)
+	if err != nil {
+		log.Err(err, "failed to read %s", ext.APTPublicGPGKeyPath)
+		return false
+	}
+	system, err := api.Host.GetSystem()
+	if err != nil {
+		log.Err(err, "failed to get system")
+		return false
+	}
+
+	packages, err := registry.ListPackages()
+	if err != nil {
+		log.Err(err, "failed to list packages")
+		return false
+	}
+	var records []config.Record
+	for _, name := range packages {
+		record, found, err := registry.GetPackageRecord(name)
+		if err != nil {
+			log.Err(err, "failed to read record of package '%s'", name)
+			return false
+		}
+		if found {
+			records = append(records, record)
+		}
+	}
+
+	return Write(dir, records, Repository{
+		Suite:        suite,
+		Release:      cfg.Release(repo, suite),
+		Version:      system.APTDistroVersion,
+		Architecture: system.Architecture,
+		PrivateKey:   cfg.PrivateAPTKey,
+		PublicKey:    public,
+	}, time.Now(), log)
+}
+
+// Write writes the latest build of every record into dir. Only what changed
+// is written, a pool file already there with the digest of its build is not
+// copied again. The pool is only ever added to, an older Packages index may
+// still point into it.
+func Write(dir string, records []config.Record, repository Repository, now time.Time, log *internal.Log) bool {
+	records = slices.Clone(records)
+	slices.SortFunc(records, func(a, b config.Record) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	copied := 0
+	var paragraphs []internal.Paragraph
+	for _, record := range records {
+		build, found := latestBuild(record)
+		if !found {
+			log.Info(5, "package '%s' has no build of '%s', not exporting it", record.Name, record.LatestPin.VersionName)
+			continue
+		}
+		changed, err := copyPool(filepath.Join(dir, filepath.FromSlash(index.PoolPath(record))), build)
+		if err != nil {
+			log.Err(err, "failed to export package '%s'", record.Name)
+			return false
+		}
+		if changed {
+			copied++
+			log.Info(8, "copied package '%s' version '%s'", record.Name, record.LatestPin.VersionName)
+		}
+		paragraphs = append(paragraphs, index.PackageParagraph(record, build))
+	}
+
+	files, err := index.Packages(paragraphs, repository.Architecture)
+	if err != nil {
+		log.Err(err, "failed to create Packages index")
+		return false
+	}
+
+	dists := filepath.Join(dir, "dists", repository.Suite)
+	releasePath := filepath.Join(dists, "Release")
+	previous, err := releasedDigests(releasePath)
+	if err != nil {
+		log.Err(err, "failed to read previous Release")
+		return false
+	}
+
+	var modified time.Time
+	for _, file := range files {
+		changed, err := writeFile(filepath.Join(dists, filepath.FromSlash(file.Path)), file.Data)
+		if err != nil {
+			log.Err(err, "failed to export %s", file.Path)
+			return false
+		}
+		if changed.After(modified) {
+			modified = changed
+		}
+		_, err = writeFile(filepath.Join(dists, filepath.FromSlash(file.ByHashPath())), file.Data)
+		if err != nil {
+			log.Err(err, "failed to export %s by hash", file.Path)
+			return false
+		}
+	}
+	err = pruneByHash(dists, files, previous)
+	if err != nil {
+		log.Err(err, "failed to remove old indexes by hash")
+		return false
+	}
+
+	date := index.ReleaseDate(modified, repository.Release.ValidFor, now)
+	release := []byte(internal.SerializeDebParagraph(index.Release(repository.Release, index.Suite{
+		Name:          repository.Suite,
+		Version:       repository.Version,
+		Architectures: []internal.Architecture{repository.Architecture},
+		Date:          date,
+	}, files)))
+
+	// the Release goes last, until then clients keep reading the previous one
+	// whose indexes are still there by hash
+	ok := writeRelease(dists, release, repository.PrivateKey, log)
+	if !ok {
+		return false
+	}
+	_, err = writeFile(filepath.Join(dir, "key.gpg"), repository.PublicKey)
+	if err != nil {
+		log.Err(err, "failed to export key.gpg")
+		return false
+	}
+
+	log.Info(5, "exported %d packages to '%s', copied %d into the pool", len(paragraphs), dir, copied)
+	return true
+}
+
+// latestBuild is the build of the version the record is pinned to.
+func latestBuild(record config.Record) (config.BuildFile, bool) {
+	for _, build := range record.Builds {
+		if build.Version == record.LatestPin.VersionName && build.CommitHash == record.LatestPin.CommitHash {
+			return build, true
+		}
+	}
+	return config.BuildFile{}, false
+}
+
+// copyPool copies a build into the pool unless it is already there, and
+// checks what it copied is the build the index says it is.
+func copyPool(dst string, build config.BuildFile) (bool, error) {
+	if digest, err := fileSHA256(dst); err == nil && digest == build.SHA245 {
+		return false, nil
+	}
+
+	src, err := os.Open(build.Path)
+	if err != nil {
+		return false, internal.ErrOf(err, "can not open build '%s'", build.Path)
+	}
+	defer src.Close()
+
+	hasher := sha256.New()
+	err = internal.AtomicWrite(dst, io.TeeReader(src, hasher), func() error {
+		if digest := hex.EncodeToString(hasher.Sum(nil)); digest != build.SHA245 {
+			return internal.Err("build '%s' has digest '%s' instead of '%s'", build.Path, digest, build.SHA245)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, file)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// writeFile writes data to path unless it already has it, and returns when
+// the file last changed.
+func writeFile(path string, data []byte) (time.Time, error) {
+	existing, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(existing, data) {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, internal.ErrOf(err, "can not stat file '%s'", path)
+		}
+		return info.ModTime(), nil
+	}
+
+	err = internal.AtomicWrite(path, bytes.NewReader(data), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, internal.ErrOf(err, "can not stat file '%s'", path)
+	}
+	return info.ModTime(), nil
+}
+
+// writeRelease writes the Release with its detached signature and InRelease,
+// signing it again only when it changed.
+func writeRelease(dists string, release []byte, key *pgplib.Entity, log *internal.Log) bool {
+	releasePath := filepath.Join(dists, "Release")
+	signaturePath := filepath.Join(dists, "Release.gpg")
+	inReleasePath := filepath.Join(dists, "InRelease")
+
+	existing, err := os.ReadFile(releasePath)
+	if err == nil && bytes.Equal(existing, release) && exists(signaturePath) && exists(inReleasePath) {
+		log.Info(9, "Release did not change")
+		return true
+	}
+
+	signature, err := internal.PGPDetachSign(key, release)
+	if err != nil {
+		log.Err(err, "failed to sign Release")
+		return false
+	}
+	inRelease, err := internal.PGPSign(key, release)
+	if err != nil {
+		log.Err(err, "failed to sign InRelease")
+		return false
+	}
+
+	// apt reads InRelease first, it is written once the rest is in place
+	for _, file := range []struct {
+		path string
+		data []byte
+	}{
+		{releasePath, release},
+		{signaturePath, []byte(signature)},
+		{inReleasePath, []byte(inRelease)},
+	} {
+		err = internal.AtomicWrite(file.path, bytes.NewReader(file.data), nil)
+		if err != nil {
+			log.Err(err, "failed to export %s", filepath.Base(file.path))
+			return false
+		}
+	}
+	return true
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// releasedDigests are the digests the SHA256 field of a Release lists, the
+// indexes clients that read it may still fetch by hash.
+func releasedDigests(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, internal.ErrOf(err, "can not read '%s'", path)
+	}
+	defer file.Close()
+
+	paragraphs, err := internal.DeserializeDebFile(file)
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not parse '%s'", path)
+	}
+	var digests []string
+	for _, paragraph := range paragraphs {
+		for _, line := range strings.Split(paragraph.Get("SHA256"), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 3 {
+				digests = append(digests, fields[0])
+			}
+		}
+	}
+	return digests, nil
+}
+
+// pruneByHash removes the indexes by hash that neither the new Release nor
+// the one it replaces list.
+func pruneByHash(dists string, files []index.File, previous []string) error {
+	kept := slices.Clone(previous)
+	var dirs []string
+	for _, file := range files {
+		kept = append(kept, file.SHA256())
+		dir := path.Dir(file.ByHashPath())
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, dir := range dirs {
+		dir = filepath.Join(dists, filepath.FromSlash(dir))
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return internal.ErrOf(err, "can not list directory '%s'", dir)
+		}
+		for _, entry := range entries {
+			if slices.Contains(kept, entry.Name()) {
+				continue
+			}
+			err = os.Remove(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				return internal.ErrOf(err, "can not remove '%s'", entry.Name())
+			}
+		}
+	}
+	return nil
+}
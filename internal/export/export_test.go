@@ -0,0 +1,160 @@
+package export
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	pgplib "github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+)
+
+func testRecord(t *testing.T, dir string, name string, contents string) config.Record {
+	path := filepath.Join(dir, name+".deb")
+	err := os.WriteFile(path, []byte(contents), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := sha256.Sum256([]byte(contents))
+	pin := config.Pin{VersionName: "1.0.0", CommitHash: "abc123"}
+	return config.Record{
+		Name:      name,
+		LatestPin: pin,
+		Metadata:  config.Metadata{Architecture: "amd64", Maintainer: "Bob Doe", Description: name},
+		Builds: []config.BuildFile{{
+			Version:    pin.VersionName,
+			CommitHash: pin.CommitHash,
+			Path:       path,
+			Size:       int64(len(contents)),
+			SHA245:     hex.EncodeToString(hash[:]),
+		}},
+	}
+}
+
+func TestWrite(t *testing.T) {
+	key, err := internal.CreateOpenPGPKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := internal.ReadPrivateKey(key.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repository := Repository{
+		Suite:        "stable",
+		Release:      internal.ReleaseConfig{Origin: "Catalogue", Label: "Catalogue"},
+		Version:      "24.04",
+		Architecture: "amd64",
+		PrivateKey:   private,
+		PublicKey:    key.Public,
+	}
+
+	builds := t.TempDir()
+	dir := t.TempDir()
+	records := []config.Record{testRecord(t, builds, "foo", "foo package"), testRecord(t, builds, "bar", "bar package")}
+	log := internal.NewLog(internal.NewStdoutLogger(0))
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if !Write(dir, records, repository, now, log) {
+		t.Fatal("expected export to succeed")
+	}
+
+	pool := filepath.Join(dir, "pool", "foo", "1.0.0", "abc123", "install.deb")
+	data, err := os.ReadFile(pool)
+	if err != nil || string(data) != "foo package" {
+		t.Fatalf("expected foo in the pool, got '%s' %v", data, err)
+	}
+	dists := filepath.Join(dir, "dists", "stable")
+	packages, err := os.ReadFile(filepath.Join(dists, "packages", "binary-amd64", "Packages"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(packages), "Package: bar\n") || !strings.Contains(string(packages), "Filename: pool/foo/1.0.0/abc123/install.deb\n") {
+		t.Fatalf("unexpected Packages:\n%s", packages)
+	}
+	for _, name := range []string{"Packages.gz", "Packages.xz", "Packages.zst"} {
+		if !exists(filepath.Join(dists, "packages", "binary-amd64", name)) {
+			t.Fatalf("expected %s", name)
+		}
+	}
+	hash := sha256.Sum256(packages)
+	if !exists(filepath.Join(dists, "packages", "binary-amd64", "by-hash", "SHA256", hex.EncodeToString(hash[:]))) {
+		t.Fatal("expected Packages by hash")
+	}
+
+	release, err := os.ReadFile(filepath.Join(dists, "Release"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := os.ReadFile(filepath.Join(dists, "Release.gpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := pgplib.ReadKeyRing(bytes.NewReader(key.Public))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pgplib.CheckArmoredDetachedSignature(keyring, bytes.NewReader(release), bytes.NewReader(signature), nil)
+	if err != nil {
+		t.Fatalf("expected Release.gpg to sign Release: %v", err)
+	}
+	inRelease, err := os.ReadFile(filepath.Join(dists, "InRelease"))
+	if err != nil || !strings.Contains(string(inRelease), "Origin: Catalogue") {
+		t.Fatalf("expected a signed InRelease, got '%s' %v", inRelease, err)
+	}
+	public, err := os.ReadFile(filepath.Join(dir, "key.gpg"))
+	if err != nil || !bytes.Equal(public, key.Public) {
+		t.Fatalf("expected the public key, %v", err)
+	}
+
+	// nothing changed, nothing is written again
+	old := now.Add(-24 * time.Hour)
+	err = os.Chtimes(pool, old, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Write(dir, records, repository, now.Add(time.Hour), log) {
+		t.Fatal("expected export to succeed")
+	}
+	info, err := os.Stat(pool)
+	if err != nil || !info.ModTime().Equal(old) {
+		t.Fatalf("expected the pool file not to be copied again, %v", err)
+	}
+	again, err := os.ReadFile(filepath.Join(dists, "Release"))
+	if err != nil || !bytes.Equal(again, release) {
+		t.Fatalf("expected the same Release, %v", err)
+	}
+
+	byHash := filepath.Join(dists, "packages", "binary-amd64", "by-hash", "SHA256")
+	if !Write(dir, records[:1], repository, now.Add(2*time.Hour), log) {
+		t.Fatal("expected export to succeed")
+	}
+	entries, err := os.ReadDir(byHash)
+	if err != nil || len(entries) != 8 {
+		t.Fatalf("expected the indexes of both Releases by hash, got %d %v", len(entries), err)
+	}
+	if !Write(dir, records[:1], repository, now.Add(3*time.Hour), log) {
+		t.Fatal("expected export to succeed")
+	}
+	entries, err = os.ReadDir(byHash)
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("expected only the indexes of the last Release by hash, got %d %v", len(entries), err)
+	}
+
+	corrupt := records[0]
+	corrupt.Builds = []config.BuildFile{corrupt.Builds[0]}
+	corrupt.Builds[0].SHA245 = strings.Repeat("0", 64)
+	if Write(dir, []config.Record{corrupt}, repository, now, log) {
+		t.Fatal("expected a build not matching its digest to fail")
+	}
+	data, err = os.ReadFile(pool)
+	if err != nil || string(data) != "foo package" {
+		t.Fatalf("expected the pool file to be kept, got '%s' %v", data, err)
+	}
+}
@@ -16,12 +16,15 @@ type DiskPath string
 type Disk interface {
 	Path(parts ...string) DiskPath
 	ReadFile(path DiskPath) ([]byte, bool, error)
+	ReadHead(path DiskPath, size int) ([]byte, error)
 	WriteFile(path DiskPath, data io.Reader) error
 	DirExists(path DiskPath) (bool, bool, error)
 	FileExists(path DiskPath) (bool, bool, error)
 	List(path DiskPath) ([]DiskPath, []DiskPath, error)
 	ListRec(path DiskPath) ([]DiskPath, error)
 	MoveFileTo(toDisk Disk, dstPath DiskPath, srcPath DiskPath) error
+	Chmod(path DiskPath, mode os.FileMode) error
+	Symlink(path DiskPath, target string) error
 	Unsafe(path DiskPath) bool
 }
 
@@ -52,6 +55,33 @@ func (disk *diskImpl) ReadFile(path DiskPath) ([]byte, bool, error) {
 	return data, true, nil
 }
 
+// ReadHead reads at most size bytes from the start of a regular file, other
+// files read as empty.
+func (disk *diskImpl) ReadHead(path DiskPath, size int) ([]byte, error) {
+	if disk.Unsafe(path) {
+		return nil, errFileBlocked(path, "read")
+	}
+	info, err := os.Lstat(string(path))
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not read file '%s'", path)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	file, err := os.Open(string(path))
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not read file '%s'", path)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, int64(size)))
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not read file '%s'", path)
+	}
+	return data, nil
+}
+
 func (disk *diskImpl) WriteFile(path DiskPath, data io.Reader) error {
 	if disk.Unsafe(path) {
 		return errFileBlocked(path, "read")
@@ -166,10 +196,84 @@ func (disk *diskImpl) MoveFileTo(toDisk Disk, dstPath DiskPath, srcPath DiskPath
 
 	os.MkdirAll(filepath.Dir(string(dstPath)), 0755)
 	err = os.Rename(string(srcPath), string(dstPath))
+	if err == nil {
+		return nil
+	}
+
+	// rename does not work across file systems, fallback to copying while
+	// keeping the mode so executables stay executable
+	err = copyFile(string(dstPath), string(srcPath))
 	if err != nil {
 		return internal.ErrOf(err, "failed to move file")
 	}
+	os.Remove(string(srcPath))
+
+	return nil
+}
+
+func copyFile(dstPath string, srcPath string) error {
+	info, err := os.Lstat(srcPath)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(srcPath)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, dstPath)
+	}
 
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(dstPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(dstPath, info.Mode().Perm())
+}
+
+func (disk *diskImpl) Chmod(path DiskPath, mode os.FileMode) error {
+	if disk.Unsafe(path) {
+		return errFileBlocked(path, "modified")
+	}
+	info, err := os.Lstat(string(path))
+	if err != nil {
+		return internal.ErrOf(err, "can not change mode of file '%s'", path)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return internal.Err("can not change mode of symlink '%s'", path)
+	}
+	err = os.Chmod(string(path), mode)
+	if err != nil {
+		return internal.ErrOf(err, "can not change mode of file '%s'", path)
+	}
+	return nil
+}
+
+func (disk *diskImpl) Symlink(path DiskPath, target string) error {
+	if disk.Unsafe(path) {
+		return errFileBlocked(path, "written")
+	}
+	err := os.MkdirAll(filepath.Dir(string(path)), 0755)
+	if err != nil {
+		return internal.ErrOf(err, "failed to create directory for symlink '%s'", path)
+	}
+	err = os.Symlink(target, string(path))
+	if err != nil {
+		return internal.ErrOf(err, "can not create symlink '%s'", path)
+	}
 	return nil
 }
 
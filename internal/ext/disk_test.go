@@ -1,6 +1,8 @@
 package ext
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -16,3 +18,35 @@ func TestFilePath(t *testing.T) {
 		t.Fatalf("'%s' not correct", path)
 	}
 }
+
+func TestSymlink(t *testing.T) {
+	base := t.TempDir()
+	disk := NewDisk(base)
+
+	tests := []struct {
+		name   string
+		path   DiskPath
+		target string
+		ok     bool
+	}{
+		{"creates_parents", disk.Path("usr", "bin", "foo"), "/opt/foo/bin/foo", true},
+		{"relative_target", disk.Path("usr", "lib", "libfoo.so"), "libfoo.so.1", true},
+		{"exists", disk.Path("usr", "bin", "foo"), "/opt/foo/bin/other", false},
+		{"outside", DiskPath(filepath.Join(filepath.Dir(base), "escape")), "/etc", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := disk.Symlink(test.path, test.target)
+			if (err == nil) != test.ok {
+				t.Fatalf("expected ok %t, got error %v", test.ok, err)
+			}
+			if !test.ok {
+				return
+			}
+			target, err := os.Readlink(string(test.path))
+			if err != nil || target != test.target {
+				t.Fatalf("expected symlink to '%s', got '%s'", test.target, target)
+			}
+		})
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"log/slog"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
+	"syscall"
 	"time"
 
 	pgplib "github.com/ProtonMail/go-crypto/openpgp"
@@ -15,8 +17,38 @@ import (
 
 const ConfigPath = "/etc/catalogue/config.toml"
 
+// CredentialsPath must only be readable by root, it holds tokens for remotes.
+const CredentialsPath = "/etc/catalogue/credentials.toml"
+
+// ClientsPath must only be readable by root, it holds the tokens clients of
+// the repository authenticate with.
+const ClientsPath = "/etc/catalogue/clients.toml"
+
+// The certificate authority and server certificate setup generates when tls
+// is self signed.
+const TLSDir = "/etc/catalogue/tls"
+const TLSCAPath = "/etc/catalogue/tls/ca.crt"
+const TLSCAKeyPath = "/etc/catalogue/tls/ca.key"
+const TLSCertPath = "/etc/catalogue/tls/server.crt"
+const TLSKeyPath = "/etc/catalogue/tls/server.key"
+
 const APTSourceListPath = "/etc/apt/sources.list.d/catalogue.list"
 
+// APTConfPath configures how apt reaches the repository of this host.
+const APTConfPath = "/etc/apt/apt.conf.d/60catalogue"
+
+// Where client-setup keeps what apt needs to use the repository of another
+// host.
+const APTKeyRingsDir = "/etc/apt/keyrings"
+const APTConfDir = "/etc/apt/apt.conf.d"
+const APTAuthConfDir = "/etc/apt/auth.conf.d"
+const APTSourceListDir = "/etc/apt/sources.list.d"
+const ClientTLSDir = "/etc/catalogue/client-tls"
+
+const APTPreferencesPath = "/etc/apt/preferences.d/catalogue"
+
+const DpkgStatusPath = "/var/lib/dpkg/status"
+
 const APTKeyRingPath = "/etc/catalogue/apt-keyring"
 const APTPublicGPGKeyPath = "/etc/catalogue/apt-keyring/public.gpg"
 const APTPrivateGPGKeyPath = "/etc/catalogue/apt-keyring/private.bin"
@@ -26,24 +58,57 @@ func NewHost() *Host {
 }
 
 type Host struct {
-	config *internal.Config
+	config      *internal.Config
+	credentials map[string]internal.Credential
 }
 
-func (host *Host) ResolveAnchor(value string) (string, error) {
-	if value == "root" {
-		return "/", nil
+// Anchors map a filemap or download anchor name to where its files are
+// installed. Paths starting with '~' are relative to each user's home.
+var Anchors = map[string]string{
+	"root":           "/",
+	"etc":            "/etc",
+	"opt":            "/opt",
+	"usr_local":      "/usr/local",
+	"share":          "/usr/share",
+	"systemd_system": "/lib/systemd/system",
+	"systemd_user":   "/usr/lib/systemd/user",
+	"applications":   "/usr/share/applications",
+	"icons":          "/usr/share/icons",
+	"home":           "~",
+	"xdg_config":     "~/.config",
+}
+
+type Anchor struct {
+	Name string
+	// Path is absolute, or relative to a user's home directory when Home is set.
+	Path string
+	Home bool
+}
+
+func (anchor Anchor) String() string {
+	if anchor.Home {
+		return filepath.Join("~", anchor.Path)
 	}
-	if value == "home" {
+	return anchor.Path
+}
+
+func (host *Host) ResolveAnchor(value string) (Anchor, error) {
+	path, found := Anchors[value]
+	if !found {
 		config, err := host.GetConfig()
 		if err != nil {
-			return "", err
-		}
-		if len(config.DefaultUser) == 0 {
-			return "", internal.Err("no default user specified in '%s' for home anchor", ConfigPath)
+			return Anchor{}, err
 		}
-		return "/home/" + config.DefaultUser, nil
+		path, found = config.Anchors[value]
+	}
+	if !found {
+		return Anchor{}, internal.Err("unknown anchor '%s'", value)
 	}
-	return "", internal.Err("unknown anchor '%s'", value)
+
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return Anchor{Name: value, Path: path}, nil
+	}
+	return Anchor{Name: value, Path: strings.TrimPrefix(strings.TrimPrefix(path, "~"), "/"), Home: true}, nil
 }
 
 func (host *Host) GetSystem() (internal.System, error) {
@@ -116,6 +181,90 @@ func (host *Host) GetConfig() (internal.Config, error) {
 	return config, nil
 }
 
+// GetCredential returns the credential for a host from the credentials file.
+func (host *Host) GetCredential(hostname string) (internal.Credential, bool, error) {
+	if host.credentials == nil {
+		credentials, err := loadCredentials()
+		if err != nil {
+			return internal.Credential{}, false, err
+		}
+		host.credentials = credentials
+	}
+	credential, found := host.credentials[strings.ToLower(hostname)]
+	return credential, found, nil
+}
+
+func loadCredentials() (map[string]internal.Credential, error) {
+	info, err := os.Stat(CredentialsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]internal.Credential{}, nil
+		}
+		return nil, internal.ErrOf(err, "can not read credentials file")
+	}
+
+	data, err := readRootOnly(info, CredentialsPath)
+	if err != nil {
+		return nil, err
+	}
+	return internal.ParseCredentials(bytes.NewReader(data))
+}
+
+// GetClients returns the tokens of the clients file by client name.
+func (host *Host) GetClients() (map[string]string, error) {
+	info, err := os.Stat(ClientsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, internal.ErrOf(err, "can not read clients file")
+	}
+
+	data, err := readRootOnly(info, ClientsPath)
+	if err != nil {
+		return nil, err
+	}
+	return internal.ParseClients(bytes.NewReader(data))
+}
+
+// readRootOnly reads a file holding secrets, refusing it when anyone but
+// root could have read or changed it.
+func readRootOnly(info os.FileInfo, path string) ([]byte, error) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0077 != 0 || (ok && stat.Uid != 0) {
+		return nil, internal.Err("'%s' must be a file owned and only readable by root", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not read '%s'", path)
+	}
+	return data, nil
+}
+
+// TLSFiles are the certificate and key the repository is served with.
+func TLSFiles(tls internal.TLSConfig) (string, string) {
+	if tls.SelfSigned {
+		return TLSCertPath, TLSKeyPath
+	}
+	return tls.Cert, tls.Key
+}
+
+// GetDpkgStatus reads what packages are installed from the dpkg status file.
+func (host *Host) GetDpkgStatus() (internal.DpkgStatus, error) {
+	file, err := os.Open(DpkgStatusPath)
+	if err != nil {
+		return internal.DpkgStatus{}, internal.ErrOf(err, "can not read '%s'", DpkgStatusPath)
+	}
+	defer file.Close()
+
+	paragraphs, err := internal.DeserializeDebFile(file)
+	if err != nil {
+		return internal.DpkgStatus{}, internal.ErrOf(err, "can not parse '%s'", DpkgStatusPath)
+	}
+	return internal.NewDpkgStatus(paragraphs), nil
+}
+
 func loadPrivatePGPKey() *pgplib.Entity {
 	privBytes, err := os.ReadFile(APTPrivateGPGKeyPath)
 	if err != nil {
@@ -1,6 +1,10 @@
 package ext
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/woolawin/catalogue/internal"
+)
 
 func TestFindOSReleaseValue(t *testing.T) {
 	t.Run("found", func(t *testing.T) {
@@ -56,3 +60,34 @@ func TestFindOSReleaseValue(t *testing.T) {
 		}
 	})
 }
+
+func TestResolveAnchor(t *testing.T) {
+	host := Host{config: &internal.Config{
+		Anchors: map[string]string{"srv": "/srv", "games": "~/games"},
+	}}
+
+	cases := map[string]Anchor{
+		"root":       {Name: "root", Path: "/"},
+		"etc":        {Name: "etc", Path: "/etc"},
+		"share":      {Name: "share", Path: "/usr/share"},
+		"home":       {Name: "home", Path: "", Home: true},
+		"xdg_config": {Name: "xdg_config", Path: ".config", Home: true},
+		"srv":        {Name: "srv", Path: "/srv"},
+		"games":      {Name: "games", Path: "games", Home: true},
+	}
+
+	for anchor, expected := range cases {
+		actual, err := host.ResolveAnchor(anchor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Fatalf("expected anchor '%s' to be '%v', got '%v'", anchor, expected, actual)
+		}
+	}
+
+	_, err := host.ResolveAnchor("nope")
+	if err == nil {
+		t.Fatal("expected unknown anchor to fail")
+	}
+}
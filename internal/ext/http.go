@@ -1,6 +1,7 @@
 package ext
 
 import (
+	"crypto/tls"
 	"io"
 	"net/http"
 	"net/url"
@@ -12,24 +13,55 @@ func NewHTTP() *HTTP {
 	return &HTTP{client: http.Client{}}
 }
 
+// NewTLSHTTP is HTTP with its own tls config, to trust a private certificate
+// authority or present a client certificate.
+func NewTLSHTTP(config *tls.Config) *HTTP {
+	return &HTTP{client: http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: config}}}
+}
+
 type HTTP struct {
 	client http.Client
 }
 
 func (impl *HTTP) Fetch(url *url.URL) ([]byte, error) {
-	request, err := http.NewRequest("GET", url.String(), nil)
+	return impl.FetchAuthorized(url, internal.Credential{})
+}
+
+// FetchAuthorized fetches with a token from credential, as a bearer token or
+// basic auth when the credential has a username.
+func (impl *HTTP) FetchAuthorized(url *url.URL, credential internal.Credential) ([]byte, error) {
+	return impl.Send("GET", url, nil, nil, credential)
+}
+
+// Send makes a request with extra headers and returns the response body.
+func (impl *HTTP) Send(method string, url *url.URL, headers map[string]string, body io.Reader, credential internal.Credential) ([]byte, error) {
+	request, err := http.NewRequest(method, url.String(), body)
 	if err != nil {
-		return nil, internal.ErrOf(err, "failed to fetch '%s'", url.String())
+		return nil, internal.ErrOf(err, "failed to fetch '%s'", url.Redacted())
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	// headers given by a server, as LFS does, already authorize the request
+	if len(request.Header.Get("Authorization")) == 0 {
+		if len(credential.Token) != 0 && len(credential.Username) != 0 {
+			request.SetBasicAuth(credential.Username, credential.Token)
+		} else if len(credential.Token) != 0 {
+			request.Header.Set("Authorization", "Bearer "+credential.Token)
+		}
 	}
 
 	response, err := impl.client.Do(request)
 	if err != nil {
-		return nil, internal.ErrOf(err, "request failed to '%s'", url.String())
+		return nil, internal.ErrOf(err, "request failed to '%s'", url.Redacted())
 	}
 	defer response.Body.Close()
-	body, err := io.ReadAll(response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return nil, internal.Err("request to '%s' failed with status '%s'", url.Redacted(), response.Status)
+	}
+	data, err := io.ReadAll(response.Body)
 	if err != nil {
-		return nil, internal.ErrOf(err, "failed to read response from %s", url.String())
+		return nil, internal.ErrOf(err, "failed to read response from %s", url.Redacted())
 	}
-	return body, nil
+	return data, nil
 }
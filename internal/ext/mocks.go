@@ -2,6 +2,7 @@ package ext
 
 import (
 	"io"
+	"os"
 	"path/filepath"
 	"slices"
 	"strings"
@@ -54,6 +55,10 @@ func (mock *MockDisk) List(path DiskPath) ([]DiskPath, []DiskPath, error) {
 	return files, dirs, nil
 }
 
+func (mock *MockDisk) ReadHead(path DiskPath, size int) ([]byte, error) {
+	return nil, nil
+}
+
 func (mock *MockDisk) WriteFile(path DiskPath, data io.Reader) error {
 	return nil
 }
@@ -66,6 +71,14 @@ func (mock *MockDisk) MoveFileTo(disk Disk, toPath DiskPath, fromPath DiskPath)
 	return nil
 }
 
+func (mock *MockDisk) Chmod(path DiskPath, mode os.FileMode) error {
+	return nil
+}
+
+func (mock *MockDisk) Symlink(path DiskPath, target string) error {
+	return nil
+}
+
 func (mock *MockDisk) Unsafe(path DiskPath) bool {
 	return false
 }
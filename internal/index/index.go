@@ -0,0 +1,120 @@
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+)
+
+// Component is the only component of the repository.
+const Component = "packages"
+
+// Variant is a compression the Packages index is published with.
+type Variant struct {
+	Compression string
+	Name        string
+	ContentType string
+	compress    func([]byte) ([]byte, error)
+}
+
+var Variants = []Variant{
+	{Compression: "plain", Name: "Packages", ContentType: "text/plain; charset=utf-8"},
+	{Compression: "gz", Name: "Packages.gz", ContentType: "application/gzip", compress: internal.Gzip},
+	{Compression: "xz", Name: "Packages.xz", ContentType: "application/x-xz", compress: internal.XZ},
+	{Compression: "zst", Name: "Packages.zst", ContentType: "application/zstd", compress: internal.Zstd},
+}
+
+// DebContentType is the media type of packages in the pool.
+const DebContentType = "application/vnd.debian.binary-package"
+
+// VariantNamed is the variant of a Packages file name.
+func VariantNamed(name string) (Variant, bool) {
+	for _, variant := range Variants {
+		if variant.Name == name {
+			return variant, true
+		}
+	}
+	return Variant{}, false
+}
+
+// File is a generated index file, Path is relative to the dists directory of
+// the suite.
+type File struct {
+	Path        string
+	Compression string
+	Data        []byte
+}
+
+func (file File) SHA256() string {
+	hash := sha256.Sum256(file.Data)
+	return hex.EncodeToString(hash[:])
+}
+
+// ByHashPath is where apt fetches the file by its digest when the Release
+// has Acquire-By-Hash.
+func (file File) ByHashPath() string {
+	return path.Join(path.Dir(file.Path), "by-hash", "SHA256", file.SHA256())
+}
+
+// PoolPath is where the package of a record is in the repository.
+func PoolPath(record config.Record) string {
+	return path.Join("pool", record.Name, record.LatestPin.VersionName, record.LatestPin.CommitHash, "install.deb")
+}
+
+// PackageParagraph is the Packages entry of a record built into buildFile.
+func PackageParagraph(record config.Record, buildFile config.BuildFile) internal.Paragraph {
+	return internal.Paragraph{
+		{Name: "Package", Value: record.Name},
+		{Name: "Version", Value: record.LatestPin.VersionName},
+		{Name: "Architecture", Value: record.Metadata.Architecture},
+		{Name: "Maintainer", Value: record.Metadata.Maintainer},
+		{Name: "Depends", Value: record.Metadata.Dependencies},
+		{Name: "Filename", Value: PoolPath(record)},
+		{Name: "Size", Value: strconv.FormatInt(buildFile.Size, 10)},
+		{Name: "SHA256", Value: buildFile.SHA245},
+		{Name: "Section", Value: record.Metadata.Category},
+		{Name: "Homepage", Value: record.Metadata.Homepage},
+		{Name: "Description", Value: record.Metadata.Description},
+	}
+}
+
+// Packages is the Packages index of the paragraphs in every variant, sorted
+// by package so the same packages always give the same index.
+func Packages(paragraphs []internal.Paragraph, arch internal.Architecture) ([]File, error) {
+	paragraphs = slices.Clone(paragraphs)
+	slices.SortFunc(paragraphs, func(a, b internal.Paragraph) int {
+		return strings.Compare(a.Get("Package"), b.Get("Package"))
+	})
+	plain := []byte(internal.SerializeDebFile(paragraphs))
+
+	dir := path.Join(Component, fmt.Sprintf("binary-%s", arch))
+	var files []File
+	for _, variant := range Variants {
+		data := plain
+		if variant.compress != nil {
+			var err error
+			data, err = variant.compress(plain)
+			if err != nil {
+				return nil, internal.ErrOf(err, "can not compress %s", variant.Name)
+			}
+		}
+		files = append(files, File{Path: path.Join(dir, variant.Name), Compression: variant.Compression, Data: data})
+	}
+	return files, nil
+}
+
+// Checksums is the SHA256 field of a Release file listing files.
+func Checksums(files []File) string {
+	lines := []string{""}
+	for _, file := range files {
+		lines = append(lines, fmt.Sprintf("%s %d %s", file.SHA256(), len(file.Data), file.Path))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,55 @@
+package index
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/woolawin/catalogue/internal"
+)
+
+func TestPackages(t *testing.T) {
+	paragraphs := []internal.Paragraph{
+		{{Name: "Package", Value: "zed"}},
+		{{Name: "Package", Value: "abc"}},
+	}
+
+	files, err := Packages(paragraphs, internal.AMD64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	expected := []string{
+		"packages/binary-amd64/Packages",
+		"packages/binary-amd64/Packages.gz",
+		"packages/binary-amd64/Packages.xz",
+		"packages/binary-amd64/Packages.zst",
+	}
+	if diff := cmp.Diff(paths, expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+
+	if string(files[0].Data) != "Package: abc\n\nPackage: zed\n\n" {
+		t.Fatalf("unexpected Packages:\n%s", files[0].Data)
+	}
+
+	if files[1].ByHashPath() != "packages/binary-amd64/by-hash/SHA256/"+files[1].SHA256() {
+		t.Fatalf("unexpected by-hash path '%s'", files[1].ByHashPath())
+	}
+
+	checksums := strings.Split(Checksums(files), "\n")
+	if len(checksums) != 5 || checksums[0] != "" {
+		t.Fatalf("unexpected checksums %q", checksums)
+	}
+	for idx, file := range files {
+		line := fmt.Sprintf("%s %d %s", file.SHA256(), len(file.Data), file.Path)
+		if checksums[idx+1] != line {
+			t.Fatalf("expected '%s', got '%s'", line, checksums[idx+1])
+		}
+	}
+}
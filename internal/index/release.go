@@ -0,0 +1,71 @@
+package index
+
+import (
+	"time"
+
+	"github.com/woolawin/catalogue/internal"
+)
+
+// DateFormat is how apt expects the dates of a Release file, RFC 2822 in UTC.
+const DateFormat = "Mon, 02 Jan 2006 15:04:05 UTC"
+
+// Suite is what a Release file describes besides its config.
+type Suite struct {
+	Name          string
+	Version       string
+	Architectures []internal.Architecture
+	// Date is when the indexes last changed, see ReleaseDate.
+	Date time.Time
+}
+
+// ReleaseDate is the date of a Release whose indexes last changed at
+// modified. A Release that expires moves forward in steps of half its
+// validity, so one is never served past its Valid-Until while it stays the
+// same between the steps.
+func ReleaseDate(modified time.Time, validFor time.Duration, now time.Time) time.Time {
+	modified = modified.UTC().Truncate(time.Second)
+	step := (validFor / 2).Truncate(time.Second)
+	if step <= 0 || now.Before(modified) {
+		return modified
+	}
+	steps := now.Sub(modified) / step
+	return modified.Add(steps * step)
+}
+
+// Release is the Release paragraph of a suite listing files, in the order
+// of the Debian repository format.
+func Release(release internal.ReleaseConfig, suite Suite, files []File) internal.Paragraph {
+	paragraph := internal.Paragraph{
+		{Name: "Origin", Value: release.Origin},
+		{Name: "Label", Value: release.Label},
+		{Name: "Suite", Value: suite.Name},
+		{Name: "Version", Value: suite.Version},
+		{Name: "Codename", Value: suite.Name},
+		{Name: "Date", Value: suite.Date.UTC().Format(DateFormat)},
+	}
+	if release.ValidFor != 0 {
+		paragraph.Set("Valid-Until", suite.Date.UTC().Add(release.ValidFor).Format(DateFormat))
+	}
+	if release.NotAutomatic != nil && *release.NotAutomatic {
+		paragraph.Set("NotAutomatic", "yes")
+		if release.ButAutomaticUpgrades != nil && *release.ButAutomaticUpgrades {
+			paragraph.Set("ButAutomaticUpgrades", "yes")
+		}
+	}
+
+	architectures := ""
+	for idx, arch := range suite.Architectures {
+		if idx != 0 {
+			architectures += " "
+		}
+		architectures += string(arch)
+	}
+	paragraph = append(paragraph, internal.Paragraph{
+		{Name: "Acquire-By-Hash", Value: "yes"},
+		{Name: "Architectures", Value: architectures},
+		{Name: "Components", Value: Component},
+		{Name: "Description", Value: release.Description},
+		{Name: "SHA256", Value: Checksums(files)},
+	}...)
+	return paragraph
+}
@@ -0,0 +1,70 @@
+package index
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/woolawin/catalogue/internal"
+)
+
+func TestRelease(t *testing.T) {
+	yes := true
+	release := internal.ReleaseConfig{
+		Origin:               "Example",
+		Label:                "Example Testing",
+		Description:          "Release candidates",
+		ValidFor:             7 * 24 * time.Hour,
+		NotAutomatic:         &yes,
+		ButAutomaticUpgrades: &yes,
+	}
+	suite := Suite{
+		Name:          "testing",
+		Architectures: []internal.Architecture{internal.AMD64},
+		Date:          time.Date(2025, 3, 1, 9, 5, 0, 0, time.FixedZone("CET", 3600)),
+	}
+	files := []File{{Path: "packages/binary-amd64/Packages", Data: []byte{}}}
+
+	expected := "Origin: Example\n" +
+		"Label: Example Testing\n" +
+		"Suite: testing\n" +
+		"Codename: testing\n" +
+		"Date: Sat, 01 Mar 2025 08:05:00 UTC\n" +
+		"Valid-Until: Sat, 08 Mar 2025 08:05:00 UTC\n" +
+		"NotAutomatic: yes\n" +
+		"ButAutomaticUpgrades: yes\n" +
+		"Acquire-By-Hash: yes\n" +
+		"Architectures: amd64\n" +
+		"Components: packages\n" +
+		"Description: Release candidates\n" +
+		"SHA256:\n" +
+		" e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855 0 packages/binary-amd64/Packages\n\n"
+	actual := internal.SerializeDebParagraph(Release(release, suite, files))
+	if diff := cmp.Diff(actual, expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+}
+
+func TestReleaseDate(t *testing.T) {
+	modified := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	for _, test := range []struct {
+		validFor time.Duration
+		now      time.Time
+		expected time.Time
+	}{
+		{0, modified.Add(30 * day), modified},
+		{2 * day, modified.Add(12 * time.Hour), modified},
+		{2 * day, modified.Add(day), modified.Add(day)},
+		{2 * day, modified.Add(3*day + time.Hour), modified.Add(3 * day)},
+	} {
+		actual := ReleaseDate(modified, test.validFor, test.now)
+		if !actual.Equal(test.expected) {
+			t.Fatalf("expected %s valid for %s at %s, got %s", test.expected, test.validFor, test.now, actual)
+		}
+		if test.validFor != 0 && !actual.Add(test.validFor).After(test.now) {
+			t.Fatalf("release dated %s expired at %s", actual, test.now)
+		}
+	}
+}
@@ -1,5 +1,11 @@
 package internal
 
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
 type BytesCounter struct {
 	count int64
 }
@@ -12,3 +18,47 @@ func (counter *BytesCounter) Write(p []byte) (int, error) {
 func (counter *BytesCounter) Count() int64 {
 	return counter.count
 }
+
+// AtomicWrite replaces path with the contents of src once check, when given,
+// accepts them. Whoever reads path, like a web server serving it, never sees
+// a file half written.
+func AtomicWrite(path string, src io.Reader, check func() error) error {
+	parent := filepath.Dir(path)
+	err := os.MkdirAll(parent, 0755)
+	if err != nil {
+		return ErrOf(err, "can not create directory '%s'", parent)
+	}
+
+	tmp, err := os.CreateTemp(parent, "."+filepath.Base(path)+".*")
+	if err != nil {
+		return ErrOf(err, "can not create file in '%s'", parent)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, src)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return ErrOf(err, "can not write to file '%s'", path)
+	}
+	if check != nil {
+		err = check()
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return ErrOf(err, "can not move file into '%s'", path)
+	}
+	return nil
+}
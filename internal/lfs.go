@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LFSPointerMaxSize is the largest a git-lfs pointer file can be.
+const LFSPointerMaxSize = 1024
+
+const lfsPointerVersion = "version https://git-lfs.github.com/spec/v1"
+
+var lfsOID = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// LFSPointer is what git-lfs commits in place of a file, the file itself is
+// stored on an LFS server by its SHA256.
+type LFSPointer struct {
+	OID  string
+	Size int64
+}
+
+func ParseLFSPointer(data []byte) (LFSPointer, bool) {
+	if len(data) > LFSPointerMaxSize || !bytes.HasPrefix(data, []byte(lfsPointerVersion)) {
+		return LFSPointer{}, false
+	}
+
+	pointer := LFSPointer{Size: -1}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, _ := strings.Cut(scanner.Text(), " ")
+		switch key {
+		case "oid":
+			oid, found := strings.CutPrefix(value, "sha256:")
+			if found && lfsOID.MatchString(oid) {
+				pointer.OID = oid
+			}
+		case "size":
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err == nil && size >= 0 {
+				pointer.Size = size
+			}
+		}
+	}
+	if len(pointer.OID) == 0 || pointer.Size < 0 {
+		return LFSPointer{}, false
+	}
+	return pointer, true
+}
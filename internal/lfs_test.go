@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseLFSPointer(t *testing.T) {
+	oid := "4d7a214614ab2935c943f9e0ff69d22eadbb8f32b1258daaa5e2ca24d17e2393"
+	tests := []struct {
+		name     string
+		input    string
+		expected LFSPointer
+		ok       bool
+	}{
+		{
+			name:     "pointer",
+			input:    "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\nsize 12345\n",
+			expected: LFSPointer{OID: oid, Size: 12345},
+			ok:       true,
+		},
+		{
+			name:  "plain file",
+			input: "hello world\n",
+		},
+		{
+			name:  "missing size",
+			input: "version https://git-lfs.github.com/spec/v1\noid sha256:" + oid + "\n",
+		},
+		{
+			name:  "bad oid",
+			input: "version https://git-lfs.github.com/spec/v1\noid sha256:abc\nsize 1\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, ok := ParseLFSPointer([]byte(test.input))
+			if ok != test.ok {
+				t.Fatalf("expected ok to be %v", test.ok)
+			}
+			if diff := cmp.Diff(test.expected, actual); diff != "" {
+				t.Fatalf("pointer mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package internal
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// TLSConfig is the certificate the repository is served with, SelfSigned uses
+// the one setup generates from its own certificate authority.
+type TLSConfig struct {
+	Cert       string
+	Key        string
+	SelfSigned bool
+}
+
+type TLSConfigTOML struct {
+	Cert       string `toml:"cert,omitempty"`
+	Key        string `toml:"key,omitempty"`
+	SelfSigned bool   `toml:"self_signed,omitempty"`
+}
+
+func (tls TLSConfig) Enabled() bool {
+	return len(tls.Cert) != 0 || tls.SelfSigned
+}
+
+type AuthMode string
+
+const (
+	NoAuth    AuthMode = ""
+	TokenAuth AuthMode = "token"
+	MTLSAuth  AuthMode = "mtls"
+)
+
+// AuthConfig is how clients authenticate. Tokens are kept in the clients
+// file, client certificates must be signed by ClientCA. TrustHost, on by
+// default, lets the host itself through without auth so its own apt works.
+// A proxy on the host, like apt-cacher-ng, then lets its clients through as
+// well, trust_host = false stops that.
+type AuthConfig struct {
+	Mode      AuthMode
+	ClientCA  string
+	TrustHost bool
+}
+
+type AuthConfigTOML struct {
+	Mode      string `toml:"mode,omitempty"`
+	ClientCA  string `toml:"client_ca,omitempty"`
+	TrustHost *bool  `toml:"trust_host,omitempty"`
+}
+
+// ListenAddresses are the addresses the repository is served on, localhost
+// on the configured port unless listen says otherwise.
+func (config *Config) ListenAddresses() []string {
+	if len(config.Listen) == 0 {
+		return []string{net.JoinHostPort("localhost", strconv.Itoa(config.Port))}
+	}
+	return config.Listen
+}
+
+// loadListen gives every address a port, the configured port when it has
+// none.
+func loadListen(addresses []string, port int) ([]string, error) {
+	var listen []string
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		host, addressPort, err := net.SplitHostPort(address)
+		if err != nil {
+			host, addressPort = strings.Trim(address, "[]"), strconv.Itoa(port)
+		}
+		if strings.ContainsAny(host, "/ \t") {
+			return nil, Err("invalid listen address '%s'", address)
+		}
+		number, err := strconv.Atoi(addressPort)
+		if err != nil || number < 1 || number > 65535 {
+			return nil, Err("invalid port of listen address '%s'", address)
+		}
+		listen = append(listen, net.JoinHostPort(host, addressPort))
+	}
+	return listen, nil
+}
+
+func loadTLS(toml TLSConfigTOML) (TLSConfig, error) {
+	tls := TLSConfig{
+		Cert:       strings.TrimSpace(toml.Cert),
+		Key:        strings.TrimSpace(toml.Key),
+		SelfSigned: toml.SelfSigned,
+	}
+	if (len(tls.Cert) == 0) != (len(tls.Key) == 0) {
+		return TLSConfig{}, Err("tls needs both a cert and a key")
+	}
+	if len(tls.Cert) != 0 && tls.SelfSigned {
+		return TLSConfig{}, Err("tls can not have a cert and be self signed")
+	}
+	for _, path := range []string{tls.Cert, tls.Key} {
+		if len(path) != 0 && !strings.HasPrefix(path, "/") {
+			return TLSConfig{}, Err("tls path '%s' must be absolute", path)
+		}
+	}
+	return tls, nil
+}
+
+func loadAuth(toml AuthConfigTOML, tls TLSConfig) (AuthConfig, error) {
+	auth := AuthConfig{
+		Mode:      AuthMode(strings.TrimSpace(toml.Mode)),
+		ClientCA:  strings.TrimSpace(toml.ClientCA),
+		TrustHost: toml.TrustHost == nil || *toml.TrustHost,
+	}
+	switch auth.Mode {
+	case NoAuth:
+		return auth, nil
+	case TokenAuth, MTLSAuth:
+	default:
+		return AuthConfig{}, Err("unknown auth mode '%s', expected 'token' or 'mtls'", auth.Mode)
+	}
+	// tokens and certificates are only worth anything when nobody can read
+	// them off the network
+	if !tls.Enabled() {
+		return AuthConfig{}, Err("auth mode '%s' requires tls", auth.Mode)
+	}
+	// nothing issues client certificates from the self signed certificate
+	// authority, whoever issues them gives their own
+	if auth.Mode == MTLSAuth && len(auth.ClientCA) == 0 {
+		return AuthConfig{}, Err("auth mode 'mtls' needs a client_ca")
+	}
+	if len(auth.ClientCA) != 0 && !strings.HasPrefix(auth.ClientCA, "/") {
+		return AuthConfig{}, Err("client_ca path '%s' must be absolute", auth.ClientCA)
+	}
+	return auth, nil
+}
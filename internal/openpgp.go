@@ -3,6 +3,8 @@ package internal
 import (
 	"bytes"
 	"crypto"
+	"encoding/hex"
+	"strings"
 	"time"
 
 	pgplib "github.com/ProtonMail/go-crypto/openpgp"
@@ -30,6 +32,17 @@ func PGPSign(key *pgplib.Entity, data []byte) (string, error) {
 	return buf.String(), nil
 }
 
+// PGPDetachSign is the armored detached signature of data, as Release.gpg
+// is next to a Release file.
+func PGPDetachSign(key *pgplib.Entity, data []byte) (string, error) {
+	var buf bytes.Buffer
+	err := pgplib.ArmoredDetachSign(&buf, key, bytes.NewReader(data), &packetlib.Config{DefaultHash: crypto.SHA256})
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
 type PGPKey struct {
 	Public  []byte
 	Private []byte
@@ -74,3 +87,16 @@ func ReadPrivateKey(private []byte) (*pgplib.Entity, error) {
 	}
 	return entities[0], nil
 }
+
+// PGPFingerprint is the fingerprint of the one key in keyring, in upper case
+// hex as gpg prints it.
+func PGPFingerprint(keyring []byte) (string, error) {
+	entities, err := pgplib.ReadKeyRing(bytes.NewReader(keyring))
+	if err != nil {
+		return "", ErrOf(err, "can not read openpgp key")
+	}
+	if len(entities) != 1 {
+		return "", Err("expected one openpgp key, found %d", len(entities))
+	}
+	return strings.ToUpper(hex.EncodeToString(entities[0].PrimaryKey.Fingerprint)), nil
+}
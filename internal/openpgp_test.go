@@ -1,8 +1,12 @@
 package internal
 
 import (
+	"bytes"
 	"fmt"
+	"strings"
 	"testing"
+
+	pgplib "github.com/ProtonMail/go-crypto/openpgp"
 )
 
 func TestSignPGP(t *testing.T) {
@@ -24,3 +28,30 @@ func TestSignPGP(t *testing.T) {
 	fmt.Println("============================")
 	fmt.Println(string(signature))
 }
+
+func TestDetachSignPGP(t *testing.T) {
+	key, err := CreateOpenPGPKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	priv, err := ReadPrivateKey(key.Private)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := []byte("Origin: Catalogue\n")
+	signature, err := PGPDetachSign(priv, message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyring, err := pgplib.ReadKeyRing(bytes.NewReader(key.Public))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pgplib.CheckArmoredDetachedSignature(keyring, bytes.NewReader(message), strings.NewReader(signature), nil)
+	if err != nil {
+		t.Fatalf("expected a valid signature: %v", err)
+	}
+}
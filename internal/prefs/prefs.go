@@ -0,0 +1,72 @@
+package prefs
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+	"github.com/woolawin/catalogue/internal/registry"
+)
+
+const header = "# This file is auto-generated, any changes may be override later on with `catalogue prefs`\n\n"
+
+// Preferences is the apt preferences file pinning every package that has a
+// priority to the catalogue repository, which apt tells apart by its origin.
+func Preferences(records []config.Record, origin string) string {
+	records = slices.Clone(records)
+	slices.SortFunc(records, func(a, b config.Record) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	var paragraphs []internal.Paragraph
+	for _, record := range records {
+		if record.Priority == 0 {
+			continue
+		}
+		paragraphs = append(paragraphs, internal.Paragraph{
+			{Name: "Explanation", Value: "catalogue package " + record.Name},
+			{Name: "Package", Value: record.Name},
+			{Name: "Pin", Value: "release o=" + origin},
+			{Name: "Pin-Priority", Value: strconv.Itoa(record.Priority)},
+		})
+	}
+	return header + internal.SerializeDebFile(paragraphs)
+}
+
+// Write regenerates the apt preferences file from the records in the
+// registry.
+func Write(cfg internal.Config, log *internal.Log) bool {
+	prev := log.Stage("prefs")
+	defer prev()
+
+	packages, err := registry.ListPackages()
+	if err != nil {
+		log.Err(err, "failed to list packages")
+		return false
+	}
+
+	var records []config.Record
+	for _, name := range packages {
+		record, found, err := registry.GetPackageRecord(name)
+		if err != nil {
+			log.Err(err, "failed to read record of package '%s'", name)
+			return false
+		}
+		if found {
+			records = append(records, record)
+		}
+	}
+
+	origin := cfg.Release(internal.HostRepository, internal.HostSuite).Origin
+	err = os.WriteFile(ext.APTPreferencesPath, []byte(Preferences(records, origin)), 0644)
+	if err != nil {
+		log.Err(err, "failed to write %s", ext.APTPreferencesPath)
+		return false
+	}
+	log.Info(9, "wrote %s", ext.APTPreferencesPath)
+	return true
+}
@@ -0,0 +1,35 @@
+package prefs
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/woolawin/catalogue/internal/config"
+)
+
+func TestPreferences(t *testing.T) {
+	records := []config.Record{
+		{Name: "ripgrep", Priority: 100},
+		{Name: "fzf"},
+		{Name: "bat", Priority: 1001},
+	}
+
+	actual := Preferences(records, "Catalogue")
+	expected := `# This file is auto-generated, any changes may be override later on with ` + "`catalogue prefs`" + `
+
+Explanation: catalogue package bat
+Package: bat
+Pin: release o=Catalogue
+Pin-Priority: 1001
+
+Explanation: catalogue package ripgrep
+Package: ripgrep
+Pin: release o=Catalogue
+Pin-Priority: 100
+
+`
+
+	if diff := cmp.Diff(actual, expected); diff != "" {
+		t.Fatalf("Mismatch (-actual +expected):\n%s", diff)
+	}
+}
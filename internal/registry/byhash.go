@@ -0,0 +1,99 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+)
+
+// IndexGenerations is how many snapshots of the indexes stay available by
+// hash, so apt can still fetch what an older Release it has read lists.
+const IndexGenerations = 3
+
+// CacheGeneration keeps the files of a snapshot of the indexes by their
+// SHA256 digest and drops the files only older generations had.
+func CacheGeneration(files map[string][]byte) error {
+	dir := releaseCachePath("by-hash", "SHA256")
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return internal.ErrOf(err, "can not create by-hash cache directory '%s'", dir)
+	}
+
+	var digests []string
+	for digest, contents := range files {
+		path := releaseCachePath("by-hash", "SHA256", digest)
+		// files are named by their contents, one that is there is unchanged
+		if _, err = os.Stat(path); err == nil {
+			digests = append(digests, digest)
+			continue
+		}
+		err = internal.AtomicWrite(path, bytes.NewReader(contents), nil)
+		if err != nil {
+			return err
+		}
+		digests = append(digests, digest)
+	}
+	slices.Sort(digests)
+
+	generationsPath := releaseCachePath("generations")
+	data, err := os.ReadFile(generationsPath)
+	if err != nil && !os.IsNotExist(err) {
+		return internal.ErrOf(err, "can not read file '%s'", generationsPath)
+	}
+	var generations []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if len(strings.TrimSpace(line)) != 0 {
+			generations = append(generations, line)
+		}
+	}
+	current := strings.Join(digests, " ")
+	// a snapshot of unchanged indexes is not a new generation
+	if len(generations) != 0 && generations[len(generations)-1] == current {
+		return nil
+	}
+	generations = append(generations, current)
+	if len(generations) > IndexGenerations {
+		generations = generations[len(generations)-IndexGenerations:]
+	}
+
+	err = internal.AtomicWrite(generationsPath, strings.NewReader(strings.Join(generations, "\n")+"\n"), nil)
+	if err != nil {
+		return err
+	}
+
+	kept := strings.Fields(strings.Join(generations, " "))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return internal.ErrOf(err, "can not list directory '%s'", dir)
+	}
+	for _, entry := range entries {
+		if !slices.Contains(kept, entry.Name()) {
+			os.Remove(releaseCachePath("by-hash", "SHA256", entry.Name()))
+		}
+	}
+	return nil
+}
+
+// ReadByHash reads an index file of one of the kept generations.
+func ReadByHash(digest string) (CachedIndex, bool, error) {
+	if _, err := hex.DecodeString(digest); err != nil || len(digest) != 64 {
+		return CachedIndex{}, false, nil
+	}
+	path := releaseCachePath("by-hash", "SHA256", digest)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return CachedIndex{}, false, nil
+		}
+		return CachedIndex{}, false, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return CachedIndex{}, false, err
+	}
+	return CachedIndex{Data: data, Modified: info.ModTime()}, true, nil
+}
@@ -2,16 +2,19 @@ package registry
 
 import (
 	"bytes"
-	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/woolawin/catalogue/internal"
 	"github.com/woolawin/catalogue/internal/config"
 )
 
 const releasesCacheBase = "/tmp/catalogue/releases"
-const packagesBase = "/var/lib/catalogue/components/packages"
+
+// PackagesBase is where the registry keeps its packages, tests point it into
+// a temporary directory.
+var PackagesBase = "/var/lib/catalogue/components/packages"
 
 func RemovePackage(name string) (bool, error) {
 	err := os.RemoveAll(packagePath(name))
@@ -25,9 +28,12 @@ func RemovePackage(name string) (bool, error) {
 }
 
 func ListPackages() ([]string, error) {
-	entries, err := os.ReadDir(packagesBase)
+	entries, err := os.ReadDir(PackagesBase)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
 	if err != nil {
-		return nil, internal.ErrOf(err, "can not list directory '%s'", packagesBase)
+		return nil, internal.ErrOf(err, "can not list directory '%s'", PackagesBase)
 	}
 
 	var dirs []string
@@ -40,43 +46,54 @@ func ListPackages() ([]string, error) {
 	return dirs, nil
 }
 
-func CacheRelease(compression string, contents []byte) error {
-	if len(contents) == 0 {
-		return nil
-	}
+// CachedIndex is a cached index and when its contents last changed.
+type CachedIndex struct {
+	Data     []byte
+	Modified time.Time
+}
+
+// CacheRelease keeps an index in a compression, an empty index is cached
+// as well since it is what a repository without packages serves. An index
+// that did not change is left alone, so it keeps when it was modified.
+func CacheRelease(compression string, contents []byte) (time.Time, error) {
 	path := releaseCachePath(compression, "latest")
-	parent := filepath.Dir(path)
 
-	err := os.MkdirAll(parent, 0755)
+	cached, found, err := ReadReleaseCache(compression)
 	if err != nil {
-		return internal.ErrOf(err, "can not create releases cache directory '%s'", parent)
+		return time.Time{}, internal.ErrOf(err, "can not read release cache file '%s'", path)
+	}
+	if found && bytes.Equal(cached.Data, contents) {
+		return cached.Modified, nil
 	}
 
-	file, err := os.Create(path)
+	err = internal.AtomicWrite(path, bytes.NewReader(contents), nil)
 	if err != nil {
-		return internal.ErrOf(err, "failed to create release cache file '%s'", path)
+		return time.Time{}, err
 	}
-	defer file.Close()
 
-	_, err = io.Copy(file, bytes.NewReader(contents))
+	info, err := os.Stat(path)
 	if err != nil {
-		return internal.ErrOf(err, "can not write to file '%s'", path)
+		return time.Time{}, internal.ErrOf(err, "can not stat file '%s'", path)
 	}
-
-	return nil
+	return info.ModTime(), nil
 }
 
-func ReadReleaseCache(compression string) (string, bool, error) {
+func ReadReleaseCache(compression string) (CachedIndex, bool, error) {
 	path := releaseCachePath(compression, "latest")
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return "", false, nil
+			return CachedIndex{}, false, nil
 		}
-		return "", false, err
+		return CachedIndex{}, false, err
 	}
 
-	return string(data), true, nil
+	info, err := os.Stat(path)
+	if err != nil {
+		return CachedIndex{}, false, err
+	}
+
+	return CachedIndex{Data: data, Modified: info.ModTime()}, true, nil
 }
 
 func GetPackageRecord(packageName string) (config.Record, bool, error) {
@@ -95,6 +112,8 @@ func GetPackageRecord(packageName string) (config.Record, bool, error) {
 	return record, true, nil
 }
 
+// PackageBuildFile creates a temporary file next to where the build of a
+// commit goes, SaveBuildFile moves it there once the build is complete.
 func PackageBuildFile(record config.Record, hash string) (*os.File, error) {
 	path := packagePath(record.Name, "caches", hash, "build.deb")
 	parent := filepath.Dir(path)
@@ -102,36 +121,41 @@ func PackageBuildFile(record config.Record, hash string) (*os.File, error) {
 	if err != nil {
 		return nil, internal.ErrOf(err, "can not create directory '%s'", parent)
 	}
-	return os.Create(path)
+	return os.CreateTemp(parent, ".build.deb.*")
 }
 
-func WriteRecord(record config.Record) error {
-	path := packagePath(record.Name, "record.toml")
-
-	parent := filepath.Dir(path)
-	err := os.MkdirAll(parent, 0755)
+// SaveBuildFile moves a complete build from PackageBuildFile into place and
+// returns where it is, a build being served is never seen half written.
+func SaveBuildFile(file *os.File) (string, error) {
+	path := filepath.Join(filepath.Dir(file.Name()), "build.deb")
+	err := file.Chmod(0644)
+	if err == nil {
+		err = file.Sync()
+	}
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
 	if err != nil {
-		return internal.ErrOf(err, "can not create component directory '%s'", parent)
+		return "", internal.ErrOf(err, "can not write to file '%s'", file.Name())
 	}
-
-	file, err := os.Create(path)
+	err = os.Rename(file.Name(), path)
 	if err != nil {
-		return internal.ErrOf(err, "can not create component file '%s'", path)
+		return "", internal.ErrOf(err, "can not move build into '%s'", path)
 	}
-	defer file.Close()
+	return path, nil
+}
+
+func WriteRecord(record config.Record) error {
+	path := packagePath(record.Name, "record.toml")
 
 	var buffer bytes.Buffer
-	err = config.SerializeRecord(&buffer, record)
+	err := config.SerializeRecord(&buffer, record)
 	if err != nil {
 		return internal.ErrOf(err, "can not serialize record file")
 	}
 
-	_, err = io.Copy(file, &buffer)
-	if err != nil {
-		return internal.ErrOf(err, "can not write to file '%s'", path)
-	}
-
-	return nil
+	return internal.AtomicWrite(path, &buffer, nil)
 }
 
 func HasPackage(name string) (bool, error) {
@@ -147,7 +171,7 @@ func HasPackage(name string) (bool, error) {
 }
 
 func packagePath(parts ...string) string {
-	return filepath.Join(append([]string{packagesBase}, parts...)...)
+	return filepath.Join(append([]string{PackagesBase}, parts...)...)
 }
 
 func releaseCachePath(parts ...string) string {
@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReleaseConfig is what the Release file of a suite says about it.
+type ReleaseConfig struct {
+	Origin      string
+	Label       string
+	Description string
+	// ValidFor is how long a Release stays valid after its date, zero for
+	// ever.
+	ValidFor time.Duration
+	// NotAutomatic keeps apt from installing from the suite unless asked to,
+	// ButAutomaticUpgrades still upgrades what was installed from it. Either
+	// is nil when not given, so a suite can turn off what its repository set.
+	NotAutomatic         *bool
+	ButAutomaticUpgrades *bool
+}
+
+type ReleaseConfigTOML struct {
+	Origin               string `toml:"origin,omitempty"`
+	Label                string `toml:"label,omitempty"`
+	Description          string `toml:"description,omitempty"`
+	ValidFor             string `toml:"valid_for,omitempty"`
+	NotAutomatic         *bool  `toml:"not_automatic,omitempty"`
+	ButAutomaticUpgrades *bool  `toml:"but_automatic_upgrades,omitempty"`
+}
+
+// RepositoryConfig is the release config of a repository, its suites
+// override it.
+type RepositoryConfig struct {
+	ReleaseConfig
+	Suites map[string]ReleaseConfig
+}
+
+type RepositoryConfigTOML struct {
+	ReleaseConfigTOML
+	Suites map[string]ReleaseConfigTOML `toml:"suites,omitempty"`
+}
+
+const defaultReleaseOrigin = "Catalogue"
+
+// HostRepository and HostSuite are the repository and suite setup adds to
+// the apt sources of the host.
+const (
+	HostRepository = "catalogue"
+	HostSuite      = "stable"
+)
+
+// Release is the release config of a suite of a repository.
+func (config *Config) Release(repo string, suite string) ReleaseConfig {
+	release := ReleaseConfig{Origin: defaultReleaseOrigin, Label: defaultReleaseOrigin}
+	repository, found := config.Repositories[repo]
+	if !found {
+		return release
+	}
+	release = release.override(repository.ReleaseConfig)
+	if suiteConfig, found := repository.Suites[suite]; found {
+		release = release.override(suiteConfig)
+	}
+	return release
+}
+
+func (release ReleaseConfig) override(other ReleaseConfig) ReleaseConfig {
+	if len(other.Origin) != 0 {
+		release.Origin = other.Origin
+	}
+	if len(other.Label) != 0 {
+		release.Label = other.Label
+	}
+	if len(other.Description) != 0 {
+		release.Description = other.Description
+	}
+	if other.ValidFor != 0 {
+		release.ValidFor = other.ValidFor
+	}
+	if other.NotAutomatic != nil {
+		release.NotAutomatic = other.NotAutomatic
+	}
+	if other.ButAutomaticUpgrades != nil {
+		release.ButAutomaticUpgrades = other.ButAutomaticUpgrades
+	}
+	return release
+}
+
+func loadRepositories(deserialized map[string]RepositoryConfigTOML) (map[string]RepositoryConfig, error) {
+	if len(deserialized) == 0 {
+		return nil, nil
+	}
+	repositories := make(map[string]RepositoryConfig)
+	for name, toml := range deserialized {
+		name = strings.TrimSpace(name)
+		if !ValidReleaseName(name) {
+			return nil, Err("invalid repository name '%s'", name)
+		}
+		release, err := loadReleaseConfig(toml.ReleaseConfigTOML)
+		if err != nil {
+			return nil, ErrOf(err, "invalid repository '%s'", name)
+		}
+		repository := RepositoryConfig{ReleaseConfig: release}
+		for suite, suiteTOML := range toml.Suites {
+			suite = strings.TrimSpace(suite)
+			if !ValidReleaseName(suite) {
+				return nil, Err("invalid suite name '%s' of repository '%s'", suite, name)
+			}
+			release, err = loadReleaseConfig(suiteTOML)
+			if err != nil {
+				return nil, ErrOf(err, "invalid suite '%s' of repository '%s'", suite, name)
+			}
+			if repository.Suites == nil {
+				repository.Suites = make(map[string]ReleaseConfig)
+			}
+			repository.Suites[suite] = release
+		}
+		repositories[name] = repository
+	}
+	return repositories, nil
+}
+
+// ValidReleaseName is whether a repository or suite name can be a path
+// segment of the repository URLs.
+func ValidReleaseName(name string) bool {
+	return len(name) != 0 && name != "." && name != ".." && !strings.ContainsAny(name, "/ \t\n")
+}
+
+func loadReleaseConfig(toml ReleaseConfigTOML) (ReleaseConfig, error) {
+	release := ReleaseConfig{
+		Origin:               strings.TrimSpace(toml.Origin),
+		Label:                strings.TrimSpace(toml.Label),
+		Description:          strings.TrimSpace(toml.Description),
+		NotAutomatic:         toml.NotAutomatic,
+		ButAutomaticUpgrades: toml.ButAutomaticUpgrades,
+	}
+	for _, value := range []string{release.Origin, release.Label, release.Description} {
+		if strings.ContainsAny(value, "\n") {
+			return ReleaseConfig{}, Err("'%s' must be a single line", value)
+		}
+	}
+	// apt preferences pin by origin, where a comma separates conditions
+	if strings.Contains(release.Origin, ",") {
+		return ReleaseConfig{}, Err("origin '%s' must not have a comma", release.Origin)
+	}
+	validFor, err := parseValidFor(strings.TrimSpace(toml.ValidFor))
+	if err != nil {
+		return ReleaseConfig{}, err
+	}
+	release.ValidFor = validFor
+	return release, nil
+}
+
+// parseValidFor parses a duration, which can also be given in days like '7d'.
+func parseValidFor(value string) (time.Duration, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+	var duration time.Duration
+	var err error
+	if days, found := strings.CutSuffix(value, "d"); found {
+		var count int
+		count, err = strconv.Atoi(days)
+		duration = time.Duration(count) * 24 * time.Hour
+	} else {
+		duration, err = time.ParseDuration(value)
+	}
+	if err != nil || duration <= 0 {
+		return 0, Err("invalid valid_for '%s', expected a duration like '7d' or '12h'", value)
+	}
+	return duration, nil
+}
+
+func toRepositoriesTOML(repositories map[string]RepositoryConfig) map[string]RepositoryConfigTOML {
+	if len(repositories) == 0 {
+		return nil
+	}
+	toml := make(map[string]RepositoryConfigTOML)
+	for name, repository := range repositories {
+		repositoryTOML := RepositoryConfigTOML{ReleaseConfigTOML: toReleaseConfigTOML(repository.ReleaseConfig)}
+		for suite, release := range repository.Suites {
+			if repositoryTOML.Suites == nil {
+				repositoryTOML.Suites = make(map[string]ReleaseConfigTOML)
+			}
+			repositoryTOML.Suites[suite] = toReleaseConfigTOML(release)
+		}
+		toml[name] = repositoryTOML
+	}
+	return toml
+}
+
+func toReleaseConfigTOML(release ReleaseConfig) ReleaseConfigTOML {
+	toml := ReleaseConfigTOML{
+		Origin:               release.Origin,
+		Label:                release.Label,
+		Description:          release.Description,
+		NotAutomatic:         release.NotAutomatic,
+		ButAutomaticUpgrades: release.ButAutomaticUpgrades,
+	}
+	if release.ValidFor != 0 {
+		toml.ValidFor = release.ValidFor.String()
+	}
+	return toml
+}
@@ -0,0 +1,287 @@
+package setup
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// ClientOpts is how to reach the repository of another host. CA, Cert and Key
+// are paths, Cert and Key for when it asks for a client certificate.
+// Fingerprint is of the key the other host signs with, as its setup prints it.
+type ClientOpts struct {
+	URL         string
+	Token       string
+	CA          string
+	Cert        string
+	Key         string
+	Fingerprint string
+}
+
+// ClientSetUp adds the repository of another host to apt on this one, with
+// the key its Release files are signed with and whatever apt needs to
+// authenticate to it.
+func ClientSetUp(opts ClientOpts, log *internal.Log) bool {
+	prev := log.Stage("client-setup")
+	defer prev()
+
+	repository, err := repositoryURL(opts.URL)
+	if err != nil {
+		log.Err(err, "invalid repository url")
+		return false
+	}
+	if (len(opts.Cert) == 0) != (len(opts.Key) == 0) {
+		log.Err(nil, "a client certificate needs both a cert and a key")
+		return false
+	}
+	if len(opts.Token) != 0 && repository.Scheme != "https" {
+		log.Err(nil, "a token can only be sent over https")
+		return false
+	}
+	// the key is trusted to sign every package of the repository, whoever
+	// sits between the hosts could otherwise hand over their own
+	fingerprint := normalizeFingerprint(opts.Fingerprint)
+	if len(fingerprint) == 0 {
+		log.Err(nil, "the fingerprint of the repository key is required, 'catalogue setup' prints it on the other host")
+		return false
+	}
+
+	tlsConfig, ok := clientTLSConfig(opts, log)
+	if !ok {
+		return false
+	}
+
+	keyURL := repository.JoinPath("key.gpg")
+	key, err := ext.NewTLSHTTP(tlsConfig).FetchAuthorized(keyURL, internal.Credential{Token: opts.Token})
+	if err != nil {
+		log.Err(err, "failed to fetch repository key")
+		return false
+	}
+	actual, err := internal.PGPFingerprint(key)
+	if err != nil {
+		log.Err(err, "'%s' is not an openpgp key", keyURL.Redacted())
+		return false
+	}
+	if actual != fingerprint {
+		log.Err(nil, "repository key has fingerprint '%s', expected '%s'", actual, fingerprint)
+		return false
+	}
+
+	name := clientName(repository)
+	keyring := filepath.Join(ext.APTKeyRingsDir, name+".gpg")
+	ok = writeClientFile(keyring, key, 0644, log)
+	if !ok {
+		return false
+	}
+
+	ok = clientTLSFiles(name, repository.Hostname(), opts, log)
+	if !ok {
+		return false
+	}
+
+	authConf := filepath.Join(ext.APTAuthConfDir, name+".conf")
+	if len(opts.Token) != 0 {
+		ok = writeClientFile(authConf, []byte(aptAuthConf(repository, opts.Token)), 0600, log)
+		if !ok {
+			return false
+		}
+	} else {
+		removeClientFile(authConf, log)
+	}
+
+	source := filepath.Join(ext.APTSourceListDir, name+".list")
+	contents := "# This file is auto-generated, any changes may be override later on with `catalogue client-setup`\n" +
+		sourceLine(keyring, repository.String())
+	ok = writeClientFile(source, []byte(contents), 0644, log)
+	if !ok {
+		return false
+	}
+
+	log.Info(5, "added repository '%s', run 'apt update' to use it", repository.Redacted())
+	return true
+}
+
+// repositoryURL is the repository at url, the catalogue repository of the
+// host when url has no path.
+func repositoryURL(value string) (*url.URL, error) {
+	parsed, err := url.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return nil, internal.ErrOf(err, "can not parse '%s'", value)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || len(parsed.Host) == 0 {
+		return nil, internal.Err("'%s' must be an http or https url", value)
+	}
+	if parsed.User != nil || len(parsed.RawQuery) != 0 || len(parsed.Fragment) != 0 {
+		return nil, internal.Err("'%s' must not have credentials, a query or a fragment", value)
+	}
+	parsed.Path = strings.TrimRight(parsed.Path, "/")
+	if len(parsed.Path) == 0 {
+		parsed.Path = "/repositories/" + internal.HostRepository
+	}
+	parsed.RawPath = ""
+	return parsed, nil
+}
+
+// normalizeFingerprint drops the spaces gpg groups a fingerprint with, so it
+// can be given the way either gpg or setup prints it.
+func normalizeFingerprint(value string) string {
+	value = strings.Join(strings.Fields(value), "")
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "0x"), "0X")
+	return strings.ToUpper(value)
+}
+
+// clientName names the files of a repository after its host, so repositories
+// of different hosts can be added side by side.
+func clientName(repository *url.URL) string {
+	name := strings.Builder{}
+	name.WriteString("catalogue-")
+	for _, char := range strings.ToLower(repository.Host) {
+		if (char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') || char == '.' || char == '-' {
+			name.WriteRune(char)
+		} else {
+			name.WriteRune('-')
+		}
+	}
+	return name.String()
+}
+
+func clientTLSConfig(opts ClientOpts, log *internal.Log) (*tls.Config, bool) {
+	config := &tls.Config{}
+	if len(opts.CA) != 0 {
+		data, err := os.ReadFile(opts.CA)
+		if err != nil {
+			log.Err(err, "failed to read certificate authority '%s'", opts.CA)
+			return nil, false
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(data) {
+			log.Err(nil, "no certificates in '%s'", opts.CA)
+			return nil, false
+		}
+	}
+	if len(opts.Cert) != 0 {
+		cert, err := tls.LoadX509KeyPair(opts.Cert, opts.Key)
+		if err != nil {
+			log.Err(err, "failed to load client certificate '%s'", opts.Cert)
+			return nil, false
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, true
+}
+
+// clientTLSFiles keeps a copy of the certificate authority and client
+// certificate, and points apt at them.
+func clientTLSFiles(name string, host string, opts ClientOpts, log *internal.Log) bool {
+	conf := filepath.Join(ext.APTConfDir, "60"+name)
+	if len(opts.CA) == 0 && len(opts.Cert) == 0 {
+		removeClientFile(conf, log)
+		return true
+	}
+
+	dir := filepath.Join(ext.ClientTLSDir, name)
+	var ca, cert, key string
+	copies := []struct {
+		src  string
+		dst  *string
+		name string
+		mode os.FileMode
+	}{
+		{opts.CA, &ca, "ca.crt", 0644},
+		{opts.Cert, &cert, "client.crt", 0644},
+		{opts.Key, &key, "client.key", 0640},
+	}
+	for _, file := range copies {
+		if len(file.src) == 0 {
+			continue
+		}
+		data, err := os.ReadFile(file.src)
+		if err != nil {
+			log.Err(err, "failed to read '%s'", file.src)
+			return false
+		}
+		*file.dst = filepath.Join(dir, file.name)
+		if !writeClientFile(*file.dst, data, file.mode, log) {
+			return false
+		}
+	}
+	if len(key) != 0 && !aptReadable(key, log) {
+		return false
+	}
+
+	contents := "// This file is auto-generated, any changes may be override later on with `catalogue client-setup`\n" +
+		aptTLSConf(host, ca, cert, key)
+	return writeClientFile(conf, []byte(contents), 0644, log)
+}
+
+// aptReadable hands the client key to the group apt downloads as, it is not
+// readable by anyone else.
+func aptReadable(path string, log *internal.Log) bool {
+	group, err := user.LookupGroup("_apt")
+	if err != nil {
+		// apt downloads as root without its own user
+		log.Info(9, "no _apt group, keeping '%s' readable by root only", path)
+		err = os.Chmod(path, 0600)
+		if err != nil {
+			log.Err(err, "failed to change mode of '%s'", path)
+			return false
+		}
+		return true
+	}
+	gid, err := strconv.Atoi(group.Gid)
+	if err != nil {
+		log.Err(err, "invalid gid of group _apt '%s'", group.Gid)
+		return false
+	}
+	err = os.Chown(path, 0, gid)
+	if err != nil {
+		log.Err(err, "failed to hand '%s' to group _apt", path)
+		return false
+	}
+	return true
+}
+
+// aptAuthConf is the auth.conf entry sending token as the password of basic
+// auth, the only kind of auth apt knows.
+func aptAuthConf(repository *url.URL, token string) string {
+	return "machine " + repository.Host + repository.Path + " login catalogue password " + token + "\n"
+}
+
+func writeClientFile(path string, data []byte, mode os.FileMode, log *internal.Log) bool {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		log.Err(err, "failed to create directory of '%s'", path)
+		return false
+	}
+	// WriteFile keeps the mode of a file that already exists, which may let
+	// others read a secret
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Err(err, "failed to replace '%s'", path)
+		return false
+	}
+	err = os.WriteFile(path, data, mode)
+	if err != nil {
+		log.Err(err, "failed to write '%s'", path)
+		return false
+	}
+	log.Info(10, "wrote %s", path)
+	return true
+}
+
+func removeClientFile(path string, log *internal.Log) {
+	err := os.Remove(path)
+	if err == nil {
+		log.Info(10, "removed %s", path)
+	} else if !os.IsNotExist(err) {
+		log.Err(err, "failed to remove '%s'", path)
+	}
+}
@@ -2,12 +2,14 @@ package setup
 
 import (
 	"bytes"
+	"net"
+	"net/url"
 	"os"
-	"strconv"
 	"strings"
 
 	"github.com/woolawin/catalogue/internal"
 	"github.com/woolawin/catalogue/internal/ext"
+	"github.com/woolawin/catalogue/internal/prefs"
 )
 
 func SetUp(log *internal.Log) {
@@ -32,8 +34,14 @@ func SetUp(log *internal.Log) {
 	if err != nil {
 		return
 	}
+	selfSignedTLS(cfg, log)
 	aptKeys(log)
+	keyFingerprint(log)
 	aptRepository(cfg, log)
+	aptTLS(cfg, log)
+	if cfg != nil {
+		prefs.Write(*cfg, log)
+	}
 }
 
 func config(log *internal.Log) *internal.Config {
@@ -146,6 +154,22 @@ func aptKeys(log *internal.Log) {
 	return
 }
 
+// keyFingerprint prints the fingerprint of the repository key, other hosts
+// check the key they fetch against it.
+func keyFingerprint(log *internal.Log) {
+	public, err := os.ReadFile(ext.APTPublicGPGKeyPath)
+	if err != nil {
+		log.Err(err, "failed to read %s", ext.APTPublicGPGKeyPath)
+		return
+	}
+	fingerprint, err := internal.PGPFingerprint(public)
+	if err != nil {
+		log.Err(err, "failed to read fingerprint of %s", ext.APTPublicGPGKeyPath)
+		return
+	}
+	log.Info(10, "repository key fingerprint is %s, add this repository on other hosts with 'catalogue client-setup --fingerprint %s'", fingerprint, fingerprint)
+}
+
 func aptRepository(config *internal.Config, log *internal.Log) {
 	_, err := os.Stat(ext.APTSourceListPath)
 	if err != nil {
@@ -166,12 +190,7 @@ func aptRepository(config *internal.Config, log *internal.Log) {
 
 	contents := strings.Builder{}
 	contents.WriteString("# This file is auto-generated, any changes may be override later on with `catalogue setup`\n")
-
-	contents.WriteString("deb [signed-by=")
-	contents.WriteString(ext.APTPublicGPGKeyPath)
-	contents.WriteString("] ")
-	contents.WriteString(address(config, "catalogue"))
-	contents.WriteString(" stable packages\n")
+	contents.WriteString(sourceLine(ext.APTPublicGPGKeyPath, address(config, internal.HostRepository)))
 
 	_, err = file.Write([]byte(contents.String()))
 
@@ -185,10 +204,71 @@ func aptRepository(config *internal.Config, log *internal.Log) {
 }
 
 func address(config *internal.Config, repo string) string {
+	scheme := "http"
+	if config.TLS.Enabled() {
+		scheme = "https"
+	}
+	host, port, _ := net.SplitHostPort(config.ListenAddresses()[0])
+	if ip := net.ParseIP(host); len(host) == 0 || (ip != nil && ip.IsUnspecified()) {
+		host = "localhost"
+	}
+
 	addr := strings.Builder{}
-	addr.WriteString("http://localhost:")
-	addr.WriteString(strconv.Itoa(config.Port))
+	addr.WriteString(scheme)
+	addr.WriteString("://")
+	addr.WriteString(net.JoinHostPort(host, port))
 	addr.WriteString("/repositories/")
 	addr.WriteString(repo)
 	return addr.String()
 }
+
+func sourceLine(keyring string, address string) string {
+	return "deb [signed-by=" + keyring + "] " + address + " " + internal.HostSuite + " packages\n"
+}
+
+// aptTLS has apt on this host trust the self signed certificate the
+// repository is served with.
+func aptTLS(config *internal.Config, log *internal.Log) {
+	if config == nil || !config.TLS.SelfSigned {
+		err := os.Remove(ext.APTConfPath)
+		if err == nil {
+			log.Info(10, "removed %s", ext.APTConfPath)
+		} else if !os.IsNotExist(err) {
+			log.Err(err, "failed to remove %s", ext.APTConfPath)
+		}
+		return
+	}
+
+	parsed, err := url.Parse(address(config, internal.HostRepository))
+	if err != nil {
+		log.Err(err, "invalid repository address")
+		return
+	}
+	contents := "// This file is auto-generated, any changes may be override later on with `catalogue setup`\n" +
+		aptTLSConf(parsed.Hostname(), ext.TLSCAPath, "", "")
+	err = os.WriteFile(ext.APTConfPath, []byte(contents), 0644)
+	if err != nil {
+		log.Err(err, "failed to write %s", ext.APTConfPath)
+		return
+	}
+	log.Info(10, "created %s", ext.APTConfPath)
+}
+
+// aptTLSConf is the apt config to trust the certificate authority of a host
+// and to present a client certificate to it, empty paths are left out.
+func aptTLSConf(host string, ca string, cert string, key string) string {
+	contents := strings.Builder{}
+	for _, option := range [][2]string{{"CaInfo", ca}, {"SslCert", cert}, {"SslKey", key}} {
+		if len(option[1]) == 0 {
+			continue
+		}
+		contents.WriteString("Acquire::https::")
+		contents.WriteString(host)
+		contents.WriteString("::")
+		contents.WriteString(option[0])
+		contents.WriteString(" \"")
+		contents.WriteString(option[1])
+		contents.WriteString("\";\n")
+	}
+	return contents.String()
+}
@@ -16,3 +16,24 @@ func TestAddress(t *testing.T) {
 		t.Fatalf("expected '%s', got '%s'", expected, actual)
 	}
 }
+
+func TestAddressTLS(t *testing.T) {
+	config := internal.Config{Port: 3465, Listen: []string{"0.0.0.0:8443", "localhost:3465"}, TLS: internal.TLSConfig{SelfSigned: true}}
+
+	actual := address(&config, "catalogue")
+	expected := "https://localhost:8443/repositories/catalogue"
+
+	if actual != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, actual)
+	}
+}
+
+func TestAptTLSConf(t *testing.T) {
+	actual := aptTLSConf("buildhost", "/etc/catalogue/client-tls/ca.crt", "", "/etc/catalogue/client-tls/client.key")
+	expected := "Acquire::https::buildhost::CaInfo \"/etc/catalogue/client-tls/ca.crt\";\n" +
+		"Acquire::https::buildhost::SslKey \"/etc/catalogue/client-tls/client.key\";\n"
+
+	if actual != expected {
+		t.Fatalf("expected '%s', got '%s'", expected, actual)
+	}
+}
@@ -0,0 +1,153 @@
+package setup
+
+import (
+	"net"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// tlsRenewBefore is how long before it expires the server certificate is
+// issued again.
+const tlsRenewBefore = 30 * 24 * time.Hour
+
+// selfSignedTLS creates the certificate authority and issues the server
+// certificate from it when tls is self signed. The certificate is issued
+// again when it is about to expire or does not cover every host the
+// repository is served on.
+func selfSignedTLS(config *internal.Config, log *internal.Log) {
+	if config == nil || !config.TLS.SelfSigned {
+		return
+	}
+
+	err := os.MkdirAll(ext.TLSDir, 0755)
+	if err != nil {
+		log.Err(err, "failed to create %s", ext.TLSDir)
+		return
+	}
+
+	now := time.Now()
+	ca, ok := tlsCA(now, log)
+	if !ok {
+		return
+	}
+
+	hosts := tlsHosts(config.ListenAddresses())
+	existing, err := os.ReadFile(ext.TLSCertPath)
+	if err == nil {
+		if validTLSCert(existing, hosts, now) {
+			log.Info(10, "%s already exists", ext.TLSCertPath)
+			return
+		}
+		log.Info(10, "%s is about to expire or does not cover every listen address, will regenerate", ext.TLSCertPath)
+	} else if !os.IsNotExist(err) {
+		log.Err(err, "failed to read %s", ext.TLSCertPath)
+		return
+	}
+
+	cert, err := internal.CreateTLSCert(ca, hosts, now)
+	if err != nil {
+		log.Err(err, "failed to create tls certificate")
+		return
+	}
+	err = os.WriteFile(ext.TLSKeyPath, cert.Key, 0600)
+	if err != nil {
+		log.Err(err, "failed to write %s", ext.TLSKeyPath)
+		return
+	}
+	err = os.WriteFile(ext.TLSCertPath, cert.Cert, 0644)
+	if err != nil {
+		log.Err(err, "failed to write %s", ext.TLSCertPath)
+		return
+	}
+	log.Info(10, "created %s for %v", ext.TLSCertPath, hosts)
+}
+
+func tlsCA(now time.Time, log *internal.Log) (internal.TLSKeyPair, bool) {
+	cert, certErr := os.ReadFile(ext.TLSCAPath)
+	key, keyErr := os.ReadFile(ext.TLSCAKeyPath)
+	if certErr == nil && keyErr == nil {
+		log.Info(10, "%s already exists", ext.TLSCAPath)
+		return internal.TLSKeyPair{Cert: cert, Key: key}, true
+	}
+	for _, err := range []error{certErr, keyErr} {
+		if err != nil && !os.IsNotExist(err) {
+			log.Err(err, "failed to read tls certificate authority")
+			return internal.TLSKeyPair{}, false
+		}
+	}
+
+	log.Info(10, "missing tls certificate authority, will generate")
+	ca, err := internal.CreateTLSCA(now)
+	if err != nil {
+		log.Err(err, "failed to create tls certificate authority")
+		return internal.TLSKeyPair{}, false
+	}
+	err = os.WriteFile(ext.TLSCAKeyPath, ca.Key, 0600)
+	if err != nil {
+		log.Err(err, "failed to write %s", ext.TLSCAKeyPath)
+		return internal.TLSKeyPair{}, false
+	}
+	err = os.WriteFile(ext.TLSCAPath, ca.Cert, 0644)
+	if err != nil {
+		log.Err(err, "failed to write %s", ext.TLSCAPath)
+		return internal.TLSKeyPair{}, false
+	}
+	// the server certificate was issued by the old authority
+	os.Remove(ext.TLSCertPath)
+	log.Info(10, "created %s", ext.TLSCAPath)
+	return ca, true
+}
+
+func validTLSCert(data []byte, hosts []string, now time.Time) bool {
+	cert, err := internal.ParseTLSCert(data)
+	if err != nil || cert.NotAfter.Before(now.Add(tlsRenewBefore)) {
+		return false
+	}
+	for _, host := range hosts {
+		if cert.VerifyHostname(host) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// tlsHosts are the host names and addresses clients may reach the repository
+// by. An address listening on every interface can be reached by the host
+// name and the address of any interface.
+func tlsHosts(addresses []string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+	add := func(host string) {
+		if len(host) != 0 && !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+
+	if name, err := os.Hostname(); err == nil {
+		add(name)
+	}
+	for _, address := range addresses {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			continue
+		}
+		ip := net.ParseIP(host)
+		if len(host) != 0 && (ip == nil || !ip.IsUnspecified()) {
+			add(host)
+			continue
+		}
+		interfaces, err := net.InterfaceAddrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range interfaces {
+			if network, ok := addr.(*net.IPNet); ok && !network.IP.IsLinkLocalUnicast() {
+				add(network.IP.String())
+			}
+		}
+	}
+	return hosts
+}
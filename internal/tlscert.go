@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"time"
+)
+
+// TLSKeyPair is a PEM encoded certificate and its private key.
+type TLSKeyPair struct {
+	Cert []byte
+	Key  []byte
+}
+
+const tlsCAValidity = 10 * 365 * 24 * time.Hour
+const tlsCertValidity = 2 * 365 * 24 * time.Hour
+
+// CreateTLSCA creates the certificate authority self signed certificates of
+// the repository are issued by, clients trust it instead of each
+// certificate.
+func CreateTLSCA(now time.Time) (TLSKeyPair, error) {
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Catalogue APT Server CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(tlsCAValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	return createTLSCert(template, nil)
+}
+
+// CreateTLSCert issues a server certificate for hosts, which are host names
+// or IP addresses, from the certificate authority.
+func CreateTLSCert(ca TLSKeyPair, hosts []string, now time.Time) (TLSKeyPair, error) {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "Catalogue APT Server"},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(tlsCertValidity),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	return createTLSCert(template, &ca)
+}
+
+func createTLSCert(template *x509.Certificate, ca *TLSKeyPair) (TLSKeyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return TLSKeyPair{}, ErrOf(err, "can not generate tls key")
+	}
+	template.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return TLSKeyPair{}, ErrOf(err, "can not generate certificate serial number")
+	}
+
+	parent, signer := template, any(key)
+	if ca != nil {
+		issuer, err := tls.X509KeyPair(ca.Cert, ca.Key)
+		if err != nil {
+			return TLSKeyPair{}, ErrOf(err, "invalid certificate authority")
+		}
+		parent, err = x509.ParseCertificate(issuer.Certificate[0])
+		if err != nil {
+			return TLSKeyPair{}, ErrOf(err, "invalid certificate authority")
+		}
+		signer = issuer.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		return TLSKeyPair{}, ErrOf(err, "can not create certificate")
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return TLSKeyPair{}, ErrOf(err, "can not encode tls key")
+	}
+	return TLSKeyPair{
+		Cert: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		Key:  pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
+
+// ParseTLSCert parses the first certificate of a PEM file.
+func ParseTLSCert(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, Err("not a PEM encoded certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, ErrOf(err, "invalid certificate")
+	}
+	return cert, nil
+}
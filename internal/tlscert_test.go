@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func TestCreateTLSCert(t *testing.T) {
+	now := time.Now()
+	ca, err := CreateTLSCA(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := CreateTLSCert(ca, []string{"localhost", "buildhost", "192.168.1.10"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca.Cert) {
+		t.Fatal("expected a certificate authority")
+	}
+	parsed, err := ParseTLSCert(cert.Cert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"localhost", "buildhost", "192.168.1.10"} {
+		_, err = parsed.Verify(x509.VerifyOptions{DNSName: host, Roots: pool})
+		if err != nil {
+			t.Fatalf("expected certificate to be valid for '%s': %v", host, err)
+		}
+	}
+	if parsed.VerifyHostname("otherhost") == nil {
+		t.Fatal("expected certificate not to be valid for 'otherhost'")
+	}
+}
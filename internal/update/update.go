@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"io"
+	"os"
 	"path/filepath"
 
 	"github.com/woolawin/catalogue/internal"
@@ -15,26 +16,21 @@ import (
 	"github.com/woolawin/catalogue/internal/registry"
 )
 
-func Update(record config.Record, log *internal.Log, system internal.System, api *ext.API) (config.Record, config.BuildFile, bool) {
+func Update(record config.Record, vcs clone.VCS, buildOpts build.Opts, log *internal.Log, system internal.System, api *ext.API) (config.Record, config.BuildFile, bool) {
 	prev := log.Stage("update")
 	defer prev()
 
 	local := api.Host.RandomTmpDir()
+	defer os.RemoveAll(local)
 
 	log.Info(9, "updating component '%s'", record.Name)
 	opts := clone.NewOpts(
-		record.Remote,
 		local,
 		".catalogue",
 		nil,
 	)
 
-	author, ok := clone.Clone(opts, log, api)
-	if !ok {
-		return config.Record{}, config.BuildFile{}, false
-	}
-
-	pin, ok := clone.CheckoutLatestVersion(local, log)
+	pin, author, ok := clone.Clone(vcs, opts, log, api)
 	if !ok {
 		log.Err(nil, "failed to checkout latest version of %s", record.Name)
 		return config.Record{}, config.BuildFile{}, false
@@ -54,7 +50,17 @@ func Update(record config.Record, log *internal.Log, system internal.System, api
 		return config.Record{}, config.BuildFile{}, false
 	}
 
-	metadata, err := config.BuildMetadata(component.Metadata, record.Remote, author, log, system)
+	if component.Build != nil {
+		ok = clone.CloneTree(vcs, pin, local, log)
+		if !ok {
+			return config.Record{}, config.BuildFile{}, false
+		}
+	}
+
+	readFile := func(path string) ([]byte, error) {
+		return clone.ReadFile(vcs, pin, local, path, api)
+	}
+	metadata, err := config.BuildMetadata(component.Metadata, record.Remote, author, readFile, log, system)
 	if err != nil {
 		log.Err(err, "failed to build metadata from config.toml")
 		return config.Record{}, config.BuildFile{}, false
@@ -73,6 +79,7 @@ func Update(record config.Record, log *internal.Log, system internal.System, api
 		log.Err(err, "failed to assemle package '%s'", record.Name)
 		return config.Record{}, config.BuildFile{}, false
 	}
+	defer os.Remove(file.Name())
 	defer file.Close()
 
 	hasher := sha256.New()
@@ -80,16 +87,22 @@ func Update(record config.Record, log *internal.Log, system internal.System, api
 
 	writer := io.MultiWriter(file, hasher, &counter)
 
-	ok = build.Build(writer, record, log, system, ext.NewAPI(buildPath))
+	ok = build.Build(writer, record, buildOpts, log, system, ext.NewAPI(buildPath))
 	if !ok {
 		return config.Record{}, config.BuildFile{}, false
 	}
 
+	path, err := registry.SaveBuildFile(file)
+	if err != nil {
+		log.Err(err, "failed to save build of package '%s'", record.Name)
+		return config.Record{}, config.BuildFile{}, false
+	}
+
 	digest := hex.EncodeToString(hasher.Sum(nil))
 	build := config.BuildFile{
 		Version:    pin.VersionName,
 		CommitHash: pin.CommitHash,
-		Path:       file.Name(),
+		Path:       path,
 		Size:       counter.Count(),
 		SHA245:     digest,
 	}
@@ -0,0 +1,102 @@
+package update
+
+import (
+	"net/url"
+	"os/exec"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/build"
+	"github.com/woolawin/catalogue/internal/clone"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+	"github.com/woolawin/catalogue/internal/registry"
+)
+
+type DoNothingLogger struct {
+}
+
+func (log *DoNothingLogger) Log(stmt *internal.LogStatement) {
+
+}
+
+const configTOML = `
+name='foo'
+type='package'
+supported_targets=['all']
+
+[metadata.all]
+maintainer='Bob Doe <bob@foo.com>'
+description='foo'
+architecture='all'
+`
+
+func u(value string) *url.URL {
+	res, err := url.Parse(value)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func TestUpdateMovesPin(t *testing.T) {
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		t.Skip("dpkg-deb is not installed")
+	}
+	base := registry.PackagesBase
+	registry.PackagesBase = t.TempDir()
+	t.Cleanup(func() { registry.PackagesBase = base })
+
+	remote := config.Remote{Protocol: config.Git, URL: u("https://foo.com/foo.git")}
+	vcs := &clone.MockVCS{
+		Source: remote,
+		Versions: []config.Pin{
+			{VersionName: "1.0.0", CommitHash: "aaa"},
+			{VersionName: "1.1.0", CommitHash: "bbb"},
+		},
+		Files: map[string]map[string]string{
+			"bbb": {
+				".catalogue/config.toml":                           configTOML,
+				".catalogue/filemaps/root.all/usr/share/foo/a.txt": "a",
+			},
+		},
+		Authors: map[string]string{"bbb": "Bob Doe <bob@foo.com>"},
+	}
+	previous := config.BuildFile{Version: "1.0.0", CommitHash: "aaa", Path: "/foo-aaa.deb"}
+	record := config.Record{
+		Name:      "foo",
+		LatestPin: config.Pin{VersionName: "1.0.0", CommitHash: "aaa"},
+		Remote:    remote,
+		Builds:    []config.BuildFile{previous},
+	}
+
+	log := internal.NewLog(&DoNothingLogger{})
+	system := internal.System{Architecture: internal.AMD64}
+	updated, latest, ok := Update(record, vcs, build.Opts{}, log, system, ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected update to succeed")
+	}
+
+	expected := config.Pin{VersionName: "1.1.0", CommitHash: "bbb"}
+	if diff := cmp.Diff(expected, updated.LatestPin); diff != "" {
+		t.Fatalf("pin mismatch (-want +got):\n%s", diff)
+	}
+	if latest.Version != "1.1.0" || latest.CommitHash != "bbb" {
+		t.Fatalf("unexpected build of '%s' at '%s'", latest.Version, latest.CommitHash)
+	}
+	if diff := cmp.Diff([]config.BuildFile{previous, latest}, updated.Builds); diff != "" {
+		t.Fatalf("builds mismatch (-want +got):\n%s", diff)
+	}
+
+	written, found, err := registry.GetPackageRecord("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("expected the updated record to be written")
+	}
+	if diff := cmp.Diff(expected, written.LatestPin); diff != "" {
+		t.Fatalf("written pin mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,75 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/build"
+	"github.com/woolawin/catalogue/internal/clone"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+)
+
+// Verify rebuilds the latest build of a package from its pin and checks it
+// comes out the same as the package in the registry.
+func Verify(record config.Record, vcs clone.VCS, log *internal.Log, system internal.System, api *ext.API) bool {
+	prev := log.Stage("verify")
+	defer prev()
+
+	var built *config.BuildFile
+	for _, candidate := range record.Builds {
+		if candidate.Version == record.LatestPin.VersionName && candidate.CommitHash == record.LatestPin.CommitHash {
+			built = &candidate
+		}
+	}
+	if built == nil {
+		log.Err(nil, "package '%s' has no build of '%s'", record.Name, record.LatestPin.VersionName)
+		return false
+	}
+
+	local := api.Host.RandomTmpDir()
+	defer os.RemoveAll(local)
+
+	opts := clone.NewOpts(local, ".catalogue", &record.LatestPin)
+	pin, _, ok := clone.Clone(vcs, opts, log, api)
+	if !ok {
+		return false
+	}
+
+	buildPath := filepath.Join(local, ".catalogue")
+	configData, err := api.Host.ReadTmpFile(filepath.Join(buildPath, "config.toml"))
+	if err != nil {
+		log.Err(err, "failed to read config.toml")
+		return false
+	}
+	component, err := config.Parse(bytes.NewReader(configData))
+	if err != nil {
+		log.Err(err, "failed to deserialize config.toml")
+		return false
+	}
+	if component.Build != nil {
+		ok = clone.CloneTree(vcs, pin, local, log)
+		if !ok {
+			return false
+		}
+	}
+
+	record.LatestPin = pin
+	hasher := sha256.New()
+	ok = build.Build(hasher, record, build.Opts{}, log, system, ext.NewAPI(buildPath))
+	if !ok {
+		return false
+	}
+
+	digest := hex.EncodeToString(hasher.Sum(nil))
+	if digest != built.SHA245 {
+		log.Err(nil, "rebuilding '%s' at '%s' gave SHA256 '%s', the registry has '%s'", record.Name, pin.VersionName, digest, built.SHA245)
+		return false
+	}
+	log.Info(9, "rebuilding '%s' at '%s' gave the same package, SHA256 '%s'", record.Name, pin.VersionName, digest)
+	return true
+}
@@ -0,0 +1,89 @@
+package verify
+
+import (
+	"net/url"
+	"os/exec"
+	"testing"
+
+	"github.com/woolawin/catalogue/internal"
+	"github.com/woolawin/catalogue/internal/add"
+	"github.com/woolawin/catalogue/internal/build"
+	"github.com/woolawin/catalogue/internal/clone"
+	"github.com/woolawin/catalogue/internal/config"
+	"github.com/woolawin/catalogue/internal/ext"
+	"github.com/woolawin/catalogue/internal/registry"
+)
+
+type DoNothingLogger struct {
+}
+
+func (log *DoNothingLogger) Log(stmt *internal.LogStatement) {
+
+}
+
+const configTOML = `
+name='foo'
+type='package'
+supported_targets=['all']
+
+[metadata.all]
+maintainer='Bob Doe <bob@foo.com>'
+description='foo'
+architecture='all'
+`
+
+func mockVCS(t *testing.T) *clone.MockVCS {
+	remote, err := url.Parse("https://foo.com/foo.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &clone.MockVCS{
+		Source:   config.Remote{Protocol: config.Git, URL: remote},
+		Versions: []config.Pin{{VersionName: "1.0.0", CommitHash: "aaa"}},
+		Files: map[string]map[string]string{
+			"aaa": {
+				".catalogue/config.toml":                           configTOML,
+				".catalogue/filemaps/root.all/usr/share/foo/a.txt": "a",
+			},
+		},
+		Authors: map[string]string{"aaa": "Bob Doe <bob@foo.com>"},
+	}
+}
+
+func TestVerify(t *testing.T) {
+	if _, err := exec.LookPath("dpkg-deb"); err != nil {
+		t.Skip("dpkg-deb is not installed")
+	}
+	base := registry.PackagesBase
+	registry.PackagesBase = t.TempDir()
+	t.Cleanup(func() { registry.PackagesBase = base })
+
+	log := internal.NewLog(&DoNothingLogger{})
+	system := internal.System{Architecture: internal.AMD64}
+
+	vcs := mockVCS(t)
+	ok := add.Add(vcs, build.Opts{}, log, system, ext.NewAPI("/"))
+	if !ok {
+		t.Fatal("expected add to succeed")
+	}
+	record, found, err := registry.GetPackageRecord("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !found {
+		t.Fatal("expected package 'foo' in the registry")
+	}
+
+	for range 2 {
+		ok = Verify(record, vcs, log, system, ext.NewAPI("/"))
+		if !ok {
+			t.Fatal("expected rebuilding the same commit to give the same package")
+		}
+	}
+
+	vcs.Files["aaa"][".catalogue/filemaps/root.all/usr/share/foo/a.txt"] = "b"
+	ok = Verify(record, vcs, log, system, ext.NewAPI("/"))
+	if ok {
+		t.Fatal("expected a rebuild with different contents to not verify")
+	}
+}